@@ -57,7 +57,7 @@ func (s *DBService) CreateAccount(user *User, account *Account) error {
 	account.UUID = uuid.NewString()
 	account.Balance = 0
 
-	return s.update(func() error {
+	return s.update(user, func(s *DBService) error {
 		return s.createAccount(user, account)
 	})
 }
@@ -65,7 +65,7 @@ func (s *DBService) CreateAccount(user *User, account *Account) error {
 // UpdateAccount saves an already existing account.
 // If the account doesn't exist, it returns an error.
 func (s *DBService) UpdateAccount(user *User, account *Account) error {
-	return s.update(func() error {
+	return s.update(user, func(s *DBService) error {
 		key := user.createAccountKey(account)
 
 		previousAccount, err := s.getAccount(user, account.UUID)
@@ -131,7 +131,7 @@ func (s *DBService) getAccounts(user *User) ([]*Account, error) {
 			return nil, fmt.Errorf("failed to get account %v: %w", string(accountKey), err)
 		}
 		if accountValue == nil {
-			// TODO: schedule a cleanup for this user.
+			s.scheduleCleanup(user)
 			continue
 		}
 
@@ -164,11 +164,36 @@ func (s *DBService) getAccount(user *User, accountUUID string) (*Account, error)
 	return account, nil
 }
 
+// getAccountBalances computes the balances of accounts from transactions matching options.
+func (s *DBService) getAccountBalances(user *User, options TransactionFilterOptions) (map[string]int64, error) {
+	transactions, err := s.getTransactions(user, GetTransactionOptions{Limit: GetAllTransactionsOptions.Limit, TransactionFilterOptions: options})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+
+	balances := make(map[string]int64)
+	for _, transaction := range transactions {
+		for _, component := range transaction.Components {
+			balances[component.AccountUUID] += component.Amount
+		}
+	}
+	return balances, nil
+}
+
+// getAccountBalance computes the balance of accountUUID from transactions dated up to toDate (inclusive).
+func (s *DBService) getAccountBalance(user *User, accountUUID string, toDate string) (int64, error) {
+	balances, err := s.getAccountBalances(user, TransactionFilterOptions{FilterAccounts: []string{accountUUID}, FilterToDate: toDate})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute balance for account %v: %w", accountUUID, err)
+	}
+	return balances[accountUUID], nil
+}
+
 // GetAccount returns an Account by its UUID.
 // If the Account doesn't exist, it returns nil.
 func (s *DBService) GetAccount(user *User, accountUUID string) (*Account, error) {
 	var account *Account
-	err := s.view(func() error {
+	err := s.view(user, func() error {
 		var err error
 		account, err = s.getAccount(user, accountUUID)
 		return err
@@ -182,7 +207,7 @@ func (s *DBService) GetAccount(user *User, accountUUID string) (*Account, error)
 // GetAccounts returns all accounts for user.
 func (s *DBService) GetAccounts(user *User) ([]*Account, error) {
 	var accounts []*Account
-	err := s.view(func() error {
+	err := s.view(user, func() error {
 		var err error
 		accounts, err = s.getAccounts(user)
 		return err
@@ -223,7 +248,7 @@ func (s *DBService) deleteAccounts(user *User) error {
 // If the account doesn't exist, it returns an error.
 func (s *DBService) DeleteAccount(user *User, accountUUID string) error {
 	key := user.createAccountKeyFromUUID(accountUUID)
-	return s.update(func() error {
+	return s.update(user, func(s *DBService) error {
 		exists, err := s.db.Has(key)
 		if err != nil {
 			return fmt.Errorf("cannot check if account exists %v: %w", accountUUID, err)
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// amountDecimals is the number of decimal places stored in an amount.
+const amountDecimals = 2
+
+// parseAmount parses a decimal string into an amount (with amountDecimals implied decimal places).
+// Thousand separators and spaces are ignored, and a leading or trailing minus sign makes the amount negative.
+func parseAmount(value string, decimalSeparator string) (int64, error) {
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+
+	integerValue, fractionValue := strings.TrimSpace(value), ""
+	if i := strings.LastIndex(integerValue, decimalSeparator); i >= 0 {
+		integerValue, fractionValue = integerValue[:i], integerValue[i+len(decimalSeparator):]
+	}
+
+	negative := false
+	digits := 0
+	var amount strings.Builder
+	for _, c := range integerValue {
+		switch {
+		case c >= '0' && c <= '9':
+			amount.WriteRune(c)
+			digits++
+		case c == '-':
+			negative = true
+		case c == '+' || c == ',' || c == '.' || c == '\'' || unicode.IsSpace(c):
+			// Ignore sign and thousand separators.
+		default:
+			return 0, fmt.Errorf("invalid character %q in amount %v", c, value)
+		}
+	}
+
+	fraction := 0
+	for _, c := range fractionValue {
+		switch {
+		case c >= '0' && c <= '9':
+			if fraction < amountDecimals {
+				amount.WriteRune(c)
+			} else if c != '0' {
+				return 0, fmt.Errorf("amount %v has more than %v decimal places", value, amountDecimals)
+			}
+			fraction++
+			digits++
+		case c == '-':
+			negative = true
+		case unicode.IsSpace(c):
+		default:
+			return 0, fmt.Errorf("invalid character %q in amount %v", c, value)
+		}
+	}
+	if digits == 0 {
+		return 0, fmt.Errorf("amount %v has no digits", value)
+	}
+	for ; fraction < amountDecimals; fraction++ {
+		amount.WriteRune('0')
+	}
+
+	result, err := strconv.ParseInt(amount.String(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse amount %v: %w", value, err)
+	}
+	if negative {
+		result = -result
+	}
+	return result, nil
+}
+
+// formatAmount converts an amount into a decimal string with amountDecimals decimal places.
+func formatAmount(amount int64) string {
+	sign := ""
+	value := strconv.FormatInt(amount, 10)
+	if amount < 0 {
+		sign, value = "-", value[1:]
+	}
+	if len(value) <= amountDecimals {
+		value = strings.Repeat("0", amountDecimals-len(value)+1) + value
+	}
+	return sign + value[:len(value)-amountDecimals] + "." + value[len(value)-amountDecimals:]
+}
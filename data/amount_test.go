@@ -0,0 +1,48 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value            string
+		decimalSeparator string
+		amount           int64
+	}{
+		{"12.34", ".", 1234},
+		{"-12.34", ".", -1234},
+		{"12.3", ".", 1230},
+		{"12", ".", 1200},
+		{"1,234.50", ".", 123450},
+		{"1.234,50", ",", 123450},
+		{"1 234,50-", ",", -123450},
+		{" +0.01 ", "", 1},
+		{"12.500", ".", 1250},
+	}
+	for _, test := range tests {
+		amount, err := parseAmount(test.value, test.decimalSeparator)
+		assert.NoError(t, err, test.value)
+		assert.Equal(t, test.amount, amount, test.value)
+	}
+
+	for _, value := range []string{"", "-", "12.345", "EUR 12", "1.2.3a"} {
+		_, err := parseAmount(value, ".")
+		assert.Error(t, err, value)
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := map[int64]string{
+		0:       "0.00",
+		1:       "0.01",
+		-5:      "-0.05",
+		1234:    "12.34",
+		-123450: "-1234.50",
+	}
+	for amount, value := range tests {
+		assert.Equal(t, value, formatAmount(amount))
+	}
+}
@@ -1,83 +1,180 @@
 package data
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 )
 
-// backupData is the toplevel structure exported in a backup.
-type backupData struct {
-	Accounts     []*Account
-	Transactions []*Transaction
-}
+// ErrBackupDataChanged is an error when data is changed while a backup is written.
+var ErrBackupDataChanged = fmt.Errorf("data was changed while the backup was written")
 
-// Backup returns a serialized copy of all data for user.
-func (s *DBService) Backup(user *User) (string, error) {
-	data := backupData{}
+// backupIndent is the indentation used for JSON in backups.
+const backupIndent = "  "
 
-	err := s.view(func() error {
-		var err error
-		accounts, err := s.getAccounts(user)
-		if err != nil {
-			return fmt.Errorf("failed to get accounts: %w", err)
-		}
+// BackupOptions specifies options for creating a backup.
+type BackupOptions struct {
+	// Compress enables gzip compression.
+	Compress bool
+	// Passphrase enables encryption with a key derived from the passphrase.
+	Passphrase string
+}
 
-		transactions, err := s.getTransactions(user, GetAllTransactionsOptions)
-		if err != nil {
-			return fmt.Errorf("failed to get transactions: %w", err)
-		}
+// backupWriter writes a JSON backup, one item at a time.
+// The output has the same format as json.MarshalIndent of an object with the Accounts and Transactions lists.
+type backupWriter struct {
+	w         *bufio.Writer
+	listItems int
+	err       error
+}
 
-		// Reverse the order.
-		for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
-			transactions[i], transactions[j] = transactions[j], transactions[i]
-		}
+// write writes value to the output, unless a previous write has failed.
+func (bw *backupWriter) write(value string) {
+	if bw.err != nil {
+		return
+	}
+	_, bw.err = bw.w.WriteString(value)
+}
 
-		data.Accounts = accounts
-		data.Transactions = transactions
-		return nil
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to get data to back up: %w", err)
+// startList starts a list with name.
+func (bw *backupWriter) startList(name string, first bool) {
+	if first {
+		bw.write("{")
+	} else {
+		bw.write(",")
 	}
+	bw.write("\n" + backupIndent + `"` + name + `": [`)
+	bw.listItems = 0
+}
 
-	value, err := json.MarshalIndent(data, "", "  ")
+// writeItem adds item to the current list.
+func (bw *backupWriter) writeItem(item interface{}) {
+	if bw.err != nil {
+		return
+	}
+	value, err := json.MarshalIndent(item, backupIndent+backupIndent, backupIndent)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling json: %w", err)
+		bw.err = fmt.Errorf("error marshaling json: %w", err)
+		return
+	}
+	if bw.listItems > 0 {
+		bw.write(",")
 	}
+	bw.write("\n" + backupIndent + backupIndent)
+	bw.write(string(value))
+	bw.listItems++
+}
 
-	return string(value), nil
+// endList ends the current list.
+func (bw *backupWriter) endList() {
+	if bw.listItems > 0 {
+		bw.write("\n" + backupIndent)
+	}
+	bw.write("]")
 }
 
-// Restore replaces all data for user with the provided serialized backup.
-func (s *DBService) Restore(user *User, value string) error {
-	data := backupData{}
-	if err := json.Unmarshal([]byte(value), &data); err != nil {
-		return fmt.Errorf("error unmarshaling json: %w", err)
+// flush writes buffered data to the output, unless a previous write has failed.
+func (bw *backupWriter) flush() {
+	if bw.err != nil {
+		return
 	}
+	bw.err = bw.w.Flush()
+}
 
-	return s.update(func() error {
-		// Delete previous values.
-		if err := s.deleteAccounts(user); err != nil {
-			return fmt.Errorf("failed to cleanup previous accounts: %w", err)
-		}
-		if err := s.deleteTransactions(user); err != nil {
-			return fmt.Errorf("failed to cleanup previous transactions: %w", err)
+// close ends the backup and flushes the output.
+func (bw *backupWriter) close() error {
+	bw.write("\n}")
+	if bw.err != nil {
+		return bw.err
+	}
+	return bw.w.Flush()
+}
+
+// Backup writes a serialized copy of all data for user into w.
+// Transactions are read one page at a time, without loading all of them into memory,
+// and without blocking updates while a page is written.
+// If data of user is changed while the backup is written, the backup is cancelled with ErrBackupDataChanged,
+// as it would be inconsistent.
+// If a passphrase is specified, the backup is compressed first and then encrypted.
+func (s *DBService) Backup(user *User, w io.Writer, options BackupOptions) error {
+	var encryptedWriter *encryptedBackupWriter
+	if options.Passphrase != "" {
+		var err error
+		encryptedWriter, err = newEncryptedBackupWriter(w, options.Passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt backup: %w", err)
 		}
-		for _, account := range data.Accounts {
-			account.Balance = 0
+		w = encryptedWriter
+	}
+	var gzipWriter *gzip.Writer
+	if options.Compress {
+		gzipWriter = gzip.NewWriter(w)
+		w = gzipWriter
+	}
+	bw := &backupWriter{w: bufio.NewWriter(w)}
 
-			if err := s.createAccount(user, account); err != nil {
-				return fmt.Errorf("failed to create account %v: %w", account, err)
-			}
+	var accounts []*Account
+	var transactionUUIDs []string
+	var version uint64
+	err := s.view(user, func() error {
+		version = s.version(user)
+		var err error
+		accounts, err = s.getAccounts(user)
+		if err != nil {
+			return fmt.Errorf("failed to get accounts: %w", err)
 		}
+		transactionUUIDs, err = s.getMatchingTransactionUUIDs(user, &TransactionFilterOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get transactions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to back up data: %w", err)
+	}
 
-		for _, transaction := range data.Transactions {
-			transaction.normalize()
+	bw.startList("Accounts", true)
+	for _, account := range accounts {
+		bw.writeItem(account)
+	}
+	bw.endList()
 
-			if err := s.createTransaction(user, transaction); err != nil {
-				return fmt.Errorf("failed to create transaction %v: %w", transaction, err)
-			}
+	// Write transactions in reverse order, oldest first.
+	for i, j := 0, len(transactionUUIDs)-1; i < j; i, j = i+1, j-1 {
+		transactionUUIDs[i], transactionUUIDs[j] = transactionUUIDs[j], transactionUUIDs[i]
+	}
+	bw.startList("Transactions", false)
+	checkFn := func() error {
+		if s.version(user) != version {
+			return ErrBackupDataChanged
 		}
 		return nil
+	}
+	err = s.iterateTransactionPages(user, transactionUUIDs, checkFn, func(transactions []*Transaction) error {
+		for _, transaction := range transactions {
+			bw.writeItem(transaction)
+		}
+		bw.flush()
+		return bw.err
 	})
+	if err != nil {
+		return fmt.Errorf("failed to back up data: %w", err)
+	}
+	bw.endList()
+	if err := bw.close(); err != nil {
+		return fmt.Errorf("failed to back up data: %w", err)
+	}
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			return fmt.Errorf("failed to compress backup: %w", err)
+		}
+	}
+	if encryptedWriter != nil {
+		if err := encryptedWriter.Close(); err != nil {
+			return fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+	}
+	return nil
 }
@@ -1,6 +1,9 @@
 package data
 
 import (
+	"bytes"
+	"compress/gzip"
+	"io"
 	"sort"
 	"strings"
 	"testing"
@@ -367,17 +370,81 @@ func TestBackup(t *testing.T) {
 		dbService.createTransaction(&testUser, transaction)
 	}
 
-	json, err := dbService.Backup(&testUser)
+	var json strings.Builder
+	err = dbService.Backup(&testUser, &json, BackupOptions{})
 	assert.NoError(t, err)
-	assert.Equal(t, testBackupData, json)
+	assert.Equal(t, testBackupData, json.String())
+}
+
+// updatingWriter runs an update of user every time data is written into it.
+type updatingWriter struct {
+	strings.Builder
+	user    *User
+	updates int
+}
+
+func (w *updatingWriter) Write(p []byte) (int, error) {
+	err := dbService.update(w.user, func(s *DBService) error {
+		return s.db.Put([]byte("k1"), p)
+	})
+	if err != nil {
+		return 0, err
+	}
+	w.updates++
+	return w.Builder.Write(p)
+}
+
+func TestBackupPages(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	previousPageSize := transactionPageSize
+	transactionPageSize = 2
+	defer func() { transactionPageSize = previousPageSize }()
+
+	accounts := createBackupAccounts()
+	for _, account := range accounts {
+		dbService.createAccount(&testUser, account)
+	}
+
+	transactions := createBackupTransactions(accounts)
+	transactions[4].Tags = []string{"Widgets", "Gadgets"}
+	for _, transaction := range transactions {
+		assert.NoError(t, transaction.normalize())
+		dbService.createTransaction(&testUser, transaction)
+	}
+
+	// Writes happen outside of views, and don't block updates.
+	json := updatingWriter{user: &User{UUID: "uuid12"}}
+	err = dbService.Backup(&testUser, &json, BackupOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, testBackupData, json.String())
+	assert.Greater(t, json.updates, 1)
+
+	// Updates of the user's data between pages cancel the backup.
+	json = updatingWriter{user: &testUser}
+	err = dbService.Backup(&testUser, &json, BackupOptions{})
+	assert.ErrorIs(t, err, ErrBackupDataChanged)
+	assert.Equal(t, 1, json.updates)
+}
+
+func TestBackupEmpty(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	var json strings.Builder
+	err = dbService.Backup(&testUser, &json, BackupOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"Accounts\": [],\n  \"Transactions\": []\n}", json.String())
 }
 
 func TestRestore(t *testing.T) {
 	err := resetDb()
 	assert.NoError(t, err)
 
-	err = dbService.Restore(&testUser, testRestoreData)
+	result, err := dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
 	assert.NoError(t, err)
+	assert.Equal(t, &RestoreResult{Inserted: 9}, result)
 
 	expectedAccounts := createBackupAccounts()
 	expectedAccounts[0].Balance = 99000
@@ -424,7 +491,7 @@ func TestRestoreOverwriteExistingData(t *testing.T) {
 		dbService.CreateTransaction(&testUser, transaction)
 	}
 
-	err = dbService.Restore(&testUser, testRestoreData)
+	_, err = dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
 	assert.NoError(t, err)
 
 	expectedAccounts := createBackupAccounts()
@@ -446,3 +513,59 @@ func TestRestoreOverwriteExistingData(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedTransactions, dbTransactions)
 }
+
+func TestBackupRestoreCompressed(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	var compressed bytes.Buffer
+	err = dbService.Backup(&testUser, &compressed, BackupOptions{Compress: true})
+	assert.NoError(t, err)
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(compressed.Bytes()))
+	assert.NoError(t, err)
+	uncompressed, err := io.ReadAll(gzipReader)
+	assert.NoError(t, err)
+	assert.Equal(t, testBackupData, string(uncompressed))
+
+	err = resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, &compressed, RestoreOptions{})
+	assert.NoError(t, err)
+
+	var json strings.Builder
+	err = dbService.Backup(&testUser, &json, BackupOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, testBackupData, json.String())
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	for _, value := range []string{
+		`{"Accounts": [{"UUID": "uuid1"}], "Transactions": [{"UUID": "uuid1", "Date": "2015-11-01"}`,
+		`{"Transactions": [{"UUID": "uuid9", "Date": "2015-11-01"}], "Accounts": [{"UUID": "uuid1"}]}`,
+		`{"Accounts": {}}`,
+		`[]`,
+		`{"Accounts": [{"UUID": "uuid1"}], "Transactions": [{"UUID": "uuid1", "Date": "2015-13-01"}]}`,
+	} {
+		_, err = dbService.Restore(&testUser, strings.NewReader(value), RestoreOptions{})
+		assert.Error(t, err)
+		_, err = dbService.Restore(&testUser, strings.NewReader(value), RestoreOptions{Mode: RestoreMerge})
+		assert.Error(t, err)
+	}
+
+	// Failed restores shouldn't change any data.
+	var json strings.Builder
+	err = dbService.Backup(&testUser, &json, BackupOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, testBackupData, json.String())
+}
@@ -0,0 +1,262 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ErrBackupPassphraseRequired is an error when restoring an encrypted backup without a passphrase.
+var ErrBackupPassphraseRequired = fmt.Errorf("backup is encrypted, a passphrase is required")
+
+// ErrBackupWrongPassphrase is an error when an encrypted backup cannot be decrypted with the provided passphrase.
+var ErrBackupWrongPassphrase = fmt.Errorf("wrong backup passphrase")
+
+// ErrBackupCorrupted is an error when an encrypted backup was modified or truncated.
+var ErrBackupCorrupted = fmt.Errorf("encrypted backup is corrupted or was tampered with")
+
+// encryptedBackupMagic is the header of encrypted backups.
+var encryptedBackupMagic = []byte("VOGONENC")
+
+const (
+	// encryptedBackupVersion is the current version of the encrypted backup format.
+	encryptedBackupVersion = 1
+
+	// Argon2id parameters for new backups.
+	backupKeyTime    = 3
+	backupKeyMemory  = 64 * 1024
+	backupKeyThreads = 4
+	// backupKeyMaxTime, backupKeyMaxMemory and backupKeyMaxThreads limit the resources used to decrypt a backup,
+	// as the parameters are read from the backup before it's authenticated.
+	backupKeyMaxTime    = 2 * backupKeyTime
+	backupKeyMaxMemory  = 2 * backupKeyMemory
+	backupKeyMaxThreads = 2 * backupKeyThreads
+
+	backupSaltSize        = 16
+	backupNoncePrefixSize = chacha20poly1305.NonceSizeX - 8
+	// backupChunkSize is the size of plaintext chunks which are encrypted separately.
+	backupChunkSize = 64 * 1024
+	// encryptedBackupHeaderSize is the size of the header: magic, version, Argon2id time, memory and threads,
+	// salt, nonce prefix and the header authentication tag.
+	encryptedBackupHeaderSize = 8 + 1 + 4 + 4 + 1 + backupSaltSize + backupNoncePrefixSize + chacha20poly1305.Overhead
+)
+
+// backupCipher encrypts or decrypts the chunks of an encrypted backup.
+//
+// An encrypted backup is a header followed by chunks encrypted with XChaCha20-Poly1305,
+// with a key derived from the passphrase using Argon2id.
+// The nonce of each chunk is the nonce prefix from the header followed by the chunk's counter;
+// the header itself is authenticated with counter 0, so that a wrong passphrase is detected before reading any data.
+// All chunks except the last one contain backupChunkSize bytes; the last chunk is marked in its additional data,
+// to detect truncated backups.
+type backupCipher struct {
+	aead        cipher.AEAD
+	noncePrefix []byte
+	counter     uint64
+}
+
+// newBackupCipher derives the key for passphrase and the header parameters.
+func newBackupCipher(passphrase string, time, memory uint32, threads uint8, salt, noncePrefix []byte) (*backupCipher, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, time, memory, threads, chacha20poly1305.KeySize)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &backupCipher{aead: aead, noncePrefix: noncePrefix}, nil
+}
+
+// nonce returns the nonce for the next chunk.
+func (c *backupCipher) nonce() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	copy(nonce, c.noncePrefix)
+	binary.BigEndian.PutUint64(nonce[backupNoncePrefixSize:], c.counter)
+	c.counter++
+	return nonce
+}
+
+// chunkAdditionalData returns the additional data for a chunk.
+func chunkAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encryptedBackupWriter encrypts a backup written into it.
+type encryptedBackupWriter struct {
+	w      io.Writer
+	cipher *backupCipher
+	buffer []byte
+}
+
+// newEncryptedBackupWriter writes the encrypted backup header into w,
+// and returns a writer which encrypts data before writing it into w.
+// Close has to be called to write the last chunk.
+func newEncryptedBackupWriter(w io.Writer, passphrase string) (*encryptedBackupWriter, error) {
+	random := make([]byte, backupSaltSize+backupNoncePrefixSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	salt, noncePrefix := random[:backupSaltSize], random[backupSaltSize:]
+
+	header := make([]byte, 0, encryptedBackupHeaderSize)
+	header = append(header, encryptedBackupMagic...)
+	header = append(header, encryptedBackupVersion)
+	header = binary.BigEndian.AppendUint32(header, backupKeyTime)
+	header = binary.BigEndian.AppendUint32(header, backupKeyMemory)
+	header = append(header, backupKeyThreads)
+	header = append(header, salt...)
+	header = append(header, noncePrefix...)
+
+	c, err := newBackupCipher(passphrase, backupKeyTime, backupKeyMemory, backupKeyThreads, salt, noncePrefix)
+	if err != nil {
+		return nil, err
+	}
+	header = c.aead.Seal(header, c.nonce(), nil, header)
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write encrypted backup header: %w", err)
+	}
+	return &encryptedBackupWriter{w: w, cipher: c, buffer: make([]byte, 0, backupChunkSize)}, nil
+}
+
+// writeChunk encrypts and writes the buffered data.
+func (ew *encryptedBackupWriter) writeChunk(last bool) error {
+	chunk := ew.cipher.aead.Seal(nil, ew.cipher.nonce(), ew.buffer, chunkAdditionalData(last))
+	ew.buffer = ew.buffer[:0]
+	if _, err := ew.w.Write(chunk); err != nil {
+		return fmt.Errorf("failed to write encrypted chunk: %w", err)
+	}
+	return nil
+}
+
+// Write encrypts p and writes it into the underlying writer.
+func (ew *encryptedBackupWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := backupChunkSize - len(ew.buffer)
+		if n > len(p) {
+			n = len(p)
+		}
+		ew.buffer = append(ew.buffer, p[:n]...)
+		p = p[n:]
+		if len(ew.buffer) == backupChunkSize {
+			if err := ew.writeChunk(false); err != nil {
+				return written, err
+			}
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the last chunk.
+// It doesn't close the underlying writer.
+func (ew *encryptedBackupWriter) Close() error {
+	return ew.writeChunk(true)
+}
+
+// encryptedBackupReader decrypts an encrypted backup.
+type encryptedBackupReader struct {
+	r      io.Reader
+	cipher *backupCipher
+	chunk  []byte
+	buffer []byte
+	done   bool
+}
+
+// isEncryptedBackup returns true if r contains an encrypted backup.
+func isEncryptedBackup(r *bufio.Reader) (bool, error) {
+	header, err := r.Peek(len(encryptedBackupMagic))
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read backup header: %w", err)
+	}
+	return bytes.Equal(header, encryptedBackupMagic), nil
+}
+
+// newEncryptedBackupReader reads the encrypted backup header from r,
+// and returns a reader which decrypts data read from r.
+func newEncryptedBackupReader(r io.Reader, passphrase string) (*encryptedBackupReader, error) {
+	if passphrase == "" {
+		return nil, ErrBackupPassphraseRequired
+	}
+
+	header := make([]byte, encryptedBackupHeaderSize)
+	if _, err := io.ReadFull(r, header[:len(encryptedBackupMagic)+1]); err != nil {
+		return nil, fmt.Errorf("%w: cannot read header: %v", ErrBackupCorrupted, err)
+	}
+	if version := header[len(encryptedBackupMagic)]; version != encryptedBackupVersion {
+		return nil, fmt.Errorf("unsupported encrypted backup version %v", version)
+	}
+	if _, err := io.ReadFull(r, header[len(encryptedBackupMagic)+1:]); err != nil {
+		return nil, fmt.Errorf("%w: cannot read header: %v", ErrBackupCorrupted, err)
+	}
+
+	params := header[len(encryptedBackupMagic)+1:]
+	time, memory, threads := binary.BigEndian.Uint32(params[0:4]), binary.BigEndian.Uint32(params[4:8]), params[8]
+	if time == 0 || time > backupKeyMaxTime || memory == 0 || memory > backupKeyMaxMemory || threads == 0 || threads > backupKeyMaxThreads {
+		return nil, fmt.Errorf("%w: invalid key parameters", ErrBackupCorrupted)
+	}
+	salt := params[9 : 9+backupSaltSize]
+	noncePrefix := params[9+backupSaltSize : 9+backupSaltSize+backupNoncePrefixSize]
+
+	c, err := newBackupCipher(passphrase, time, memory, threads, salt, noncePrefix)
+	if err != nil {
+		return nil, err
+	}
+	tagStart := encryptedBackupHeaderSize - chacha20poly1305.Overhead
+	if _, err := c.aead.Open(nil, c.nonce(), header[tagStart:], header[:tagStart]); err != nil {
+		// Cannot distinguish between a wrong passphrase and a modified header.
+		return nil, ErrBackupWrongPassphrase
+	}
+	return &encryptedBackupReader{
+		r:      r,
+		cipher: c,
+		chunk:  make([]byte, backupChunkSize+chacha20poly1305.Overhead),
+	}, nil
+}
+
+// readChunk reads and decrypts the next chunk.
+func (er *encryptedBackupReader) readChunk() error {
+	n, err := io.ReadFull(er.r, er.chunk)
+	last := false
+	if err == io.ErrUnexpectedEOF {
+		last = true
+	} else if err == io.EOF {
+		return fmt.Errorf("%w: backup is truncated", ErrBackupCorrupted)
+	} else if err != nil {
+		return fmt.Errorf("failed to read encrypted chunk: %w", err)
+	}
+
+	er.buffer, err = er.cipher.aead.Open(er.buffer[:0], er.cipher.nonce(), er.chunk[:n], chunkAdditionalData(last))
+	if err != nil {
+		if !last {
+			// A full chunk might be the last one if the backup was truncated right after it.
+			return fmt.Errorf("%w: cannot decrypt chunk %v", ErrBackupCorrupted, er.cipher.counter-1)
+		}
+		return fmt.Errorf("%w: cannot decrypt last chunk", ErrBackupCorrupted)
+	}
+	er.done = last
+	return nil
+}
+
+// Read decrypts data from the underlying reader.
+func (er *encryptedBackupReader) Read(p []byte) (int, error) {
+	for len(er.buffer) == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+		if err := er.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, er.buffer)
+	er.buffer = er.buffer[n:]
+	return n, nil
+}
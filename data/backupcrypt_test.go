@@ -0,0 +1,157 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encryptTestData returns data encrypted with passphrase.
+func encryptTestData(t *testing.T, data []byte, passphrase string) []byte {
+	var encrypted bytes.Buffer
+	w, err := newEncryptedBackupWriter(&encrypted, passphrase)
+	assert.NoError(t, err)
+	_, err = w.Write(data)
+	assert.NoError(t, err)
+	err = w.Close()
+	assert.NoError(t, err)
+	return encrypted.Bytes()
+}
+
+// decryptTestData decrypts data with passphrase.
+func decryptTestData(data []byte, passphrase string) ([]byte, error) {
+	r, err := newEncryptedBackupReader(bytes.NewReader(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncryptDecryptBackup(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	for _, size := range []int{0, 1, backupChunkSize - 1, backupChunkSize, backupChunkSize + 1, 3 * backupChunkSize} {
+		data := make([]byte, size)
+		random.Read(data)
+
+		encrypted := encryptTestData(t, data, "secret")
+		assert.Equal(t, encryptedBackupMagic, encrypted[:len(encryptedBackupMagic)])
+
+		decrypted, err := decryptTestData(encrypted, "secret")
+		assert.NoError(t, err)
+		assert.Equal(t, data, decrypted)
+	}
+}
+
+func TestDecryptBackupErrors(t *testing.T) {
+	data := []byte(strings.Repeat("backup", backupChunkSize/2))
+	encrypted := encryptTestData(t, data, "secret")
+
+	_, err := decryptTestData(encrypted, "")
+	assert.ErrorIs(t, err, ErrBackupPassphraseRequired)
+
+	_, err = decryptTestData(encrypted, "wrong")
+	assert.ErrorIs(t, err, ErrBackupWrongPassphrase)
+
+	tampered := bytes.Clone(encrypted)
+	tampered[len(tampered)-100] ^= 1
+	_, err = decryptTestData(tampered, "secret")
+	assert.ErrorIs(t, err, ErrBackupCorrupted)
+
+	// Truncated after a full chunk.
+	truncated := encrypted[:encryptedBackupHeaderSize+backupChunkSize+16]
+	_, err = decryptTestData(truncated, "secret")
+	assert.ErrorIs(t, err, ErrBackupCorrupted)
+
+	// Truncated in the middle of a chunk.
+	truncated = encrypted[:len(encrypted)-1]
+	_, err = decryptTestData(truncated, "secret")
+	assert.ErrorIs(t, err, ErrBackupCorrupted)
+
+	truncated = encrypted[:encryptedBackupHeaderSize-1]
+	_, err = decryptTestData(truncated, "secret")
+	assert.ErrorIs(t, err, ErrBackupCorrupted)
+
+	// Key parameters are checked before deriving the key.
+	params := len(encryptedBackupMagic) + 1
+	for _, invalid := range []struct {
+		offset int
+		value  []byte
+	}{
+		{offset: params, value: []byte{0, 0, 0, 0}},
+		{offset: params, value: binary.BigEndian.AppendUint32(nil, backupKeyMaxTime+1)},
+		{offset: params + 4, value: binary.BigEndian.AppendUint32(nil, backupKeyMaxMemory+1)},
+		{offset: params + 4, value: []byte{0xff, 0xff, 0xff, 0xff}},
+		{offset: params + 8, value: []byte{0}},
+		{offset: params + 8, value: []byte{backupKeyMaxThreads + 1}},
+	} {
+		modified := bytes.Clone(encrypted)
+		copy(modified[invalid.offset:], invalid.value)
+		_, err = decryptTestData(modified, "secret")
+		assert.ErrorIs(t, err, ErrBackupCorrupted)
+	}
+
+	unsupported := bytes.Clone(encrypted)
+	unsupported[len(encryptedBackupMagic)] = encryptedBackupVersion + 1
+	_, err = decryptTestData(unsupported, "secret")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrBackupWrongPassphrase)
+}
+
+func TestBackupRestoreEncrypted(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		err := resetDb()
+		assert.NoError(t, err)
+
+		_, err = dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
+		assert.NoError(t, err)
+
+		var encrypted bytes.Buffer
+		err = dbService.Backup(&testUser, &encrypted, BackupOptions{Compress: compress, Passphrase: "secret"})
+		assert.NoError(t, err)
+		assert.False(t, bytes.Contains(encrypted.Bytes(), []byte("Orange Bank")))
+
+		err = resetDb()
+		assert.NoError(t, err)
+
+		_, err = dbService.Restore(&testUser, bytes.NewReader(encrypted.Bytes()), RestoreOptions{})
+		assert.ErrorIs(t, err, ErrBackupPassphraseRequired)
+		_, err = dbService.Restore(&testUser, bytes.NewReader(encrypted.Bytes()), RestoreOptions{Passphrase: "wrong"})
+		assert.ErrorIs(t, err, ErrBackupWrongPassphrase)
+
+		_, err = dbService.Restore(&testUser, bytes.NewReader(encrypted.Bytes()), RestoreOptions{Passphrase: "secret"})
+		assert.NoError(t, err)
+
+		var json strings.Builder
+		err = dbService.Backup(&testUser, &json, BackupOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, testBackupData, json.String())
+	}
+}
+
+func TestRestoreEncryptedTampered(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	var encrypted bytes.Buffer
+	err = dbService.Backup(&testUser, &encrypted, BackupOptions{Passphrase: "secret"})
+	assert.NoError(t, err)
+
+	tampered := encrypted.Bytes()
+	tampered[len(tampered)-1] ^= 1
+	_, err = dbService.Restore(&testUser, bytes.NewReader(tampered), RestoreOptions{Mode: RestoreMerge, Passphrase: "secret"})
+	assert.ErrorIs(t, err, ErrBackupCorrupted)
+
+	// Failed restores shouldn't change any data.
+	var json strings.Builder
+	err = dbService.Backup(&testUser, &json, BackupOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, testBackupData, json.String())
+}
@@ -0,0 +1,285 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// BudgetPeriodWeekly is a budget for every week, starting on Monday.
+	BudgetPeriodWeekly = iota
+	// BudgetPeriodMonthly is a budget for every calendar month.
+	BudgetPeriodMonthly
+	// BudgetPeriodQuarterly is a budget for every calendar quarter.
+	BudgetPeriodQuarterly
+	// BudgetPeriodYearly is a budget for every calendar year.
+	BudgetPeriodYearly
+)
+
+// Budget is a spending limit for transactions with a set of tags.
+type Budget struct {
+	UUID string
+	Name string
+	// Tags is the tag set of this budget; transactions are counted if they have all of these tags.
+	// An empty tag set counts all expenses.
+	Tags     []string
+	Currency string
+	// Amount is the limit for every period.
+	Amount int64
+	Period int
+	// Rollover adds the unspent amount of a period to the next period.
+	Rollover bool
+	// StartDate is the date when the budget starts; rollover is accumulated from this date.
+	// An empty value means there is no start date.
+	StartDate string
+}
+
+// BudgetPeriod is a date range of a Budget.
+type BudgetPeriod struct {
+	From string
+	To   string
+}
+
+// encode serializes a Budget.
+func (budget *Budget) encode() ([]byte, error) {
+	var value bytes.Buffer
+	if err := gob.NewEncoder(&value).Encode(budget); err != nil {
+		return nil, err
+	}
+	return value.Bytes(), nil
+}
+
+// decode deserializes a Budget.
+func (budget *Budget) decode(val []byte) error {
+	return gob.NewDecoder(bytes.NewBuffer(val)).Decode(budget)
+}
+
+// normalize validates budget, reformats the start date and sorts/deduplicates tags.
+func (budget *Budget) normalize() error {
+	switch budget.Period {
+	case BudgetPeriodWeekly, BudgetPeriodMonthly, BudgetPeriodQuarterly, BudgetPeriodYearly:
+	default:
+		return fmt.Errorf("budget %v has unsupported period %v", budget.Name, budget.Period)
+	}
+	if budget.Currency == "" {
+		return fmt.Errorf("budget %v has no currency", budget.Name)
+	}
+	if budget.Amount <= 0 {
+		return fmt.Errorf("budget %v has a non-positive amount %v", budget.Name, formatAmount(budget.Amount))
+	}
+	if budget.StartDate != "" {
+		date, err := time.Parse(inputDateFormat, budget.StartDate)
+		if err != nil {
+			return fmt.Errorf("cannot parse date %v: %w", budget.StartDate, err)
+		}
+		budget.StartDate = date.Format(dateFormat)
+	}
+	budget.Tags = normalizeTags(budget.Tags)
+	return nil
+}
+
+// periodStart returns the start date of the period containing date.
+func (budget *Budget) periodStart(date time.Time) time.Time {
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	switch budget.Period {
+	case BudgetPeriodWeekly:
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	case BudgetPeriodMonthly:
+		return date.AddDate(0, 0, 1-date.Day())
+	case BudgetPeriodQuarterly:
+		return time.Date(date.Year(), (date.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nextPeriodStart returns the start date of the period after the period starting on start.
+func (budget *Budget) nextPeriodStart(start time.Time) time.Time {
+	switch budget.Period {
+	case BudgetPeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case BudgetPeriodMonthly:
+		return start.AddDate(0, 1, 0)
+	case BudgetPeriodQuarterly:
+		return start.AddDate(0, 3, 0)
+	default:
+		return start.AddDate(1, 0, 0)
+	}
+}
+
+// Periods returns all periods of budget which include dates between from and to (inclusive).
+// Periods before the budget StartDate are not included.
+func (budget *Budget) Periods(from, to string) ([]BudgetPeriod, error) {
+	if budget.StartDate != "" && budget.StartDate > from {
+		from = budget.StartDate
+	}
+	fromDate, err := time.Parse(inputDateFormat, from)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse date %v: %w", from, err)
+	}
+	toDate, err := time.Parse(inputDateFormat, to)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse date %v: %w", to, err)
+	}
+
+	periods := make([]BudgetPeriod, 0)
+	for start := budget.periodStart(fromDate); !start.After(toDate); {
+		next := budget.nextPeriodStart(start)
+		periods = append(periods, BudgetPeriod{
+			From: start.Format(dateFormat),
+			To:   next.AddDate(0, 0, -1).Format(dateFormat),
+		})
+		start = next
+	}
+	return periods, nil
+}
+
+// Matches returns true if tags contain all tags of budget.
+func (budget *Budget) Matches(tags []string) bool {
+	for _, budgetTag := range budget.Tags {
+		found := false
+		for _, tag := range tags {
+			if tag == budgetTag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// saveBudget saves budget and adds it to the index.
+func (s *DBService) saveBudget(user *User, budget *Budget) error {
+	value, err := budget.encode()
+	if err != nil {
+		return fmt.Errorf("cannot encode budget: %w", err)
+	}
+
+	if err := s.addReferencedKey([]byte(user.createBudgetKeyPrefix()), []byte(budget.UUID), false); err != nil {
+		return fmt.Errorf("cannot add budget to index: %w", err)
+	}
+
+	return s.db.Put(user.createBudgetKey(budget), value)
+}
+
+// CreateBudget creates and saves the specified budget.
+// It generates and sets the ID for the new budget.
+func (s *DBService) CreateBudget(user *User, budget *Budget) error {
+	if err := budget.normalize(); err != nil {
+		return err
+	}
+	budget.UUID = uuid.NewString()
+
+	return s.update(user, func(s *DBService) error {
+		return s.saveBudget(user, budget)
+	})
+}
+
+// UpdateBudget saves an already existing budget.
+// If the budget doesn't exist, it returns an error.
+func (s *DBService) UpdateBudget(user *User, budget *Budget) error {
+	if err := budget.normalize(); err != nil {
+		return err
+	}
+	return s.update(user, func(s *DBService) error {
+		key := user.createBudgetKey(budget)
+
+		exists, err := s.db.Has(key)
+		if err != nil {
+			return fmt.Errorf("cannot check if budget exists %v: %w", string(key), err)
+		} else if !exists {
+			return fmt.Errorf("cannot update budget %v if it doesn't exist", string(key))
+		}
+
+		return s.saveBudget(user, budget)
+	})
+}
+
+// getBudget returns a Budget by its UUID.
+// If the Budget doesn't exist, it returns nil.
+func (s *DBService) getBudget(user *User, budgetUUID string) (*Budget, error) {
+	key := user.createBudgetKeyFromUUID(budgetUUID)
+
+	value, err := s.db.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget %v: %w", string(key), err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	budget := &Budget{}
+	if err := budget.decode(value); err != nil {
+		return nil, fmt.Errorf("failed to read value for budget %v: %w", string(key), err)
+	}
+	return budget, nil
+}
+
+// GetBudget returns a Budget by its UUID.
+// If the Budget doesn't exist, it returns nil.
+func (s *DBService) GetBudget(user *User, budgetUUID string) (*Budget, error) {
+	var budget *Budget
+	err := s.view(user, func() error {
+		var err error
+		budget, err = s.getBudget(user, budgetUUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
+
+// GetBudgets returns all budgets for user.
+func (s *DBService) GetBudgets(user *User) ([]*Budget, error) {
+	budgets := make([]*Budget, 0)
+	err := s.view(user, func() error {
+		budgetUUIDs, err := s.getReferencedKeys([]byte(user.createBudgetKeyPrefix()))
+		if err != nil {
+			return fmt.Errorf("cannot get budget UUIDs for user: %w", err)
+		}
+
+		for _, budgetUUID := range budgetUUIDs {
+			budget, err := s.getBudget(user, string(budgetUUID))
+			if err != nil {
+				return err
+			}
+			if budget == nil {
+				continue
+			}
+			budgets = append(budgets, budget)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+// DeleteBudget deletes a budget by its UUID.
+// If the budget doesn't exist, it returns an error.
+func (s *DBService) DeleteBudget(user *User, budgetUUID string) error {
+	key := user.createBudgetKeyFromUUID(budgetUUID)
+	return s.update(user, func(s *DBService) error {
+		exists, err := s.db.Has(key)
+		if err != nil {
+			return fmt.Errorf("cannot check if budget exists %v: %w", budgetUUID, err)
+		} else if !exists {
+			return fmt.Errorf("cannot delete budget %v because it doesn't exist", budgetUUID)
+		}
+
+		if err := s.db.Delete(key); err != nil {
+			return fmt.Errorf("cannot delete budget %v: %w", budgetUUID, err)
+		}
+
+		return s.deleteReferencedKey([]byte(user.createBudgetKeyPrefix()), []byte(budgetUUID))
+	})
+}
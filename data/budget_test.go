@@ -0,0 +1,174 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestBudget() Budget {
+	return Budget{
+		Name:      "Groceries",
+		Tags:      []string{"Groceries", "", "Food", "Groceries"},
+		Currency:  "EUR",
+		Amount:    60000,
+		Period:    BudgetPeriodMonthly,
+		Rollover:  true,
+		StartDate: "2015-1-5",
+	}
+}
+
+func TestBudgetPeriods(t *testing.T) {
+	budget := Budget{Period: BudgetPeriodMonthly}
+	periods, err := budget.Periods("2015-01-15", "2015-03-01")
+	assert.NoError(t, err)
+	assert.Equal(t, []BudgetPeriod{
+		{From: "2015-01-01", To: "2015-01-31"},
+		{From: "2015-02-01", To: "2015-02-28"},
+		{From: "2015-03-01", To: "2015-03-31"},
+	}, periods)
+
+	budget = Budget{Period: BudgetPeriodWeekly}
+	periods, err = budget.Periods("2015-11-01", "2015-11-03")
+	assert.NoError(t, err)
+	assert.Equal(t, []BudgetPeriod{
+		{From: "2015-10-26", To: "2015-11-01"},
+		{From: "2015-11-02", To: "2015-11-08"},
+	}, periods)
+
+	budget = Budget{Period: BudgetPeriodQuarterly, StartDate: "2015-05-10"}
+	periods, err = budget.Periods("2015-01-01", "2015-12-31")
+	assert.NoError(t, err)
+	assert.Equal(t, []BudgetPeriod{
+		{From: "2015-04-01", To: "2015-06-30"},
+		{From: "2015-07-01", To: "2015-09-30"},
+		{From: "2015-10-01", To: "2015-12-31"},
+	}, periods)
+
+	budget = Budget{Period: BudgetPeriodYearly}
+	periods, err = budget.Periods("2015-06-01", "2016-01-01")
+	assert.NoError(t, err)
+	assert.Equal(t, []BudgetPeriod{
+		{From: "2015-01-01", To: "2015-12-31"},
+		{From: "2016-01-01", To: "2016-12-31"},
+	}, periods)
+
+	periods, err = budget.Periods("2016-01-01", "2015-06-01")
+	assert.NoError(t, err)
+	assert.Empty(t, periods)
+
+	_, err = budget.Periods("2015-13-01", "2016-01-01")
+	assert.Error(t, err)
+}
+
+func TestBudgetMatches(t *testing.T) {
+	budget := Budget{Tags: []string{"Food", "Groceries"}}
+	assert.True(t, budget.Matches([]string{"Food", "Groceries", "Weekly"}))
+	assert.False(t, budget.Matches([]string{"Groceries"}))
+	assert.False(t, budget.Matches(nil))
+
+	budget = Budget{}
+	assert.True(t, budget.Matches([]string{"Groceries"}))
+	assert.True(t, budget.Matches(nil))
+}
+
+func TestCreateBudget(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	budget := createTestBudget()
+	err = dbService.CreateBudget(&testUser, &budget)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, budget.UUID)
+	assert.Equal(t, []string{"Food", "Groceries"}, budget.Tags)
+	assert.Equal(t, "2015-01-05", budget.StartDate)
+
+	budgets, err := dbService.GetBudgets(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Budget{&budget}, budgets)
+
+	dbBudget, err := dbService.GetBudget(&testUser, budget.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, &budget, dbBudget)
+}
+
+func TestCreateInvalidBudget(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	budget := createTestBudget()
+	budget.Currency = ""
+	err = dbService.CreateBudget(&testUser, &budget)
+	assert.Error(t, err)
+
+	budget = createTestBudget()
+	budget.Amount = 0
+	err = dbService.CreateBudget(&testUser, &budget)
+	assert.Error(t, err)
+
+	budget = createTestBudget()
+	budget.Period = 10
+	err = dbService.CreateBudget(&testUser, &budget)
+	assert.Error(t, err)
+
+	budget = createTestBudget()
+	budget.StartDate = "2015-13-01"
+	err = dbService.CreateBudget(&testUser, &budget)
+	assert.Error(t, err)
+
+	budgets, err := dbService.GetBudgets(&testUser)
+	assert.NoError(t, err)
+	assert.Empty(t, budgets)
+}
+
+func TestUpdateBudget(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	budget := createTestBudget()
+	err = dbService.CreateBudget(&testUser, &budget)
+	assert.NoError(t, err)
+
+	budget.Amount = 50000
+	budget.Rollover = false
+	budget.Tags = []string{"Groceries"}
+	saveBudget := budget
+	err = dbService.UpdateBudget(&testUser, &saveBudget)
+	assert.NoError(t, err)
+
+	budgets, err := dbService.GetBudgets(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Budget{&budget}, budgets)
+
+	missingBudget := createTestBudget()
+	missingBudget.UUID = "missing"
+	err = dbService.UpdateBudget(&testUser, &missingBudget)
+	assert.Error(t, err)
+
+	budgets, err = dbService.GetBudgets(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Budget{&budget}, budgets)
+}
+
+func TestDeleteBudget(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	budget := createTestBudget()
+	err = dbService.CreateBudget(&testUser, &budget)
+	assert.NoError(t, err)
+
+	err = dbService.DeleteBudget(&testUser, budget.UUID)
+	assert.NoError(t, err)
+
+	err = dbService.DeleteBudget(&testUser, budget.UUID)
+	assert.Error(t, err)
+
+	budgets, err := dbService.GetBudgets(&testUser)
+	assert.NoError(t, err)
+	assert.Empty(t, budgets)
+
+	dbBudget, err := dbService.GetBudget(&testUser, budget.UUID)
+	assert.NoError(t, err)
+	assert.Nil(t, dbBudget)
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduleCleanup schedules an asynchronous repair of user's indexes.
+// It should be called when a read finds an index entry referencing a missing item.
+// The repair is started in a separate goroutine, as it needs a write lock and reads are done while holding a read lock.
+func (s *DBService) scheduleCleanup(user *User) {
+	s.cleanupLock.Lock()
+	defer s.cleanupLock.Unlock()
+
+	if s.closed {
+		return
+	}
+	if _, ok := s.cleanupUsers[user.UUID]; ok {
+		// Cleanup is already scheduled.
+		return
+	}
+	cleanupUser := *user
+	s.cleanupUsers[user.UUID] = &cleanupUser
+	s.cleanupRunning.Add(1)
+	go s.cleanup(&cleanupUser)
+}
+
+// cleanup removes index entries referencing missing items and empty parent index keys for user.
+func (s *DBService) cleanup(user *User) {
+	defer s.cleanupRunning.Done()
+
+	report := &FsckReport{}
+	err := s.update(user, func(s *DBService) error {
+		s.cleanupLock.Lock()
+		delete(s.cleanupUsers, user.UUID)
+		s.cleanupLock.Unlock()
+
+		if _, err := s.checkAccountIndex(user, true, report); err != nil {
+			return err
+		}
+		indexed, misplaced, err := s.checkTransactionIndex(user, true, report)
+		if err != nil {
+			return err
+		}
+		if err := s.reindexTransactions(user, misplaced, indexed); err != nil {
+			return err
+		}
+		transactions, err := s.getAllTransactions(user)
+		if err != nil {
+			return err
+		}
+		return s.checkSecondaryIndexes(user, transactions, true, report)
+	})
+	if err != nil {
+		log.WithField("user", user.UUID).WithError(err).Error("Failed to clean up index")
+	}
+	for _, problem := range report.Problems {
+		log.WithField("user", user.UUID).WithField("fixed", problem).Info("Cleaned up index")
+	}
+}
+
+// stopCleanup waits for all scheduled cleanups to complete and prevents new cleanups from being scheduled.
+func (s *DBService) stopCleanup() {
+	s.cleanupLock.Lock()
+	s.closed = true
+	s.cleanupLock.Unlock()
+
+	s.cleanupRunning.Wait()
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanupMissingTransaction(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20", Components: []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 100}}}
+	transaction2 := &Transaction{Description: "t2", Date: "2020-01-01", Components: []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 200}}}
+	for _, transaction := range []*Transaction{transaction1, transaction2} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+	err = dbService.db.Delete(testUser.createTransactionKey(transaction2))
+	assert.NoError(t, err)
+
+	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction1}, transactions)
+
+	dbService.cleanupRunning.Wait()
+
+	years, err := dbService.getReferencedKeys([]byte(testUser.createTransactionKeyPrefix()))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{0x07, 0xe3}}, years)
+	_, monthsIndexKey, _, _ := testUser.createTransactionIndexPath(2020, 1, 1)
+	exists, err := dbService.db.Has(monthsIndexKey)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+	assert.Empty(t, dbService.cleanupUsers)
+}
+
+func TestCleanupMissingAccount(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	err = dbService.db.Delete(testUser.createAccountKeyFromUUID(testAccount1.UUID))
+	assert.NoError(t, err)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Account{&testAccount2}, accounts)
+
+	dbService.cleanupRunning.Wait()
+
+	assertIndexEquals(t, testUser.createAccountKeyPrefix(), testAccount2.UUID)
+}
+
+func TestCleanupAfterClose(t *testing.T) {
+	service := newDBService(nil)
+	service.stopCleanup()
+
+	service.scheduleCleanup(&testUser)
+	assert.Empty(t, service.cleanupUsers)
+}
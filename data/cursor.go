@@ -0,0 +1,127 @@
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidCursor is an error when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = fmt.Errorf("invalid cursor")
+
+// transactionCursorVersion is the version of the transaction cursor format.
+const transactionCursorVersion = 1
+
+// transactionCursor is the position of a transaction in the date index.
+// Iteration resumes after the transaction referenced by the cursor.
+type transactionCursor struct {
+	year     uint16
+	month    uint8
+	day      uint8
+	position uint32
+	// transactionUUID is used to find the transaction if its position has changed.
+	transactionUUID string
+}
+
+// encode serializes the cursor into an opaque string.
+func (cursor *transactionCursor) encode() string {
+	value := make([]byte, 9, 9+len(cursor.transactionUUID))
+	value[0] = transactionCursorVersion
+	binary.BigEndian.PutUint16(value[1:3], cursor.year)
+	value[3], value[4] = cursor.month, cursor.day
+	binary.BigEndian.PutUint32(value[5:9], cursor.position)
+	value = append(value, cursor.transactionUUID...)
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// decodeTransactionCursor deserializes a cursor created by encode.
+func decodeTransactionCursor(value string) (*transactionCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if len(decoded) < 9 || decoded[0] != transactionCursorVersion {
+		return nil, fmt.Errorf("%w: unsupported format", ErrInvalidCursor)
+	}
+	return &transactionCursor{
+		year:            binary.BigEndian.Uint16(decoded[1:3]),
+		month:           decoded[3],
+		day:             decoded[4],
+		position:        binary.BigEndian.Uint32(decoded[5:9]),
+		transactionUUID: string(decoded[9:]),
+	}, nil
+}
+
+// date returns the date of the transaction referenced by the cursor, in the Transaction Date format.
+func (cursor *transactionCursor) date() string {
+	return fmt.Sprintf("%04d-%02d-%02d", cursor.year, cursor.month, cursor.day)
+}
+
+// resolvePosition returns the current position of the cursor's transaction in transactionUUIDs, the list of its day.
+// If the transaction was deleted, the position where it used to be is returned.
+func (cursor *transactionCursor) resolvePosition(transactionUUIDs [][]byte) int {
+	position := int(cursor.position)
+	if position < len(transactionUUIDs) && string(transactionUUIDs[position]) == cursor.transactionUUID {
+		return position
+	}
+	for i := range transactionUUIDs {
+		if string(transactionUUIDs[i]) == cursor.transactionUUID {
+			return i
+		}
+	}
+	if position > len(transactionUUIDs) {
+		return len(transactionUUIDs)
+	}
+	return position
+}
+
+// createTransactionCursor creates a cursor for transaction, so that iteration can resume after it.
+func (s *DBService) createTransactionCursor(user *User, transaction *Transaction) (*transactionCursor, error) {
+	date, err := time.Parse(inputDateFormat, transaction.Date)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse date %v: %w", transaction.Date, err)
+	}
+	cursor := &transactionCursor{
+		year:            uint16(date.Year()),
+		month:           uint8(date.Month()),
+		day:             uint8(date.Day()),
+		transactionUUID: transaction.UUID,
+	}
+	_, _, _, indexKey := user.createTransactionIndexPath(cursor.year, cursor.month, cursor.day)
+	transactionUUIDs, err := s.getReferencedKeys(indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed get transactions index: %w", err)
+	}
+	for i := range transactionUUIDs {
+		if bytes.Equal(transactionUUIDs[i], []byte(transaction.UUID)) {
+			cursor.position = uint32(i)
+			break
+		}
+	}
+	return cursor, nil
+}
+
+// transactionReferenceFilter returns a function which returns true if a reference to a transaction
+// from a secondary index comes after the cursor's transaction.
+func (s *DBService) transactionReferenceFilter(user *User, cursor *transactionCursor) (func(transactionReference) bool, error) {
+	_, _, _, indexKey := user.createTransactionIndexPath(cursor.year, cursor.month, cursor.day)
+	transactionUUIDs, err := s.getReferencedKeys(indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed get transactions index: %w", err)
+	}
+	cursorDate := cursor.date()
+	cursorPosition := cursor.resolvePosition(transactionUUIDs)
+	positions := make(map[string]int, len(transactionUUIDs))
+	for i := range transactionUUIDs {
+		positions[string(transactionUUIDs[i])] = i
+	}
+	return func(reference transactionReference) bool {
+		if reference.Date != cursorDate {
+			return reference.Date < cursorDate
+		}
+		position, ok := positions[reference.UUID]
+		return ok && position < cursorPosition
+	}, nil
+}
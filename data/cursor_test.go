@@ -0,0 +1,121 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionCursorEncodeDecode(t *testing.T) {
+	cursor := &transactionCursor{year: 2019, month: 3, day: 20, position: 2, transactionUUID: "uuid1"}
+
+	decoded, err := decodeTransactionCursor(cursor.encode())
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	for _, value := range []string{"not a cursor!", "", "AQ"} {
+		decoded, err = decodeTransactionCursor(value)
+		assert.True(t, errors.Is(err, ErrInvalidCursor))
+		assert.Nil(t, decoded)
+	}
+}
+
+func TestGetTransactionsPage(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20"}
+	transaction2 := &Transaction{Description: "t2", Date: "2019-03-20"}
+	transaction3 := &Transaction{Description: "t3", Date: "2019-03-21"}
+	transaction4 := &Transaction{Description: "t4", Date: "2019-04-01"}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3, transaction4} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	page, err := dbService.GetTransactionsPage(&testUser, GetTransactionOptions{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction4, transaction3}, page.Transactions)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// Transactions added after the first page was returned don't shift the next page.
+	transaction5 := &Transaction{Description: "t5", Date: "2019-03-20"}
+	transaction6 := &Transaction{Description: "t6", Date: "2019-05-01"}
+	for _, transaction := range []*Transaction{transaction5, transaction6} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	page, err = dbService.GetTransactionsPage(&testUser, GetTransactionOptions{Cursor: page.NextCursor, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction5, transaction2}, page.Transactions)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = dbService.GetTransactionsPage(&testUser, GetTransactionOptions{Cursor: page.NextCursor, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction1}, page.Transactions)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = dbService.GetTransactionsPage(&testUser, GetTransactionOptions{Cursor: "invalid", Limit: 2})
+	assert.True(t, errors.Is(err, ErrInvalidCursor))
+	assert.Nil(t, page)
+}
+
+func TestGetTransactionsPageDeletedCursor(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20"}
+	transaction2 := &Transaction{Description: "t2", Date: "2019-03-20"}
+	transaction3 := &Transaction{Description: "t3", Date: "2019-03-20"}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	page, err := dbService.GetTransactionsPage(&testUser, GetTransactionOptions{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction3}, page.Transactions)
+
+	// Deleting the last transaction from the previous page shouldn't skip any transactions.
+	err = dbService.DeleteTransaction(&testUser, transaction3.UUID, false)
+	assert.NoError(t, err)
+
+	page, err = dbService.GetTransactionsPage(&testUser, GetTransactionOptions{Cursor: page.NextCursor, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction2, transaction1}, page.Transactions)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestGetTransactionsPageFiltered(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20", Tags: []string{"x"}}
+	transaction2 := &Transaction{Description: "t2", Date: "2019-03-20", Tags: []string{"y"}}
+	transaction3 := &Transaction{Description: "t3", Date: "2019-03-20", Tags: []string{"x"}}
+	transaction4 := &Transaction{Description: "t4", Date: "2019-03-21", Tags: []string{"x"}}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3, transaction4} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	filterOptions := TransactionFilterOptions{FilterTags: []string{"x"}}
+	page, err := dbService.GetTransactionsPage(&testUser, GetTransactionOptions{Limit: 2, TransactionFilterOptions: filterOptions})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction4, transaction3}, page.Transactions)
+
+	transaction5 := &Transaction{Description: "t5", Date: "2019-03-20", Tags: []string{"x"}}
+	err = dbService.CreateTransaction(&testUser, transaction5)
+	assert.NoError(t, err)
+
+	page, err = dbService.GetTransactionsPage(&testUser, GetTransactionOptions{
+		Cursor:                   page.NextCursor,
+		Limit:                    2,
+		TransactionFilterOptions: filterOptions,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction1}, page.Transactions)
+	assert.Empty(t, page.NextCursor)
+}
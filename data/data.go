@@ -1,6 +1,7 @@
 package data
 
 import (
+	"fmt"
 	golog "log"
 	"os"
 	"path"
@@ -24,10 +25,48 @@ func DefaultOptions() pogreb.Options {
 }
 
 // DBService provides services for reading and writing structs in the database.
+// Inside update, db is a batch which is committed when the update completes.
 type DBService struct {
-	db *pogreb.DB
+	db      store
+	storage *pogreb.DB
 
-	userLock sync.RWMutex
+	*dbLocks
+}
+
+// dbLocks is the synchronization state shared by a DBService and the batches created by update.
+//
+// Items of each user are protected by that user's lock, so that different users don't block each other.
+// User records, server config and indexes shared by all users are protected by systemLock.
+// To prevent deadlocks, a user lock should be acquired before systemLock, and only one user lock can be held at a time.
+type dbLocks struct {
+	userLocksLock sync.Mutex
+	userLocks     map[string]*sync.RWMutex
+	// userVersions counts the updates of each user, so that reads done in several views can detect changes.
+	userVersions map[string]uint64
+	systemLock   sync.RWMutex
+
+	// commitLock protects the journal, which is shared by all updates.
+	commitLock sync.Mutex
+	// journalPending is set when a commit failed, and its journal might have to be replayed before the next commit.
+	journalPending bool
+
+	cleanupLock    sync.Mutex
+	cleanupUsers   map[string]*User
+	cleanupRunning sync.WaitGroup
+	closed         bool
+}
+
+// newDBService creates a DBService for storage.
+func newDBService(storage *pogreb.DB) *DBService {
+	return &DBService{
+		db:      committedStore{storage},
+		storage: storage,
+		dbLocks: &dbLocks{
+			userLocks:    make(map[string]*sync.RWMutex),
+			userVersions: make(map[string]uint64),
+			cleanupUsers: make(map[string]*User),
+		},
+	}
 }
 
 // Open opens the database with options and returns a DBService instance.
@@ -42,12 +81,21 @@ func Open(options pogreb.Options) (*DBService, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &DBService{db: db}, nil
+	if err := recoverJournal(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	service := newDBService(db)
+	if err := service.buildSecondaryIndexes(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return service, nil
 }
 
 // GC deletes expired items and attempts to perform a database cleanup.
 func (service *DBService) GC() {
-	result, err := service.db.Compact()
+	result, err := service.storage.Compact()
 	if err != nil {
 		log.WithError(err).Error("Cleanup failed")
 	}
@@ -60,32 +108,131 @@ func (service *DBService) GC() {
 }
 
 // Close closes the underlying database.
+// Scheduled cleanups are completed before closing.
 func (service *DBService) Close() {
 	log.Info("Closing database")
-	if service != nil && service.db != nil {
+	if service != nil {
+		service.stopCleanup()
+	}
+	if service != nil && service.storage != nil {
 		service.GC()
-		err := service.db.Close()
+		err := service.storage.Close()
 		if err != nil {
 			log.Fatal(err)
 		}
 		service.db = nil
+		service.storage = nil
 	}
 }
 
-// view will acquire a read lock on the database and execute txn.
-// Returns the error returned by txn.
-func (service *DBService) view(txn func() error) error {
-	service.userLock.RLock()
-	defer service.userLock.RUnlock()
+// lock returns the lock protecting items of user.
+// If user is nil, returns the lock protecting items shared by all users.
+func (service *DBService) lock(user *User) *sync.RWMutex {
+	if user == nil {
+		return &service.systemLock
+	}
 
-	return txn()
+	service.userLocksLock.Lock()
+	defer service.userLocksLock.Unlock()
+
+	lock, ok := service.userLocks[user.UUID]
+	if !ok {
+		lock = &sync.RWMutex{}
+		service.userLocks[user.UUID] = lock
+	}
+	return lock
 }
 
-// update will acquire a write lock on the database and execute txn.
+// version returns the version of items of user, which changes every time they're updated.
+func (service *DBService) version(user *User) uint64 {
+	service.userLocksLock.Lock()
+	defer service.userLocksLock.Unlock()
+
+	return service.userVersions[user.UUID]
+}
+
+// nextVersion changes the version of items of user.
+// This method should be called while holding the write lock of user.
+func (service *DBService) nextVersion(user *User) {
+	service.userLocksLock.Lock()
+	defer service.userLocksLock.Unlock()
+
+	service.userVersions[user.UUID]++
+}
+
+// view will acquire a read lock on items of user and execute txn.
+// If user is nil, a read lock on user records, server config and shared indexes is acquired instead.
 // Returns the error returned by txn.
-func (service *DBService) update(txn func() error) error {
-	service.userLock.Lock()
-	defer service.userLock.Unlock()
+func (service *DBService) view(user *User, txn func() error) error {
+	lock := service.lock(user)
+	lock.RLock()
+	defer lock.RUnlock()
 
 	return txn()
 }
+
+// pendingSize returns the size of writes pending in the current update, or 0 if called outside of an update.
+func (service *DBService) pendingSize() int {
+	if b, ok := service.db.(*batch); ok {
+		return b.size
+	}
+	return 0
+}
+
+// update will acquire a write lock on items of user and execute txn.
+// If user is nil, a write lock on user records, server config and shared indexes is acquired instead.
+// All writes done by txn through its DBService are collected in a batch and committed only if txn succeeds;
+// if txn returns an error, its writes are discarded, except writes which were already saved by commitPending.
+// If a commit fails after its journal was saved, the journal is replayed before the next commit.
+// Returns the error returned by txn or by the commit.
+func (service *DBService) update(user *User, txn func(s *DBService) error) error {
+	lock := service.lock(user)
+	lock.Lock()
+	defer lock.Unlock()
+	if user != nil {
+		// Writes might be committed even if txn fails.
+		defer service.nextVersion(user)
+	}
+
+	b := newBatch(service.storage)
+	if err := txn(&DBService{db: b, storage: service.storage, dbLocks: service.dbLocks}); err != nil {
+		return err
+	}
+
+	return service.commitBatch(b)
+}
+
+// commitBatch commits the writes from b.
+// A previous commit which failed after its journal was saved is completed first.
+func (service *DBService) commitBatch(b *batch) error {
+	service.commitLock.Lock()
+	defer service.commitLock.Unlock()
+	if service.journalPending {
+		// Complete the failed commit, so that its journal is not overwritten by this commit.
+		if err := recoverJournal(service.storage); err != nil {
+			return fmt.Errorf("cannot complete previous commit: %w", err)
+		}
+		service.journalPending = false
+	}
+	if err := commit(service.storage, b); err != nil {
+		service.journalPending = true
+		return err
+	}
+	return nil
+}
+
+// commitPending commits the writes pending in the current update, and continues the update with an empty batch.
+// This keeps the memory used by large updates bounded, but committed writes are kept even if the update fails later;
+// it should only be used by updates which can be safely repeated.
+// Does nothing if called outside of an update.
+func (service *DBService) commitPending() error {
+	b, ok := service.db.(*batch)
+	if !ok {
+		return nil
+	}
+	if err := service.commitBatch(b); err != nil {
+		return err
+	}
+	b.reset()
+	return nil
+}
@@ -3,7 +3,9 @@ package data
 import (
 	"bytes"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/akrylysov/pogreb"
 	"github.com/akrylysov/pogreb/fs"
@@ -29,6 +31,7 @@ var dbService *DBService
 
 func resetDb() (err error) {
 	if dbService != nil {
+		dbService.cleanupRunning.Wait()
 		it := dbService.db.Items()
 		for {
 			k, _, err := it.Next()
@@ -105,3 +108,123 @@ func assertIndexEquals(t *testing.T, prefix string, expectKeys ...string) {
 	}
 	assert.ElementsMatch(t, expectKeys, indexValues)
 }
+
+func TestUpdateDifferentUsersInParallel(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	otherUser := User{UUID: "uuid12"}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blockedDone := make(chan error)
+	go func() {
+		blockedDone <- dbService.update(&testUser, func(s *DBService) error {
+			close(started)
+			<-release
+			return s.db.Put([]byte("k1"), []byte("v1"))
+		})
+	}()
+	<-started
+
+	// Writes of another user and user records shouldn't wait for testUser's update to complete.
+	done := make(chan error)
+	go func() {
+		if err := dbService.CreateAccount(&otherUser, &Account{Name: "a2"}); err != nil {
+			done <- err
+			return
+		}
+		done <- dbService.SaveUser(NewUser("user02"))
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "update of another user is blocked")
+	}
+
+	close(release)
+	assert.NoError(t, <-blockedDone)
+
+	accounts, err := dbService.GetAccounts(&otherUser)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 1)
+}
+
+func TestUpdateSameUserSerialized(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blockedDone := make(chan error)
+	go func() {
+		blockedDone <- dbService.update(&testUser, func(s *DBService) error {
+			close(started)
+			<-release
+			return s.db.Put([]byte("k1"), []byte("v1"))
+		})
+	}()
+	<-started
+
+	done := make(chan error)
+	go func() {
+		done <- dbService.CreateAccount(&testUser, &Account{Name: "a2"})
+	}()
+	select {
+	case <-done:
+		assert.Fail(t, "update of the same user is not blocked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-blockedDone)
+	assert.NoError(t, <-done)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 1)
+}
+
+func TestSaveUserConcurrentRename(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	users := []*User{NewUser("user01"), NewUser("user02")}
+	for _, user := range users {
+		err = dbService.SaveUser(user)
+		assert.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(users))
+	for i := range users {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := users[i].SetUsername("user03"); err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = dbService.SaveUser(users[i])
+		}(i)
+	}
+	wg.Wait()
+
+	// Only one rename should succeed.
+	renamed := 0
+	for _, err := range errs {
+		if err == nil {
+			renamed++
+		} else {
+			assert.ErrorIs(t, err, ErrUserAlreadyExists)
+		}
+	}
+	assert.Equal(t, 1, renamed)
+
+	savedUsers, err := getAllUsers(dbService)
+	assert.NoError(t, err)
+	assert.Len(t, savedUsers, 2)
+	user, err := dbService.GetUser("user03")
+	assert.NoError(t, err)
+	assert.NotNil(t, user)
+}
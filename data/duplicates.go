@@ -0,0 +1,174 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultDuplicateDateWindow is the default maximum number of days between the dates of duplicate transactions.
+const DefaultDuplicateDateWindow = 3
+
+// DuplicateAction specifies how imports handle likely duplicates of existing transactions.
+type DuplicateAction int
+
+const (
+	// DuplicatesIgnore imports transactions without checking for duplicates.
+	DuplicatesIgnore DuplicateAction = iota
+	// DuplicatesFlag imports likely duplicates and reports them as warnings.
+	DuplicatesFlag
+	// DuplicatesSkip doesn't import likely duplicates.
+	DuplicatesSkip
+)
+
+// ParseDuplicateAction returns the DuplicateAction with the specified name (ignore, flag or skip).
+// An empty name is the same as ignore.
+func ParseDuplicateAction(name string) (DuplicateAction, error) {
+	switch name {
+	case "", "ignore":
+		return DuplicatesIgnore, nil
+	case "flag":
+		return DuplicatesFlag, nil
+	case "skip":
+		return DuplicatesSkip, nil
+	default:
+		return DuplicatesIgnore, fmt.Errorf("unsupported duplicates action %v", name)
+	}
+}
+
+// DuplicateOptions specifies how imports detect and handle likely duplicates.
+type DuplicateOptions struct {
+	Action DuplicateAction
+	// DateWindow is the maximum number of days between the dates of duplicate transactions.
+	DateWindow int
+}
+
+// descriptionWords splits a description into lowercase words, ignoring punctuation.
+func descriptionWords(description string) []string {
+	return strings.FieldsFunc(strings.ToLower(description), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+}
+
+// similarDescriptions returns true if descriptions are equal (ignoring case and punctuation),
+// one contains the other, or at least half of their words are shared.
+func similarDescriptions(a, b string) bool {
+	wordsA, wordsB := descriptionWords(a), descriptionWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return len(wordsA) == len(wordsB)
+	}
+	joinedA, joinedB := strings.Join(wordsA, " "), strings.Join(wordsB, " ")
+	if strings.Contains(joinedA, joinedB) || strings.Contains(joinedB, joinedA) {
+		return true
+	}
+
+	words := make(map[string]bool, len(wordsA))
+	for _, word := range wordsA {
+		words[word] = true
+	}
+	union := len(words)
+	shared := 0
+	for _, word := range wordsB {
+		if _, ok := words[word]; !ok {
+			union++
+			words[word] = false
+		} else if words[word] {
+			shared++
+			words[word] = false
+		}
+	}
+	return shared*2 >= union
+}
+
+// sameComponents returns true if transactions have the same account amounts.
+func sameComponents(a, b *Transaction) bool {
+	if len(a.Components) != len(b.Components) {
+		return false
+	}
+	type accountAmount struct {
+		AccountUUID string
+		Amount      int64
+	}
+	counts := make(map[accountAmount]int, len(a.Components))
+	for _, component := range a.Components {
+		counts[accountAmount{component.AccountUUID, component.Amount}]++
+	}
+	for _, component := range b.Components {
+		key := accountAmount{component.AccountUUID, component.Amount}
+		if counts[key] == 0 {
+			return false
+		}
+		counts[key]--
+	}
+	return true
+}
+
+// findDuplicates returns existing transactions which are likely duplicates of transaction.
+// Transactions with UUIDs from exclude are ignored.
+// This method should be called from a view or update transaction.
+func (s *DBService) findDuplicates(user *User, transaction *Transaction, dateWindow int, exclude map[string]bool) ([]*Transaction, error) {
+	date, err := time.Parse(inputDateFormat, transaction.Date)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse date %v: %w", transaction.Date, err)
+	}
+	endDate := date.AddDate(0, 0, dateWindow)
+	options := TransactionFilterOptions{
+		FilterFromDate: date.AddDate(0, 0, -dateWindow).Format(dateFormat),
+		FilterToDate:   endDate.Format(dateFormat),
+	}
+	// Start after the last transaction of the window's last day, so that newer transactions are not read.
+	start := &transactionCursor{
+		year:     uint16(endDate.Year()),
+		month:    uint8(endDate.Month()),
+		day:      uint8(endDate.Day()),
+		position: math.MaxUint32,
+	}
+
+	duplicates := make([]*Transaction, 0)
+	done := false
+	handleFn := func(transactionUUID string) error {
+		if transactionUUID == transaction.UUID || exclude[transactionUUID] {
+			return nil
+		}
+		existing, err := s.getTransaction(user, transactionUUID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			s.scheduleCleanup(user)
+			return nil
+		}
+		if existing.Date < options.FilterFromDate {
+			// Transactions are sorted newest first, so all remaining transactions are outside the window.
+			done = true
+			return nil
+		}
+		if options.Matches(existing) && sameComponents(transaction, existing) && similarDescriptions(transaction.Description, existing.Description) {
+			duplicates = append(duplicates, existing)
+		}
+		return nil
+	}
+	doneFn := func() bool { return done }
+
+	if err := s.iterateTransactions(user, start, handleFn, doneFn); err != nil {
+		return nil, err
+	}
+	return duplicates, nil
+}
+
+// FindDuplicates returns existing transactions which are likely duplicates of transaction:
+// their dates are at most dateWindow days apart, they have the same account amounts and a similar description.
+func (s *DBService) FindDuplicates(user *User, transaction *Transaction, dateWindow int) ([]*Transaction, error) {
+	var duplicates []*Transaction
+	err := s.view(user, func() error {
+		var err error
+		duplicates, err = s.findDuplicates(user, transaction, dateWindow, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicates: %w", err)
+	}
+	return duplicates, nil
+}
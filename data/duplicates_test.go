@@ -0,0 +1,131 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createDuplicatesTestTransaction(t *testing.T) *Transaction {
+	transaction := &Transaction{
+		Description: "Dinner at Joe's",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-10",
+		Components: []TransactionComponent{
+			{AccountUUID: testAccount1.UUID, Amount: -4250},
+			{AccountUUID: testAccount2.UUID, Amount: 100},
+		},
+	}
+	err := dbService.CreateTransaction(&testUser, transaction)
+	assert.NoError(t, err)
+	return transaction
+}
+
+func TestSimilarDescriptions(t *testing.T) {
+	assert.True(t, similarDescriptions("Dinner", "dinner!"))
+	assert.True(t, similarDescriptions("Dinner", "Dinner at Joe's"))
+	assert.True(t, similarDescriptions("Joe's restaurant, dinner", "dinner at Joe's"))
+	assert.True(t, similarDescriptions("", " - "))
+	assert.False(t, similarDescriptions("Dinner", ""))
+	assert.False(t, similarDescriptions("Dinner at Joe's", "Lunch at Ann's"))
+}
+
+func TestFindDuplicates(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+	existing := createDuplicatesTestTransaction(t)
+	err = dbService.CreateTransaction(&testUser, &Transaction{
+		Description: "Dinner",
+		Date:        "2019-03-20",
+		Components:  []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: -4250}, {AccountUUID: testAccount2.UUID, Amount: 100}},
+	})
+	assert.NoError(t, err)
+
+	candidate := &Transaction{
+		Description: "dinner",
+		Date:        "2019-3-12",
+		Components:  []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: 100}, {AccountUUID: testAccount1.UUID, Amount: -4250}},
+	}
+	duplicates, err := dbService.FindDuplicates(&testUser, candidate, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{existing}, duplicates)
+
+	duplicates, err = dbService.FindDuplicates(&testUser, candidate, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, duplicates)
+
+	// Transactions on the last day of the window are checked.
+	candidate.Date = "2019-03-07"
+	duplicates, err = dbService.FindDuplicates(&testUser, candidate, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{existing}, duplicates)
+	candidate.Date = "2019-03-12"
+
+	candidate.Components[0].Amount = 200
+	duplicates, err = dbService.FindDuplicates(&testUser, candidate, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, duplicates)
+
+	// A transaction is not a duplicate of itself.
+	duplicates, err = dbService.FindDuplicates(&testUser, existing, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, duplicates)
+
+	_, err = dbService.FindDuplicates(&testUser, &Transaction{Date: "2019-13-01"}, 3)
+	assert.Error(t, err)
+}
+
+func TestImportDuplicates(t *testing.T) {
+	journal := `2019/03/11 Dinner at Joe's
+    Test 1  -42.50 USD
+    Test 2  1.00 EUR
+
+2019/03/11 Dinner at Joe's
+    Test 1  -42.50 USD
+    Test 2  1.00 EUR
+
+2019/03/11 Lunch
+    Test 1  -42.50 USD
+    Test 2  1.00 EUR
+`
+	for _, action := range []DuplicateAction{DuplicatesIgnore, DuplicatesFlag, DuplicatesSkip} {
+		err := resetDb()
+		assert.NoError(t, err)
+		err = createTestAccounts(dbService)
+		assert.NoError(t, err)
+		createDuplicatesTestTransaction(t)
+
+		duplicates := DuplicateOptions{Action: action, DateWindow: DefaultDuplicateDateWindow}
+		result, err := dbService.ImportJournal(&testUser, JournalFormatLedger, duplicates, strings.NewReader(journal))
+		assert.NoError(t, err)
+
+		// Transactions from the same file are not duplicates of each other.
+		messages := []string{
+			"transaction 2019-03-11 Dinner at Joe's is a possible duplicate of 2019-03-10 Dinner at Joe's",
+			"transaction 2019-03-11 Dinner at Joe's is a possible duplicate of 2019-03-10 Dinner at Joe's",
+		}
+		switch action {
+		case DuplicatesIgnore:
+			assert.Equal(t, &ImportResult{Imported: 3}, result)
+		case DuplicatesFlag:
+			assert.Equal(t, &ImportResult{Imported: 3, Warnings: messages}, result)
+		case DuplicatesSkip:
+			assert.Equal(t, &ImportResult{Imported: 1, Skipped: messages}, result)
+		}
+	}
+}
+
+func TestParseDuplicateAction(t *testing.T) {
+	tests := map[string]DuplicateAction{"": DuplicatesIgnore, "ignore": DuplicatesIgnore, "flag": DuplicatesFlag, "skip": DuplicatesSkip}
+	for name, expected := range tests {
+		action, err := ParseDuplicateAction(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, action, name)
+	}
+
+	_, err := ParseDuplicateAction("merge")
+	assert.Error(t, err)
+}
@@ -0,0 +1,307 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExchangeRate is the rate to convert an amount from one currency to another, starting from Date.
+type ExchangeRate struct {
+	Date string
+	From string
+	To   string
+	// Rate is the amount in To currency for one unit of From currency.
+	Rate float64
+}
+
+// exchangeRateValue is a dated rate of a currency pair.
+type exchangeRateValue struct {
+	Date string
+	Rate float64
+}
+
+// exchangeRateValues are all dated rates of a currency pair, sorted by date.
+type exchangeRateValues []exchangeRateValue
+
+// encode serializes exchangeRateValues.
+func (values exchangeRateValues) encode() ([]byte, error) {
+	var value bytes.Buffer
+	if err := gob.NewEncoder(&value).Encode(values); err != nil {
+		return nil, err
+	}
+	return value.Bytes(), nil
+}
+
+// decode deserializes exchangeRateValues.
+func (values *exchangeRateValues) decode(val []byte) error {
+	return gob.NewDecoder(bytes.NewBuffer(val)).Decode(values)
+}
+
+// find returns the rate valid on date: the last rate before or on that date.
+// If date is before the first rate, the first rate is returned.
+func (values exchangeRateValues) find(date string) (float64, bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(values), func(i int) bool { return values[i].Date > date })
+	if i == 0 {
+		return values[0].Rate, true
+	}
+	return values[i-1].Rate, true
+}
+
+// normalize validates the exchange rate and reformats its date.
+func (rate *ExchangeRate) normalize() error {
+	rate.From = strings.TrimSpace(rate.From)
+	rate.To = strings.TrimSpace(rate.To)
+	if rate.From == "" || rate.To == "" {
+		return fmt.Errorf("exchange rate has no currency")
+	}
+	if rate.From == rate.To {
+		return fmt.Errorf("exchange rate converts %v into itself", rate.From)
+	}
+	if !(rate.Rate > 0) || math.IsInf(rate.Rate, 0) {
+		return fmt.Errorf("invalid exchange rate %v", rate.Rate)
+	}
+	date, err := time.Parse(inputDateFormat, rate.Date)
+	if err != nil {
+		return fmt.Errorf("cannot parse date %v: %w", rate.Date, err)
+	}
+	rate.Date = date.Format(dateFormat)
+	return nil
+}
+
+// getExchangeRateValues returns the rates of a currency pair.
+func (s *DBService) getExchangeRateValues(user *User, from, to string) (exchangeRateValues, error) {
+	key := user.createExchangeRateKey(from, to)
+	value, err := s.db.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rates %v: %w", string(key), err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	var values exchangeRateValues
+	if err := values.decode(value); err != nil {
+		return nil, fmt.Errorf("failed to read value for exchange rates %v: %w", string(key), err)
+	}
+	return values, nil
+}
+
+// saveExchangeRates adds rates to the existing rates, replacing rates for the same currency pair and date.
+// This method should be called from an update transaction.
+func (s *DBService) saveExchangeRates(user *User, rates []*ExchangeRate) error {
+	type currencyPair struct {
+		From string
+		To   string
+	}
+	pairRates := make(map[currencyPair]map[string]float64)
+	for _, rate := range rates {
+		pair := currencyPair{From: rate.From, To: rate.To}
+		dateRates, ok := pairRates[pair]
+		if !ok {
+			dateRates = make(map[string]float64)
+			pairRates[pair] = dateRates
+		}
+		dateRates[rate.Date] = rate.Rate
+	}
+
+	for pair, dateRates := range pairRates {
+		values, err := s.getExchangeRateValues(user, pair.From, pair.To)
+		if err != nil {
+			return err
+		}
+		for _, value := range values {
+			if _, ok := dateRates[value.Date]; !ok {
+				dateRates[value.Date] = value.Rate
+			}
+		}
+
+		values = make(exchangeRateValues, 0, len(dateRates))
+		for date, rate := range dateRates {
+			values = append(values, exchangeRateValue{Date: date, Rate: rate})
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i].Date < values[j].Date })
+
+		value, err := values.encode()
+		if err != nil {
+			return fmt.Errorf("cannot encode exchange rates: %w", err)
+		}
+		if err := s.addReferencedKey([]byte(user.createExchangeRateKeyPrefix()), []byte(createExchangeRatePair(pair.From, pair.To)), true); err != nil {
+			return fmt.Errorf("cannot add exchange rates to index: %w", err)
+		}
+		if err := s.db.Put(user.createExchangeRateKey(pair.From, pair.To), value); err != nil {
+			return fmt.Errorf("cannot save exchange rates: %w", err)
+		}
+	}
+	return nil
+}
+
+// SaveExchangeRates validates and saves rates.
+// Existing rates for the same currency pair and date are replaced.
+func (s *DBService) SaveExchangeRates(user *User, rates []*ExchangeRate) error {
+	for _, rate := range rates {
+		if err := rate.normalize(); err != nil {
+			return err
+		}
+	}
+	return s.update(user, func(s *DBService) error {
+		return s.saveExchangeRates(user, rates)
+	})
+}
+
+// GetExchangeRates returns all exchange rates for user, sorted by currency pair and date.
+func (s *DBService) GetExchangeRates(user *User) ([]*ExchangeRate, error) {
+	rates := make([]*ExchangeRate, 0)
+	err := s.view(user, func() error {
+		pairs, err := s.getReferencedKeys([]byte(user.createExchangeRateKeyPrefix()))
+		if err != nil {
+			return fmt.Errorf("cannot get exchange rate pairs for user: %w", err)
+		}
+
+		for _, pair := range pairs {
+			from, to, err := decodeExchangeRatePair(pair)
+			if err != nil {
+				return err
+			}
+			values, err := s.getExchangeRateValues(user, from, to)
+			if err != nil {
+				return err
+			}
+			for _, value := range values {
+				rates = append(rates, &ExchangeRate{Date: value.Date, From: from, To: to, Rate: value.Rate})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rates: %w", err)
+	}
+	sort.SliceStable(rates, func(i, j int) bool {
+		if rates[i].From != rates[j].From {
+			return rates[i].From < rates[j].From
+		}
+		return rates[i].To < rates[j].To
+	})
+	return rates, nil
+}
+
+// DeleteExchangeRate deletes the exchange rate of a currency pair on date.
+// If the exchange rate doesn't exist, it returns an error.
+func (s *DBService) DeleteExchangeRate(user *User, from, to, date string) error {
+	return s.update(user, func(s *DBService) error {
+		values, err := s.getExchangeRateValues(user, from, to)
+		if err != nil {
+			return err
+		}
+		remaining := values[:0]
+		for _, value := range values {
+			if value.Date != date {
+				remaining = append(remaining, value)
+			}
+		}
+		if len(remaining) == len(values) {
+			return fmt.Errorf("cannot delete exchange rate %v/%v on %v because it doesn't exist", from, to, date)
+		}
+
+		key := user.createExchangeRateKey(from, to)
+		if len(remaining) > 0 {
+			value, err := remaining.encode()
+			if err != nil {
+				return fmt.Errorf("cannot encode exchange rates: %w", err)
+			}
+			return s.db.Put(key, value)
+		}
+
+		if err := s.db.Delete(key); err != nil {
+			return fmt.Errorf("cannot delete exchange rates %v: %w", string(key), err)
+		}
+		return s.deleteReferencedKey([]byte(user.createExchangeRateKeyPrefix()), []byte(createExchangeRatePair(from, to)))
+	})
+}
+
+// ExchangeRateConverter converts amounts between currencies.
+type ExchangeRateConverter struct {
+	rates map[string]map[string]exchangeRateValues
+}
+
+// NewExchangeRateConverter creates an ExchangeRateConverter from rates.
+func NewExchangeRateConverter(rates []*ExchangeRate) *ExchangeRateConverter {
+	converter := &ExchangeRateConverter{rates: make(map[string]map[string]exchangeRateValues)}
+	for _, rate := range rates {
+		toRates, ok := converter.rates[rate.From]
+		if !ok {
+			toRates = make(map[string]exchangeRateValues)
+			converter.rates[rate.From] = toRates
+		}
+		toRates[rate.To] = append(toRates[rate.To], exchangeRateValue{Date: rate.Date, Rate: rate.Rate})
+	}
+	for _, toRates := range converter.rates {
+		for _, values := range toRates {
+			sort.SliceStable(values, func(i, j int) bool { return values[i].Date < values[j].Date })
+		}
+	}
+	return converter
+}
+
+// pairRate returns the rate to convert from one currency to another on date, using the direct or inverse rate.
+func (converter *ExchangeRateConverter) pairRate(from, to, date string) (float64, bool) {
+	if rate, ok := converter.rates[from][to].find(date); ok {
+		return rate, true
+	}
+	if rate, ok := converter.rates[to][from].find(date); ok {
+		return 1 / rate, true
+	}
+	return 0, false
+}
+
+// Rate returns the rate to convert from one currency to another on date.
+// If there's no rate between the currencies, it tries to convert through another currency.
+func (converter *ExchangeRateConverter) Rate(from, to, date string) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	if rate, ok := converter.pairRate(from, to, date); ok {
+		return rate, true
+	}
+
+	currencies := make([]string, 0)
+	for currency, toRates := range converter.rates {
+		currencies = append(currencies, currency)
+		for currency := range toRates {
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		if currency == from || currency == to {
+			continue
+		}
+		fromRate, ok := converter.pairRate(from, currency, date)
+		if !ok {
+			continue
+		}
+		toRate, ok := converter.pairRate(currency, to, date)
+		if !ok {
+			continue
+		}
+		return fromRate * toRate, true
+	}
+	return 0, false
+}
+
+// Convert converts amount from one currency to another, using the rate valid on date.
+// Returns false if there's no rate between the currencies.
+func (converter *ExchangeRateConverter) Convert(amount int64, from, to, date string) (int64, bool) {
+	rate, ok := converter.Rate(from, to, date)
+	if !ok {
+		return 0, false
+	}
+	return int64(math.Round(float64(amount) * rate)), true
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveExchangeRates(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = dbService.SaveExchangeRates(&testUser, []*ExchangeRate{
+		{Date: "2015-1-2", From: "EUR", To: "USD", Rate: 1.2},
+		{Date: "2015-01-01", From: " EUR ", To: "USD", Rate: 1.1},
+		{Date: "2015-01-01", From: "CHF", To: "EUR", Rate: 0.9},
+	})
+	assert.NoError(t, err)
+
+	err = dbService.SaveExchangeRates(&testUser, []*ExchangeRate{
+		{Date: "2015-01-02", From: "EUR", To: "USD", Rate: 1.25},
+		{Date: "2015-01-03", From: "EUR", To: "USD", Rate: 1.3},
+	})
+	assert.NoError(t, err)
+
+	rates, err := dbService.GetExchangeRates(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ExchangeRate{
+		{Date: "2015-01-01", From: "CHF", To: "EUR", Rate: 0.9},
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: 1.1},
+		{Date: "2015-01-02", From: "EUR", To: "USD", Rate: 1.25},
+		{Date: "2015-01-03", From: "EUR", To: "USD", Rate: 1.3},
+	}, rates)
+}
+
+func TestSaveInvalidExchangeRates(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	for _, rate := range []*ExchangeRate{
+		{Date: "2015-13-01", From: "EUR", To: "USD", Rate: 1.1},
+		{Date: "2015-01-01", From: "", To: "USD", Rate: 1.1},
+		{Date: "2015-01-01", From: "EUR", To: "EUR", Rate: 1.1},
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: 0},
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: -1},
+	} {
+		err = dbService.SaveExchangeRates(&testUser, []*ExchangeRate{rate})
+		assert.Error(t, err)
+	}
+
+	rates, err := dbService.GetExchangeRates(&testUser)
+	assert.NoError(t, err)
+	assert.Empty(t, rates)
+}
+
+func TestDeleteExchangeRate(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = dbService.SaveExchangeRates(&testUser, []*ExchangeRate{
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: 1.1},
+		{Date: "2015-01-02", From: "EUR", To: "USD", Rate: 1.2},
+	})
+	assert.NoError(t, err)
+
+	err = dbService.DeleteExchangeRate(&testUser, "EUR", "USD", "2015-01-01")
+	assert.NoError(t, err)
+	err = dbService.DeleteExchangeRate(&testUser, "EUR", "USD", "2015-01-01")
+	assert.Error(t, err)
+
+	rates, err := dbService.GetExchangeRates(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ExchangeRate{{Date: "2015-01-02", From: "EUR", To: "USD", Rate: 1.2}}, rates)
+
+	err = dbService.DeleteExchangeRate(&testUser, "EUR", "USD", "2015-01-02")
+	assert.NoError(t, err)
+
+	rates, err = dbService.GetExchangeRates(&testUser)
+	assert.NoError(t, err)
+	assert.Empty(t, rates)
+
+	pairs, err := dbService.getReferencedKeys([]byte(testUser.createExchangeRateKeyPrefix()))
+	assert.NoError(t, err)
+	assert.Empty(t, pairs)
+}
+
+func TestExchangeRateConverter(t *testing.T) {
+	converter := NewExchangeRateConverter([]*ExchangeRate{
+		{Date: "2015-01-10", From: "EUR", To: "USD", Rate: 1.25},
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: 1.1},
+		{Date: "2015-01-01", From: "EUR", To: "CHF", Rate: 1.2},
+	})
+
+	amount, ok := converter.Convert(10000, "EUR", "USD", "2015-01-09")
+	assert.True(t, ok)
+	assert.Equal(t, int64(11000), amount)
+
+	amount, ok = converter.Convert(10000, "EUR", "USD", "2015-01-10")
+	assert.True(t, ok)
+	assert.Equal(t, int64(12500), amount)
+
+	// Dates before the first rate use the first rate.
+	amount, ok = converter.Convert(10000, "EUR", "USD", "2014-12-31")
+	assert.True(t, ok)
+	assert.Equal(t, int64(11000), amount)
+
+	amount, ok = converter.Convert(12500, "USD", "EUR", "2015-02-01")
+	assert.True(t, ok)
+	assert.Equal(t, int64(10000), amount)
+
+	amount, ok = converter.Convert(12500, "USD", "CHF", "2015-02-01")
+	assert.True(t, ok)
+	assert.Equal(t, int64(12000), amount)
+
+	amount, ok = converter.Convert(-100, "USD", "USD", "2015-02-01")
+	assert.True(t, ok)
+	assert.Equal(t, int64(-100), amount)
+
+	_, ok = converter.Convert(100, "USD", "GBP", "2015-02-01")
+	assert.False(t, ok)
+}
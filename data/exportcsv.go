@@ -0,0 +1,99 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvExportHeader contains the column names of exported CSV files.
+var csvExportHeader = []string{"Date", "Description", "Type", "Tags", "Account", "Currency", "Amount"}
+
+// csvTransactionType returns the name of a transaction type.
+func csvTransactionType(transactionType int) string {
+	switch transactionType {
+	case TransactionTypeExpenseIncome:
+		return "Expense/Income"
+	case TransactionTypeTransfer:
+		return "Transfer"
+	default:
+		return fmt.Sprintf("Unknown (%v)", transactionType)
+	}
+}
+
+// ExportCSV writes transactions matching options to writer as CSV, with one row for each transaction component.
+// Transactions are written newest first, reading them from the database one page at a time.
+func (s *DBService) ExportCSV(user *User, options TransactionFilterOptions, writer io.Writer) error {
+	if err := options.normalizeDates(); err != nil {
+		return err
+	}
+
+	var accountsMap map[string]*Account
+	var transactionUUIDs []string
+	err := s.view(user, func() error {
+		accounts, err := s.getAccounts(user)
+		if err != nil {
+			return fmt.Errorf("failed to get accounts: %w", err)
+		}
+		accountsMap = make(map[string]*Account, len(accounts))
+		for _, account := range accounts {
+			accountsMap[account.UUID] = account
+		}
+
+		transactionUUIDs, err = s.getMatchingTransactionUUIDs(user, &options)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export transactions: %w", err)
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(csvExportHeader); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	emptyFilter := options.IsEmpty()
+	handleFn := func(transactions []*Transaction) error {
+		for _, transaction := range transactions {
+			if !emptyFilter && !options.Matches(transaction) {
+				continue
+			}
+
+			tags := strings.Join(transaction.Tags, ", ")
+			transactionType := csvTransactionType(transaction.Type)
+			for _, component := range transaction.Components {
+				var accountName, currency string
+				if account := accountsMap[component.AccountUUID]; account != nil {
+					accountName, currency = account.Name, account.Currency
+				}
+				record := []string{
+					transaction.Date,
+					transaction.Description,
+					transactionType,
+					tags,
+					accountName,
+					currency,
+					formatAmount(component.Amount),
+				}
+				if err := csvWriter.Write(record); err != nil {
+					return fmt.Errorf("failed to write CSV: %w", err)
+				}
+			}
+		}
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+		return nil
+	}
+	if err := s.iterateTransactionPages(user, transactionUUIDs, nil, handleFn); err != nil {
+		return fmt.Errorf("failed to export transactions: %w", err)
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
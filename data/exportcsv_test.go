@@ -0,0 +1,73 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportCSV(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+	createJournalTestTransactions(t)
+
+	var export bytes.Buffer
+	err = dbService.ExportCSV(&testUser, TransactionFilterOptions{}, &export)
+	assert.NoError(t, err)
+	assert.Equal(t, `Date,Description,Type,Tags,Account,Currency,Amount
+2019-01-03,Exchange,Transfer,,Test 1,USD,-100.00
+2019-01-03,Exchange,Transfer,,Test 2,EUR,90.00
+2019-01-02,"Groceries ""Corner shop""",Expense/Income,"Food, Household items",Test 1,USD,-12.50
+2019-01-01,Salary,Expense/Income,Salary,Test 1,USD,1000.00
+`, export.String())
+}
+
+func TestExportCSVPages(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+	createJournalTestTransactions(t)
+
+	previousPageSize := transactionPageSize
+	transactionPageSize = 1
+	defer func() { transactionPageSize = previousPageSize }()
+
+	var export updatingWriter
+	err = dbService.ExportCSV(&testUser, TransactionFilterOptions{FilterTags: []string{"Food", "Salary"}}, &export)
+	assert.NoError(t, err)
+	assert.Equal(t, `Date,Description,Type,Tags,Account,Currency,Amount
+2019-01-02,"Groceries ""Corner shop""",Expense/Income,"Food, Household items",Test 1,USD,-12.50
+2019-01-01,Salary,Expense/Income,Salary,Test 1,USD,1000.00
+`, export.String())
+	assert.Equal(t, 2, export.updates)
+}
+
+func TestExportCSVFilter(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+	createJournalTestTransactions(t)
+
+	var export bytes.Buffer
+	options := TransactionFilterOptions{FilterFromDate: "2019-1-2", ExcludeTransfer: true}
+	err = dbService.ExportCSV(&testUser, options, &export)
+	assert.NoError(t, err)
+	assert.Equal(t, `Date,Description,Type,Tags,Account,Currency,Amount
+2019-01-02,"Groceries ""Corner shop""",Expense/Income,"Food, Household items",Test 1,USD,-12.50
+`, export.String())
+}
+
+func TestExportCSVInvalidDate(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	var export bytes.Buffer
+	err = dbService.ExportCSV(&testUser, TransactionFilterOptions{FilterToDate: "2019-13-01"}, &export)
+	assert.Error(t, err)
+	assert.Empty(t, export.String())
+}
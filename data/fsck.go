@@ -0,0 +1,457 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/akrylysov/pogreb"
+)
+
+// FsckReport contains the results of a consistency check.
+type FsckReport struct {
+	Users        int
+	Accounts     int
+	Transactions int
+	// Problems lists all detected inconsistencies.
+	Problems []string
+	// Repaired is true if the detected problems were fixed.
+	Repaired bool
+}
+
+// problem adds a problem found in the data of user.
+func (report *FsckReport) problem(user *User, format string, args ...interface{}) {
+	report.Problems = append(report.Problems, fmt.Sprintf("user %v: ", user.username)+fmt.Sprintf(format, args...))
+}
+
+// transactionIndexEntry is a transaction UUID found in the date index.
+type transactionIndexEntry struct {
+	year            uint16
+	month           uint8
+	day             uint8
+	transactionUUID []byte
+}
+
+// date returns the date of the index path containing entry.
+// Entries for empty year or month indexes return a partial date.
+func (entry *transactionIndexEntry) date() string {
+	if entry.month == 0 {
+		return fmt.Sprintf("%04d", entry.year)
+	} else if entry.day == 0 {
+		return fmt.Sprintf("%04d-%02d", entry.year, entry.month)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", entry.year, entry.month, entry.day)
+}
+
+// createTransactionIndexPath returns the index keys listing the years, months, days and transaction UUIDs for a date.
+func (user *User) createTransactionIndexPath(year uint16, month, day uint8) ([]byte, []byte, []byte, []byte) {
+	yearKey := make([]byte, 2)
+	binary.BigEndian.PutUint16(yearKey, year)
+
+	yearsIndexKey := []byte(user.createTransactionKeyPrefix())
+	monthsIndexKey := append(append([]byte{}, yearsIndexKey...), yearKey...)
+	daysIndexKey := append(append([]byte{}, monthsIndexKey...), month)
+	transactionsIndexKey := append(append([]byte{}, daysIndexKey...), day)
+	return yearsIndexKey, monthsIndexKey, daysIndexKey, transactionsIndexKey
+}
+
+// deleteEmptyTransactionIndexKeys deletes the index keys for a date if they no longer reference any transactions,
+// and removes them from their parent index.
+func (s *DBService) deleteEmptyTransactionIndexKeys(user *User, year uint16, month, day uint8) error {
+	yearsIndexKey, monthsIndexKey, daysIndexKey, transactionsIndexKey := user.createTransactionIndexPath(year, month, day)
+	yearKey := monthsIndexKey[len(yearsIndexKey):]
+
+	parentIndexKeys := []struct {
+		indexKey  []byte
+		parentKey []byte
+		key       []byte
+	}{
+		{transactionsIndexKey, daysIndexKey, []byte{day}},
+		{daysIndexKey, monthsIndexKey, []byte{month}},
+		{monthsIndexKey, yearsIndexKey, yearKey},
+	}
+	for _, parentIndexKey := range parentIndexKeys {
+		indexKeys, err := s.getReferencedKeys(parentIndexKey.indexKey)
+		if err != nil {
+			return err
+		}
+		if len(indexKeys) > 0 {
+			// Items still remaining in index.
+			return nil
+		}
+		// Nothing remaining for this index - delete it.
+		if err := s.db.Delete(parentIndexKey.indexKey); err != nil {
+			return err
+		}
+		if err := s.deleteReferencedKey(parentIndexKey.parentKey, parentIndexKey.key); err != nil {
+			return err
+		}
+	}
+
+	years, err := s.getReferencedKeys(yearsIndexKey)
+	if err != nil {
+		return err
+	}
+	if len(years) == 0 {
+		return s.db.Delete(yearsIndexKey)
+	}
+	return nil
+}
+
+// getUsers returns all users.
+func (s *DBService) getUsers() ([]*User, error) {
+	users := make([]*User, 0)
+	it := s.db.Items()
+	for {
+		key, value, err := it.Next()
+		if err == pogreb.ErrIterationDone {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if !bytes.HasPrefix(key, []byte(userKeyPrefix)) {
+			continue
+		}
+
+		username, err := decodeUserKey(key)
+		if err != nil {
+			return nil, err
+		}
+		user := &User{username: *username}
+		if err := user.decode(value); err != nil {
+			return nil, fmt.Errorf("failed to read value of user %v: %w", *username, err)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// getAllTransactionUUIDs returns the UUIDs of all stored transactions, including transactions missing from the index.
+func (s *DBService) getAllTransactionUUIDs(user *User) ([]string, error) {
+	prefix := []byte(user.createTransactionKeyPrefix() + separator)
+	transactionUUIDs := make([]string, 0)
+	it := s.db.Items()
+	for {
+		key, _, err := it.Next()
+		if err == pogreb.ErrIterationDone {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(key, prefix) {
+			transactionUUIDs = append(transactionUUIDs, string(key[len(prefix):]))
+		}
+	}
+	return transactionUUIDs, nil
+}
+
+// getAllTransactions returns all stored transactions, including transactions missing from the index.
+func (s *DBService) getAllTransactions(user *User) ([]*Transaction, error) {
+	transactionUUIDs, err := s.getAllTransactionUUIDs(user)
+	if err != nil {
+		return nil, err
+	}
+	transactions := make([]*Transaction, 0, len(transactionUUIDs))
+	for _, transactionUUID := range transactionUUIDs {
+		transaction, err := s.getTransaction(user, transactionUUID)
+		if err != nil {
+			return nil, err
+		}
+		if transaction != nil {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+// getTransactionIndexEntries returns all entries from the date index of user's transactions.
+// Index keys which don't reference anything are returned as entries without a transactionUUID.
+func (s *DBService) getTransactionIndexEntries(user *User) ([]*transactionIndexEntry, error) {
+	entries := make([]*transactionIndexEntry, 0)
+	yearsIndexKey, _, _, _ := user.createTransactionIndexPath(0, 0, 0)
+	years, err := s.getReferencedKeys(yearsIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed get transactions years index: %w", err)
+	}
+	for _, yearKey := range years {
+		if len(yearKey) != 2 {
+			return nil, fmt.Errorf("invalid year index key %v", yearKey)
+		}
+		year := binary.BigEndian.Uint16(yearKey)
+		_, monthsIndexKey, _, _ := user.createTransactionIndexPath(year, 0, 0)
+		months, err := s.getReferencedKeys(monthsIndexKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed get transactions months index: %w", err)
+		}
+		if len(months) == 0 {
+			entries = append(entries, &transactionIndexEntry{year: year})
+		}
+		for _, monthKey := range months {
+			if len(monthKey) != 1 {
+				return nil, fmt.Errorf("invalid month index key %v", monthKey)
+			}
+			month := monthKey[0]
+			_, _, daysIndexKey, _ := user.createTransactionIndexPath(year, month, 0)
+			days, err := s.getReferencedKeys(daysIndexKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed get transactions days index: %w", err)
+			}
+			if len(days) == 0 {
+				entries = append(entries, &transactionIndexEntry{year: year, month: month})
+			}
+			for _, dayKey := range days {
+				if len(dayKey) != 1 {
+					return nil, fmt.Errorf("invalid day index key %v", dayKey)
+				}
+				day := dayKey[0]
+				_, _, _, transactionsIndexKey := user.createTransactionIndexPath(year, month, day)
+				transactionUUIDs, err := s.getReferencedKeys(transactionsIndexKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed get transactions index: %w", err)
+				}
+				if len(transactionUUIDs) == 0 {
+					entries = append(entries, &transactionIndexEntry{year: year, month: month, day: day})
+				}
+				for _, transactionUUID := range transactionUUIDs {
+					entries = append(entries, &transactionIndexEntry{year: year, month: month, day: day, transactionUUID: transactionUUID})
+				}
+			}
+		}
+	}
+	return entries, nil
+}
+
+// checkAccountIndex checks that the account index references only existing accounts.
+// If repair is true, references to missing accounts are deleted.
+// Returns the accounts referenced by the index.
+func (s *DBService) checkAccountIndex(user *User, repair bool, report *FsckReport) ([]*Account, error) {
+	accountsPrefix := []byte(user.createAccountKeyPrefix())
+	accountUUIDs, err := s.getReferencedKeys(accountsPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get accounts UUIDs for user: %w", err)
+	}
+
+	accounts := make([]*Account, 0, len(accountUUIDs))
+	for _, accountUUID := range accountUUIDs {
+		account, err := s.getAccount(user, string(accountUUID))
+		if err != nil {
+			return nil, err
+		}
+		if account != nil {
+			accounts = append(accounts, account)
+			continue
+		}
+
+		report.problem(user, "account index references missing account %v", string(accountUUID))
+		if repair {
+			if err := s.deleteReferencedKey(accountsPrefix, accountUUID); err != nil {
+				return nil, fmt.Errorf("failed to delete account index entry %v: %w", string(accountUUID), err)
+			}
+		}
+	}
+	return accounts, nil
+}
+
+// checkTransactionIndex checks that index entries reference existing transactions with a matching date.
+// If repair is true, invalid entries and empty index keys are deleted.
+// Returns the UUIDs of correctly indexed transactions, and transactions which are indexed under a different date.
+func (s *DBService) checkTransactionIndex(user *User, repair bool, report *FsckReport) (map[string]bool, []*Transaction, error) {
+	entries, err := s.getTransactionIndexEntries(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indexed := make(map[string]bool, len(entries))
+	misplaced := make([]*Transaction, 0)
+	for _, entry := range entries {
+		var transaction *Transaction
+		if entry.transactionUUID != nil {
+			transaction, err = s.getTransaction(user, string(entry.transactionUUID))
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if entry.transactionUUID == nil {
+			report.problem(user, "index for %v is empty", entry.date())
+		} else if transaction == nil {
+			report.problem(user, "index for %v references missing transaction %v", entry.date(), string(entry.transactionUUID))
+		} else if date, err := time.Parse(inputDateFormat, transaction.Date); err != nil {
+			// Cannot be repaired automatically - the transaction has to be edited.
+			report.problem(user, "transaction %v has an invalid date %v", transaction.UUID, transaction.Date)
+			indexed[transaction.UUID] = true
+			continue
+		} else if date.Format(dateFormat) != entry.date() {
+			report.problem(user, "index for %v references transaction %v dated %v", entry.date(), transaction.UUID, transaction.Date)
+			misplaced = append(misplaced, transaction)
+		} else {
+			indexed[transaction.UUID] = true
+			continue
+		}
+
+		if repair {
+			_, _, _, transactionsIndexKey := user.createTransactionIndexPath(entry.year, entry.month, entry.day)
+			if entry.transactionUUID != nil {
+				if err := s.deleteReferencedKey(transactionsIndexKey, entry.transactionUUID); err != nil {
+					return nil, nil, fmt.Errorf("failed to delete index entry for %v: %w", entry.date(), err)
+				}
+			}
+			if err := s.deleteEmptyTransactionIndexKeys(user, entry.year, entry.month, entry.day); err != nil {
+				return nil, nil, fmt.Errorf("failed to delete empty index for %v: %w", entry.date(), err)
+			}
+		}
+	}
+	return indexed, misplaced, nil
+}
+
+// reindexTransactions adds transactions which are not in indexed to the index.
+func (s *DBService) reindexTransactions(user *User, transactions []*Transaction, indexed map[string]bool) error {
+	for _, transaction := range transactions {
+		if indexed[transaction.UUID] {
+			continue
+		}
+		if err := s.createTransactionIndexKey(user, transaction); err != nil {
+			return err
+		}
+		indexed[transaction.UUID] = true
+	}
+	return nil
+}
+
+// fsckUser checks the transaction index and account balances of user.
+// If repair is true, all problems are fixed.
+func (s *DBService) fsckUser(user *User, repair bool, report *FsckReport) error {
+	accounts, err := s.checkAccountIndex(user, repair, report)
+	if err != nil {
+		return err
+	}
+
+	indexed, reindex, err := s.checkTransactionIndex(user, repair, report)
+	if err != nil {
+		return err
+	}
+
+	transactionUUIDs, err := s.getAllTransactionUUIDs(user)
+	if err != nil {
+		return fmt.Errorf("failed to get transactions: %w", err)
+	}
+	transactions := make(map[string]*Transaction, len(transactionUUIDs))
+	transactionsList := make([]*Transaction, 0, len(transactionUUIDs))
+	for _, transactionUUID := range transactionUUIDs {
+		transaction, err := s.getTransaction(user, transactionUUID)
+		if err != nil {
+			return err
+		}
+		transactions[transactionUUID] = transaction
+		transactionsList = append(transactionsList, transaction)
+	}
+
+	// Check that all transactions are indexed.
+	for _, transactionUUID := range transactionUUIDs {
+		transaction := transactions[transactionUUID]
+		if indexed[transaction.UUID] || containsTransaction(reindex, transaction) {
+			continue
+		}
+		if _, err := time.Parse(inputDateFormat, transaction.Date); err != nil {
+			report.problem(user, "transaction %v has an invalid date %v", transaction.UUID, transaction.Date)
+			continue
+		}
+		report.problem(user, "transaction %v dated %v is missing from index", transaction.UUID, transaction.Date)
+		reindex = append(reindex, transaction)
+	}
+	if repair {
+		if err := s.reindexTransactions(user, reindex, indexed); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkSecondaryIndexes(user, transactionsList, repair, report); err != nil {
+		return err
+	}
+
+	// Check account balances.
+	balances := make(map[string]int64, len(accounts))
+	for _, transactionUUID := range transactionUUIDs {
+		for _, component := range transactions[transactionUUID].Components {
+			balances[component.AccountUUID] += component.Amount
+		}
+	}
+	for _, account := range accounts {
+		balance := balances[account.UUID]
+		delete(balances, account.UUID)
+		if account.Balance == balance {
+			continue
+		}
+		report.problem(user, "account %v balance is %v, expected %v", account.UUID, formatAmount(account.Balance), formatAmount(balance))
+		if repair {
+			account.Balance = balance
+			value, err := account.encode()
+			if err != nil {
+				return fmt.Errorf("cannot encode account: %w", err)
+			}
+			if err := s.db.Put(user.createAccountKey(account), value); err != nil {
+				return fmt.Errorf("cannot save account %v: %w", account.UUID, err)
+			}
+		}
+	}
+	missingAccounts := make([]string, 0, len(balances))
+	for accountUUID := range balances {
+		missingAccounts = append(missingAccounts, accountUUID)
+	}
+	sort.Strings(missingAccounts)
+	for _, accountUUID := range missingAccounts {
+		// Cannot be repaired automatically - the account has to be recreated or the transactions edited.
+		report.problem(user, "transactions reference missing account %v", accountUUID)
+	}
+
+	report.Accounts += len(accounts)
+	report.Transactions += len(transactionUUIDs)
+	return nil
+}
+
+// containsTransaction returns true if transactions contains transaction.
+func containsTransaction(transactions []*Transaction, transaction *Transaction) bool {
+	for _, item := range transactions {
+		if item.UUID == transaction.UUID {
+			return true
+		}
+	}
+	return false
+}
+
+// Fsck checks that the transaction index and account balances of all users match their transactions.
+// If repair is true, the index is rebuilt where necessary and balances are recomputed.
+func (s *DBService) Fsck(repair bool) (*FsckReport, error) {
+	report := &FsckReport{Problems: make([]string, 0), Repaired: repair}
+	var users []*User
+	err := s.view(nil, func() error {
+		var err error
+		users, err = s.getUsers()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	for _, user := range users {
+		if repair {
+			err = s.update(user, func(s *DBService) error {
+				return s.fsckUser(user, repair, report)
+			})
+		} else {
+			err = s.view(user, func() error {
+				return s.fsckUser(user, repair, report)
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("consistency check failed for user %v: %w", user.username, err)
+		}
+		report.Users++
+	}
+	if len(report.Problems) == 0 {
+		report.Repaired = false
+	}
+	return report, nil
+}
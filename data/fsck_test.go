@@ -0,0 +1,129 @@
+package data
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFsckConsistent(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	user := NewUser("user01")
+	err = dbService.SaveUser(user)
+	assert.NoError(t, err)
+
+	account := &Account{Name: "a1", Currency: "USD"}
+	err = dbService.CreateAccount(user, account)
+	assert.NoError(t, err)
+
+	for _, date := range []string{"2019-03-20", "2019-03-20", "2019-04-01", "2020-01-01"} {
+		transaction := &Transaction{Date: date, Components: []TransactionComponent{{AccountUUID: account.UUID, Amount: 100}}}
+		err = dbService.CreateTransaction(user, transaction)
+		assert.NoError(t, err)
+	}
+	transactions, err := dbService.GetTransactions(user, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	err = dbService.DeleteTransaction(user, transactions[0].UUID, false)
+	assert.NoError(t, err)
+
+	report, err := dbService.Fsck(false)
+	assert.NoError(t, err)
+	assert.Equal(t, &FsckReport{Users: 1, Accounts: 1, Transactions: 3, Problems: []string{}}, report)
+
+	// Deleting the last transaction of a year also deletes the year from the index.
+	years, err := dbService.getReferencedKeys([]byte(user.createTransactionKeyPrefix()))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{0x07, 0xe3}}, years)
+}
+
+func TestFsckRepair(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	user := NewUser("user01")
+	err = dbService.SaveUser(user)
+	assert.NoError(t, err)
+
+	account1 := &Account{Name: "a1", Currency: "USD"}
+	err = dbService.CreateAccount(user, account1)
+	assert.NoError(t, err)
+	account2 := &Account{Name: "a2", Currency: "USD"}
+	err = dbService.CreateAccount(user, account2)
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20", Components: []TransactionComponent{{AccountUUID: account1.UUID, Amount: 100}}}
+	transaction2 := &Transaction{Description: "t2", Date: "2019-03-21", Components: []TransactionComponent{{AccountUUID: account1.UUID, Amount: 200}}}
+	transaction3 := &Transaction{Description: "t3", Date: "2019-03-22", Components: []TransactionComponent{{AccountUUID: account2.UUID, Amount: 300}}}
+	transaction4 := &Transaction{UUID: "uuid4", Description: "t4", Date: "2019-03-23", Components: []TransactionComponent{{AccountUUID: account2.UUID, Amount: 400}}}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3} {
+		err = dbService.CreateTransaction(user, transaction)
+		assert.NoError(t, err)
+	}
+
+	// Simulate partially applied writes.
+	err = dbService.db.Delete(user.createTransactionKey(transaction1))
+	assert.NoError(t, err)
+	value, err := transaction4.encode()
+	assert.NoError(t, err)
+	err = dbService.db.Put(user.createTransactionKey(transaction4), value)
+	assert.NoError(t, err)
+	transaction2.Date = "2019-02-01"
+	value, err = transaction2.encode()
+	assert.NoError(t, err)
+	err = dbService.db.Put(user.createTransactionKey(transaction2), value)
+	assert.NoError(t, err)
+	_, _, _, emptyIndexKey := user.createTransactionIndexPath(2018, 1, 1)
+	err = dbService.addReferencedKey(emptyIndexKey[:len(emptyIndexKey)-2], []byte{1}, true)
+	assert.NoError(t, err)
+	err = dbService.addReferencedKey(emptyIndexKey[:len(emptyIndexKey)-4], []byte{0x07, 0xe2}, true)
+	assert.NoError(t, err)
+
+	secondaryIndexProblems := []string{
+		"user user01: index of account " + account1.UUID + " is inconsistent",
+		"user user01: index of account " + account2.UUID + " is inconsistent",
+		"user user01: index of word t1 is inconsistent",
+		"user user01: index of word t2 is inconsistent",
+		"user user01: index of word t4 is inconsistent",
+		"user user01: list of words is inconsistent",
+	}
+	sort.Strings(secondaryIndexProblems)
+	expectedProblems := []string{
+		"user user01: index for 2018-01 is empty",
+		"user user01: index for 2019-03-20 references missing transaction " + transaction1.UUID,
+		"user user01: index for 2019-03-21 references transaction " + transaction2.UUID + " dated 2019-02-01",
+		"user user01: transaction uuid4 dated 2019-03-23 is missing from index",
+	}
+	expectedProblems = append(expectedProblems, secondaryIndexProblems...)
+	expectedProblems = append(expectedProblems,
+		"user user01: account "+account1.UUID+" balance is 3.00, expected 2.00",
+		"user user01: account "+account2.UUID+" balance is 3.00, expected 7.00",
+	)
+
+	report, err := dbService.Fsck(false)
+	assert.NoError(t, err)
+	assert.Equal(t, &FsckReport{Users: 1, Accounts: 2, Transactions: 3, Problems: expectedProblems}, report)
+
+	report, err = dbService.Fsck(true)
+	assert.NoError(t, err)
+	assert.Equal(t, &FsckReport{Users: 1, Accounts: 2, Transactions: 3, Problems: expectedProblems, Repaired: true}, report)
+
+	report, err = dbService.Fsck(false)
+	assert.NoError(t, err)
+	assert.Equal(t, &FsckReport{Users: 1, Accounts: 2, Transactions: 3, Problems: []string{}}, report)
+
+	transactions, err := dbService.GetTransactions(user, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction4, transaction3, transaction2}, transactions)
+
+	accounts, err := dbService.GetAccounts(user)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200), accounts[0].Balance)
+	assert.Equal(t, int64(700), accounts[1].Balance)
+
+	years, err := dbService.getReferencedKeys([]byte(user.createTransactionKeyPrefix()))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{0x07, 0xe3}}, years)
+}
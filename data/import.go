@@ -0,0 +1,185 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ImportResult contains the outcome of importing a statement.
+type ImportResult struct {
+	Imported        int
+	AlreadyImported int
+	Skipped         []string
+	CreatedAccounts []string
+	Warnings        []string
+}
+
+// skip adds a skipped line to the import result.
+func (result *ImportResult) skip(line int, reason string) {
+	result.Skipped = append(result.Skipped, fmt.Sprintf("line %v: %v", line, reason))
+}
+
+// joinDescription combines a payee name and a memo into a transaction description.
+func joinDescription(name, memo string) string {
+	if memo == "" || memo == name {
+		return name
+	}
+	if name == "" {
+		return memo
+	}
+	return name + " - " + memo
+}
+
+// importedTransaction is a Transaction parsed from a statement.
+type importedTransaction struct {
+	*Transaction
+	// AccountUUID is the account which the statement belongs to.
+	AccountUUID string
+	// EntryID uniquely identifies the statement entry (e.g. an OFX FITID).
+	// If not empty, it's used to skip entries that were imported previously.
+	EntryID string
+}
+
+// getImportAccount returns the account that a statement will be imported into.
+// If currency is not empty, it has to match the account currency.
+// This method should be called from an update transaction.
+func (s *DBService) getImportAccount(user *User, accountUUID string, currency string) (*Account, error) {
+	account, err := s.getAccount(user, accountUUID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get account %v: %w", accountUUID, err)
+	}
+	if account == nil {
+		return nil, fmt.Errorf("cannot import into account %v because it doesn't exist", accountUUID)
+	}
+	if currency != "" && account.Currency != currency {
+		return nil, fmt.Errorf("statement currency %v doesn't match account %v currency %v", currency, account.Name, account.Currency)
+	}
+	return account, nil
+}
+
+// getImportAccounts returns accounts with the specified names.
+// Accounts which don't exist are created from a template returned by newAccount.
+// This method should be called from an update transaction.
+func (s *DBService) getImportAccounts(user *User, names []string, newAccount func(name string) (*Account, error), result *ImportResult) (map[string]*Account, error) {
+	existingAccounts, err := s.getAccounts(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	accounts := make(map[string]*Account)
+	for _, account := range existingAccounts {
+		if _, ok := accounts[account.Name]; !ok {
+			accounts[account.Name] = account
+		}
+	}
+
+	for _, name := range names {
+		if _, ok := accounts[name]; ok {
+			continue
+		}
+		account, err := newAccount(name)
+		if err != nil {
+			return nil, err
+		}
+		account.UUID = uuid.NewString()
+		account.Balance = 0
+		if err := s.createAccount(user, account); err != nil {
+			return nil, fmt.Errorf("cannot create account %v: %w", name, err)
+		}
+		accounts[name] = account
+		result.CreatedAccounts = append(result.CreatedAccounts, name)
+	}
+	return accounts, nil
+}
+
+// isImported returns true if the statement entry was already imported into an existing transaction.
+func (s *DBService) isImported(user *User, transaction *importedTransaction) (bool, error) {
+	if transaction.EntryID == "" {
+		return false, nil
+	}
+	key := user.createImportKey(transaction.AccountUUID, transaction.EntryID)
+	transactionUUID, err := s.db.Get(key)
+	if err != nil {
+		return false, fmt.Errorf("cannot get import key %v: %w", string(key), err)
+	}
+	if transactionUUID == nil {
+		return false, nil
+	}
+	return s.db.Has(user.createTransactionKeyFromUUID(string(transactionUUID)))
+}
+
+// checkDuplicate returns true if transaction should be imported.
+// Likely duplicates are skipped or reported according to duplicates;
+// transactions with UUIDs from imported (created by the current import) are not considered to be duplicates.
+func (s *DBService) checkDuplicate(user *User, transaction *importedTransaction, duplicates DuplicateOptions, imported map[string]bool, result *ImportResult) (bool, error) {
+	if duplicates.Action == DuplicatesIgnore {
+		return true, nil
+	}
+	existing, err := s.findDuplicates(user, transaction.Transaction, duplicates.DateWindow, imported)
+	if err != nil {
+		return false, err
+	}
+	if len(existing) == 0 {
+		return true, nil
+	}
+	message := fmt.Sprintf("transaction %v %v is a possible duplicate of %v %v", transaction.Date, transaction.Description, existing[0].Date, existing[0].Description)
+	if duplicates.Action == DuplicatesSkip {
+		result.Skipped = append(result.Skipped, message)
+		return false, nil
+	}
+	result.Warnings = append(result.Warnings, message)
+	return true, nil
+}
+
+// validateImportedTransactions returns transactions which are valid and normalizes them.
+// Invalid transactions are skipped and listed in result.
+func validateImportedTransactions(transactions []*importedTransaction, result *ImportResult) []*importedTransaction {
+	validTransactions := make([]*importedTransaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		if err := transaction.normalize(); err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("transaction %v: %v", transaction.Description, err))
+			continue
+		}
+		validTransactions = append(validTransactions, transaction)
+	}
+	return validTransactions
+}
+
+// importTransactions saves parsed transactions, skipping entries which were already imported.
+// All transactions are validated before saving any of them; invalid transactions are skipped and listed in result.
+// This method should be called from an update transaction.
+func (s *DBService) importTransactions(user *User, transactions []*importedTransaction, duplicates DuplicateOptions, result *ImportResult) error {
+	imported := make(map[string]bool)
+	for _, transaction := range validateImportedTransactions(transactions, result) {
+		alreadyImported, err := s.isImported(user, transaction)
+		if err != nil {
+			return err
+		}
+		if alreadyImported {
+			result.AlreadyImported++
+			continue
+		}
+
+		ok, err := s.checkDuplicate(user, transaction, duplicates, imported, result)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		transaction.UUID = uuid.NewString()
+		clearReconciledComponents(transaction.Transaction)
+		if err := s.createTransaction(user, transaction.Transaction); err != nil {
+			return fmt.Errorf("failed to create transaction %v: %w", transaction, err)
+		}
+		imported[transaction.UUID] = true
+		if transaction.EntryID != "" {
+			key := user.createImportKey(transaction.AccountUUID, transaction.EntryID)
+			if err := s.db.Put(key, []byte(transaction.UUID)); err != nil {
+				return fmt.Errorf("cannot save import key %v: %w", string(key), err)
+			}
+		}
+		result.Imported++
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportTransactionsInvalid(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	transactions := []*importedTransaction{
+		{Transaction: &Transaction{Description: "Valid", Date: "2019-3-20", Components: []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 100}}}, AccountUUID: testAccount1.UUID},
+		{Transaction: &Transaction{Description: "Invalid", Date: "2019-13-20", Components: []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 200}}}, AccountUUID: testAccount1.UUID},
+	}
+	result := &ImportResult{}
+	err = dbService.update(&testUser, func(s *DBService) error {
+		return s.importTransactions(&testUser, transactions, DuplicateOptions{Action: DuplicatesIgnore}, result)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+	assert.Len(t, result.Skipped, 1)
+	assert.Contains(t, result.Skipped[0], "transaction Invalid: cannot parse date 2019-13-20")
+
+	saved, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	assert.Len(t, saved, 1)
+	assert.Equal(t, "Valid", saved[0].Description)
+	assert.Equal(t, "2019-03-20", saved[0].Date)
+}
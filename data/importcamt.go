@@ -0,0 +1,232 @@
+package data
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// camtDocument is an ISO 20022 camt.053 (statement) or camt.052 (account report) document.
+// Namespaces are ignored, so that all versions of the format can be parsed.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+	Reports    []camtStatement `xml:"BkToCstmrAcctRpt>Rpt"`
+}
+
+// camtStatement is a Stmt or Rpt element.
+type camtStatement struct {
+	ID       string      `xml:"Id"`
+	Currency string      `xml:"Acct>Ccy"`
+	Entries  []camtEntry `xml:"Ntry"`
+}
+
+// camtEntry is an Ntry element.
+type camtEntry struct {
+	Reference             string        `xml:"NtryRef"`
+	ServicerReference     string        `xml:"AcctSvcrRef"`
+	Amount                camtAmount    `xml:"Amt"`
+	CreditDebitIndicator  string        `xml:"CdtDbtInd"`
+	Status                camtStatus    `xml:"Sts"`
+	BookingDate           camtDate      `xml:"BookgDt"`
+	ValueDate             camtDate      `xml:"ValDt"`
+	AdditionalInformation string        `xml:"AddtlNtryInf"`
+	Details               []camtDetails `xml:"NtryDtls>TxDtls"`
+}
+
+// camtAmount is an amount with a currency.
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// camtStatus is the entry status.
+// Older versions contain the code directly, newer versions use a Cd element.
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+// camtDate is a date or date and time.
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// camtParty is a debtor or creditor.
+// Older versions contain the name directly, newer versions use a Pty element.
+type camtParty struct {
+	Name      string `xml:"Nm"`
+	PartyName string `xml:"Pty>Nm"`
+}
+
+// camtDetails is a TxDtls element.
+type camtDetails struct {
+	Debtor       camtParty `xml:"RltdPties>Dbtr"`
+	Creditor     camtParty `xml:"RltdPties>Cdtr"`
+	Unstructured []string  `xml:"RmtInf>Ustrd"`
+}
+
+// get returns the status code.
+func (status camtStatus) get() string {
+	if status.Code != "" {
+		return strings.TrimSpace(status.Code)
+	}
+	return strings.TrimSpace(status.Value)
+}
+
+// get returns the date part of the value.
+func (date camtDate) get() string {
+	value := strings.TrimSpace(date.Date)
+	if value == "" {
+		value = strings.TrimSpace(date.DateTime)
+	}
+	if len(value) > len(dateFormat) {
+		value = value[:len(dateFormat)]
+	}
+	return value
+}
+
+// get returns the party name.
+func (party camtParty) get() string {
+	if party.Name != "" {
+		return strings.TrimSpace(party.Name)
+	}
+	return strings.TrimSpace(party.PartyName)
+}
+
+// parseCamt parses a camt.053 or camt.052 document.
+func parseCamt(reader io.Reader) ([]camtStatement, error) {
+	document := &camtDocument{}
+	if err := xml.NewDecoder(reader).Decode(document); err != nil {
+		return nil, fmt.Errorf("cannot parse camt document: %w", err)
+	}
+	statements := append(document.Statements, document.Reports...)
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("camt document has no statements")
+	}
+	return statements, nil
+}
+
+// entryID returns the reference which uniquely identifies the entry.
+func (entry *camtEntry) entryID() string {
+	if reference := strings.TrimSpace(entry.Reference); reference != "" {
+		return reference
+	}
+	return strings.TrimSpace(entry.ServicerReference)
+}
+
+// description returns the counterparty name and remittance information of the entry.
+func (entry *camtEntry) description(credit bool) string {
+	var counterparty string
+	remittance := make([]string, 0, len(entry.Details))
+	for _, details := range entry.Details {
+		if counterparty == "" {
+			if credit {
+				counterparty = details.Debtor.get()
+			} else {
+				counterparty = details.Creditor.get()
+			}
+		}
+		for _, line := range details.Unstructured {
+			if line = strings.TrimSpace(line); line != "" {
+				remittance = append(remittance, line)
+			}
+		}
+	}
+	description := joinDescription(counterparty, strings.Join(remittance, " "))
+	if description == "" {
+		description = strings.TrimSpace(entry.AdditionalInformation)
+	}
+	return description
+}
+
+// convert creates a Transaction from a camt entry.
+func (entry *camtEntry) convert(accountUUID string, currency string) (*importedTransaction, error) {
+	if status := entry.Status.get(); status != "" && status != "BOOK" {
+		return nil, fmt.Errorf("entry is not booked (status %v)", status)
+	}
+	if entry.Amount.Currency != "" && entry.Amount.Currency != currency {
+		return nil, fmt.Errorf("entry currency %v doesn't match statement currency %v", entry.Amount.Currency, currency)
+	}
+
+	date := entry.BookingDate.get()
+	if date == "" {
+		date = entry.ValueDate.get()
+	}
+	if _, err := time.Parse(dateFormat, date); err != nil {
+		return nil, fmt.Errorf("invalid date %v", date)
+	}
+
+	amount, err := parseAmount(strings.TrimSpace(entry.Amount.Value), ".")
+	if err != nil {
+		return nil, err
+	}
+	if amount < 0 {
+		amount = -amount
+	}
+	var credit bool
+	switch indicator := strings.TrimSpace(entry.CreditDebitIndicator); indicator {
+	case "CRDT":
+		credit = true
+	case "DBIT":
+		amount = -amount
+	default:
+		return nil, fmt.Errorf("invalid credit/debit indicator %v", indicator)
+	}
+
+	return &importedTransaction{
+		Transaction: &Transaction{
+			Description: entry.description(credit),
+			Type:        TransactionTypeExpenseIncome,
+			Date:        date,
+			Components:  []TransactionComponent{{AccountUUID: accountUUID, Amount: amount}},
+		},
+		AccountUUID: accountUUID,
+		EntryID:     entry.entryID(),
+	}, nil
+}
+
+// ImportCamt imports entries from a camt.053 statement or camt.052 account report into accountUUID.
+// Entries are identified by their reference, so that importing an overlapping statement doesn't create duplicates.
+func (s *DBService) ImportCamt(user *User, accountUUID string, duplicates DuplicateOptions, reader io.Reader) (*ImportResult, error) {
+	statements, err := parseCamt(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	err = s.update(user, func(s *DBService) error {
+		account, err := s.getImportAccount(user, accountUUID, "")
+		if err != nil {
+			return err
+		}
+
+		transactions := make([]*importedTransaction, 0)
+		for _, statement := range statements {
+			currency := strings.TrimSpace(statement.Currency)
+			if currency == "" {
+				currency = account.Currency
+			}
+			if currency != account.Currency {
+				return fmt.Errorf("statement %v currency %v doesn't match account %v currency %v", statement.ID, currency, account.Name, account.Currency)
+			}
+
+			for i := range statement.Entries {
+				entry := &statement.Entries[i]
+				transaction, err := entry.convert(accountUUID, currency)
+				if err != nil {
+					result.Skipped = append(result.Skipped, fmt.Sprintf("statement %v entry %v (reference %v): %v", statement.ID, i+1, entry.entryID(), err))
+					continue
+				}
+				transactions = append(transactions, transaction)
+			}
+		}
+		return s.importTransactions(user, transactions, duplicates, result)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import transactions: %w", err)
+	}
+	return result, nil
+}
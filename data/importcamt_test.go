@@ -0,0 +1,162 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCamt053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>MSG1</MsgId><CreDtTm>2019-03-05T10:00:00</CreDtTm></GrpHdr>
+    <Stmt>
+      <Id>STMT1</Id>
+      <Acct><Id><IBAN>DE00123456780000000000</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Ntry>
+        <NtryRef>REF1</NtryRef>
+        <Amt Ccy="EUR">1000.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2019-03-01</Dt></BookgDt>
+        <ValDt><Dt>2019-03-01</Dt></ValDt>
+        <NtryDtls><TxDtls>
+          <RltdPties><Dbtr><Nm>Employer GmbH</Nm></Dbtr></RltdPties>
+          <RmtInf><Ustrd>Salary</Ustrd><Ustrd>March</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <NtryRef>REF2</NtryRef>
+        <Amt Ccy="EUR">12.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2019-03-02T12:00:00+01:00</DtTm></BookgDt>
+        <NtryDtls><TxDtls>
+          <RltdPties><Cdtr><Pty><Nm>Supermarket</Nm></Pty></Cdtr></RltdPties>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <AcctSvcrRef>REF3</AcctSvcrRef>
+        <Amt Ccy="EUR">3.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2019-03-03</Dt></BookgDt>
+        <AddtlNtryInf>Account fee</AddtlNtryInf>
+      </Ntry>
+      <Ntry>
+        <NtryRef>REF4</NtryRef>
+        <Amt Ccy="EUR">1.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2019-03-04</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+
+const testCamt052 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.052.001.08">
+  <BkToCstmrAcctRpt>
+    <Rpt>
+      <Id>RPT1</Id>
+      <Acct><Ccy>EUR</Ccy></Acct>
+      <Ntry>
+        <NtryRef>REF3</NtryRef>
+        <Amt Ccy="EUR">3.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2019-03-03</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <NtryRef>REF5</NtryRef>
+        <Amt Ccy="EUR">4.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2019-03-05</Dt></BookgDt>
+        <AddtlNtryInf>Coffee</AddtlNtryInf>
+      </Ntry>
+    </Rpt>
+  </BkToCstmrAcctRpt>
+</Document>
+`
+
+func TestImportCamt053(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportCamt(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testCamt053))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 3,
+		Skipped:  []string{"statement STMT1 entry 4 (reference REF4): entry is not booked (status PDNG)"},
+	}, result)
+
+	assert.Equal(t, []*Transaction{{
+		Description: "Account fee",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-03",
+		Components:  []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: -300}},
+	}, {
+		Description: "Supermarket",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-02",
+		Components:  []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: -1250}},
+	}, {
+		Description: "Employer GmbH - Salary March",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-01",
+		Components:  []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: 100000}},
+	}}, getImportedTransactions(t))
+}
+
+func TestImportOverlappingCamt(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	_, err = dbService.ImportCamt(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testCamt053))
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportCamt(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testCamt053))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Imported)
+	assert.Equal(t, 3, result.AlreadyImported)
+
+	result, err = dbService.ImportCamt(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testCamt052))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{Imported: 1, AlreadyImported: 1}, result)
+
+	account, err := dbService.GetAccount(&testUser, testAccount2.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(98050), account.Balance)
+}
+
+func TestImportCamtCurrencyMismatch(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportCamt(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader(testCamt053))
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Empty(t, getImportedTransactions(t))
+}
+
+func TestImportInvalidCamt(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	_, err = dbService.ImportCamt(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader("<Document><BkToCstmrStmt>"))
+	assert.Error(t, err)
+
+	_, err = dbService.ImportCamt(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader("<Document></Document>"))
+	assert.Error(t, err)
+}
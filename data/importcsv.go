@@ -0,0 +1,170 @@
+package data
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// csvColumns maps CSV header names to column indexes.
+type csvColumns map[string]int
+
+// get returns the value of column from record.
+// If column is not used, returns an empty string.
+func (columns csvColumns) get(record []string, column string) string {
+	if column == "" {
+		return ""
+	}
+	i := columns[column]
+	if i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// readCSVHeader reads the header row and checks that it contains all columns used by profile.
+func readCSVHeader(reader *csv.Reader, profile *ImportProfile) (csvColumns, error) {
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CSV header: %w", err)
+	}
+	columns := make(csvColumns)
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	requiredColumns := []string{profile.DateColumn, profile.AmountColumn, profile.DebitColumn, profile.CreditColumn, profile.DescriptionColumn}
+	for _, column := range requiredColumns {
+		if column == "" {
+			continue
+		}
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("CSV file has no %v column", column)
+		}
+	}
+	return columns, nil
+}
+
+// parseCSVRecord converts a CSV record into a Transaction.
+func parseCSVRecord(profile *ImportProfile, columns csvColumns, record []string) (*Transaction, error) {
+	layout := profile.DateFormat
+	if layout == "" {
+		layout = inputDateFormat
+	}
+	dateValue := columns.get(record, profile.DateColumn)
+	date, err := time.Parse(layout, dateValue)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse date %v: %w", dateValue, err)
+	}
+
+	var amount int64
+	if value := columns.get(record, profile.AmountColumn); value != "" {
+		amount, err = parseAmount(value, profile.DecimalSeparator)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value := columns.get(record, profile.CreditColumn); value != "" {
+		credit, err := parseAmount(value, profile.DecimalSeparator)
+		if err != nil {
+			return nil, err
+		}
+		if credit < 0 {
+			credit = -credit
+		}
+		amount += credit
+	}
+	if value := columns.get(record, profile.DebitColumn); value != "" {
+		debit, err := parseAmount(value, profile.DecimalSeparator)
+		if err != nil {
+			return nil, err
+		}
+		if debit < 0 {
+			debit = -debit
+		}
+		amount -= debit
+	}
+	if amount == 0 {
+		return nil, fmt.Errorf("amount is empty")
+	}
+
+	return &Transaction{
+		Description: columns.get(record, profile.DescriptionColumn),
+		Type:        TransactionTypeExpenseIncome,
+		Date:        date.Format(dateFormat),
+		Components:  []TransactionComponent{{AccountUUID: profile.AccountUUID, Amount: amount}},
+	}, nil
+}
+
+// ImportCSV imports transactions from a CSV statement using the column mapping from the profileUUID ImportProfile.
+// The first row of the CSV file should contain column names.
+// Rows that cannot be parsed are skipped and listed in the returned ImportResult.
+func (s *DBService) ImportCSV(user *User, profileUUID string, duplicates DuplicateOptions, reader io.Reader) (*ImportResult, error) {
+	profile, err := s.GetImportProfile(user, profileUUID)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return nil, fmt.Errorf("import profile %v doesn't exist", profileUUID)
+	}
+	if err := profile.validate(); err != nil {
+		return nil, err
+	}
+
+	csvReader := csv.NewReader(reader)
+	if profile.Delimiter != "" {
+		csvReader.Comma = []rune(profile.Delimiter)[0]
+	}
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	columns, err := readCSVHeader(csvReader, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	transactions := make([]*importedTransaction, 0)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseError *csv.ParseError
+			if errors.As(err, &parseError) {
+				result.skip(parseError.Line, parseError.Err.Error())
+				continue
+			}
+			return nil, fmt.Errorf("cannot read CSV file: %w", err)
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		transaction, err := parseCSVRecord(profile, columns, record)
+		if err != nil {
+			result.skip(line, err.Error())
+			continue
+		}
+		transactions = append(transactions, &importedTransaction{Transaction: transaction, AccountUUID: profile.AccountUUID})
+	}
+
+	err = s.update(user, func(s *DBService) error {
+		if _, err := s.getImportAccount(user, profile.AccountUUID, ""); err != nil {
+			return err
+		}
+		return s.importTransactions(user, transactions, duplicates, result)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import transactions: %w", err)
+	}
+	return result, nil
+}
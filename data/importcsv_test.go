@@ -0,0 +1,120 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportCSV(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	profile := createTestImportProfile()
+	profile.AccountUUID = testAccount1.UUID
+	err = dbService.CreateImportProfile(&testUser, &profile)
+	assert.NoError(t, err)
+
+	csvData := "\ufeffDate;Description;Amount\n" +
+		"01.03.2019;Salary;\"1.000,00\"\n" +
+		"02.03.2019;Groceries;-12,50\n" +
+		"\n" +
+		"03.03.2019;Bad amount;twelve\n" +
+		"yesterday;Bad date;1,00\n"
+
+	result, err := dbService.ImportCSV(&testUser, profile.UUID, DuplicateOptions{}, strings.NewReader(csvData))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 2,
+		Skipped: []string{
+			`line 5: invalid character 't' in amount twelve`,
+			`line 6: cannot parse date yesterday: parsing time "yesterday" as "02.01.2006": cannot parse "yesterday" as "02"`,
+		},
+	}, result)
+
+	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	for _, transaction := range transactions {
+		transaction.UUID = ""
+	}
+	assert.Equal(t, []*Transaction{{
+		Description: "Groceries",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-02",
+		Components:  []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: -1250}},
+	}, {
+		Description: "Salary",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-01",
+		Components:  []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 100000}},
+	}}, transactions)
+
+	account, err := dbService.GetAccount(&testUser, testAccount1.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(98750), account.Balance)
+}
+
+func TestImportCSVDebitCredit(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	profile := ImportProfile{
+		AccountUUID:       testAccount2.UUID,
+		DateColumn:        "Booked",
+		DebitColumn:       "Out",
+		CreditColumn:      "In",
+		DescriptionColumn: "Details",
+	}
+	err = dbService.CreateImportProfile(&testUser, &profile)
+	assert.NoError(t, err)
+
+	csvData := "Booked,Details,Out,In\n" +
+		"2019-3-1,Refund,,10.00\n" +
+		"2019-3-2,Rent,\"1,200.00\",\n"
+
+	result, err := dbService.ImportCSV(&testUser, profile.UUID, DuplicateOptions{}, strings.NewReader(csvData))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{Imported: 2}, result)
+
+	account, err := dbService.GetAccount(&testUser, testAccount2.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-119000), account.Balance)
+}
+
+func TestImportCSVMissingColumn(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	profile := createTestImportProfile()
+	profile.AccountUUID = testAccount1.UUID
+	err = dbService.CreateImportProfile(&testUser, &profile)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportCSV(&testUser, profile.UUID, DuplicateOptions{}, strings.NewReader("Date;Amount\n01.03.2019;1,00\n"))
+	assert.Error(t, err)
+	assert.Nil(t, result)
+
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+}
+
+func TestImportCSVMissingAccount(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	profile := createTestImportProfile()
+	err = dbService.CreateImportProfile(&testUser, &profile)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportCSV(&testUser, profile.UUID, DuplicateOptions{}, strings.NewReader("Date;Description;Amount\n01.03.2019;Salary;1,00\n"))
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,625 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// journalCurrencySymbols maps common currency symbols to currency codes.
+var journalCurrencySymbols = map[string]string{
+	"$": "USD",
+	"€": "EUR",
+	"£": "GBP",
+	"¥": "JPY",
+}
+
+// journalImportPosting is a posting parsed from a journal.
+type journalImportPosting struct {
+	Account string
+	// HasAmount is false if the amount was elided and should be inferred.
+	HasAmount bool
+	Amount    int64
+	Currency  string
+	// Weight is the amount used to balance the entry (the cost if the posting has a price).
+	Weight         int64
+	WeightCurrency string
+}
+
+// journalImportEntry is a transaction parsed from a journal.
+type journalImportEntry struct {
+	Line        int
+	Date        string
+	Description string
+	Tags        []string
+	Postings    []*journalImportPosting
+	// Balanced is true if the postings are balanced in each currency.
+	Balanced bool
+	// Err is the first error encountered while parsing the entry.
+	Err error
+}
+
+// journalImportFile contains accounts and transactions parsed from a journal.
+type journalImportFile struct {
+	// Accounts contains the names of all accounts, in order of their first appearance.
+	Accounts []string
+	// AccountCurrencies contains the currencies of accounts from open directives or postings.
+	AccountCurrencies map[string]string
+	Entries           []*journalImportEntry
+}
+
+// addAccount registers an account name and its currency.
+func (file *journalImportFile) addAccount(name string, currency string) {
+	if _, ok := file.AccountCurrencies[name]; !ok {
+		file.Accounts = append(file.Accounts, name)
+		file.AccountCurrencies[name] = ""
+	}
+	if file.AccountCurrencies[name] == "" {
+		file.AccountCurrencies[name] = currency
+	}
+}
+
+// parseJournalDate parses a journal date in the YYYY-MM-DD, YYYY/MM/DD or YYYY.MM.DD format.
+func parseJournalDate(value string) (string, error) {
+	if i := strings.IndexByte(value, '='); i >= 0 {
+		// Ignore the ledger auxiliary date.
+		value = value[:i]
+	}
+	value = strings.NewReplacer("/", "-", ".", "-").Replace(value)
+	date, err := time.Parse(inputDateFormat, value)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse date %v: %w", value, err)
+	}
+	return date.Format(dateFormat), nil
+}
+
+// unquoteJournalString removes quotes and escapes from a beancount string.
+func unquoteJournalString(value string) string {
+	value = strings.TrimSpace(value)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return strings.Trim(value, `"`)
+}
+
+// splitJournalAmount splits an amount into its number and currency, for example "12.50 USD", "$-12.50" or "-12.50€".
+func splitJournalAmount(value string) (string, string, error) {
+	isNumber := func(c rune) bool {
+		return unicode.IsDigit(c) || c == '.' || c == ',' || c == '-' || c == '+'
+	}
+	var number, currency string
+	fields := strings.Fields(value)
+	switch len(fields) {
+	case 1:
+		// The sign can be before or after a currency symbol, for example -$12.50 or $-12.50.
+		token, sign := fields[0], ""
+		if strings.HasPrefix(token, "-") || strings.HasPrefix(token, "+") {
+			sign, token = token[:1], token[1:]
+		}
+		start := strings.IndexFunc(token, isNumber)
+		if start < 0 {
+			return "", "", fmt.Errorf("amount %v has no digits", value)
+		}
+		prefix := token[:start]
+		token = token[start:]
+		if sign == "" && (strings.HasPrefix(token, "-") || strings.HasPrefix(token, "+")) {
+			sign, token = token[:1], token[1:]
+		}
+		end := strings.IndexFunc(token, func(c rune) bool { return !isNumber(c) })
+		if end < 0 {
+			end = len(token)
+		}
+		suffix := token[end:]
+		if prefix != "" && suffix != "" {
+			return "", "", fmt.Errorf("invalid amount %v", value)
+		}
+		number, currency = sign+token[:end], prefix+suffix
+	case 2:
+		number, currency = fields[0], fields[1]
+		if strings.IndexFunc(number, unicode.IsDigit) < 0 {
+			number, currency = currency, number
+		}
+	default:
+		return "", "", fmt.Errorf("invalid amount %v", value)
+	}
+	for _, c := range number {
+		if !isNumber(c) {
+			return "", "", fmt.Errorf("invalid amount %v", value)
+		}
+	}
+
+	currency = unquoteJournalString(currency)
+	if code, ok := journalCurrencySymbols[currency]; ok {
+		currency = code
+	}
+	return number, currency, nil
+}
+
+// parseJournalAmount parses an amount and its currency, for example "12.50 USD", "$-12.50" or "-12.50€".
+func parseJournalAmount(value string) (int64, string, error) {
+	number, currency, err := splitJournalAmount(value)
+	if err != nil {
+		return 0, "", err
+	}
+	amount, err := parseAmount(number, ".")
+	if err != nil {
+		return 0, "", err
+	}
+	return amount, currency, nil
+}
+
+// parseJournalPrice parses a price and its currency, like parseJournalAmount.
+// Unlike amounts, prices can have any number of decimal places.
+func parseJournalPrice(value string) (*big.Rat, string, error) {
+	number, currency, err := splitJournalAmount(value)
+	if err != nil {
+		return nil, "", err
+	}
+	price, ok := new(big.Rat).SetString(strings.ReplaceAll(strings.TrimPrefix(number, "+"), ",", ""))
+	if !ok {
+		return nil, "", fmt.Errorf("invalid price %v", value)
+	}
+	return price, currency, nil
+}
+
+// roundJournalAmount rounds value to the nearest amount, rounding halves away from zero.
+func roundJournalAmount(value *big.Rat) (int64, error) {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	remainder.Abs(remainder).Lsh(remainder, 1)
+	if remainder.Cmp(value.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	}
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("amount %v is too large", value.FloatString(amountDecimals))
+	}
+	return quotient.Int64(), nil
+}
+
+// parseJournalPosting parses a posting line, for example "Assets:Checking  -12.50 USD @ 1.10 EUR".
+func parseJournalPosting(value string, format string) (*journalImportPosting, error) {
+	if i := strings.IndexByte(value, ';'); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "* ") || strings.HasPrefix(value, "! ") {
+		value = strings.TrimSpace(value[2:])
+	}
+
+	account, amountValue := value, ""
+	var separator int
+	if format == JournalFormatBeancount {
+		separator = strings.IndexFunc(value, unicode.IsSpace)
+	} else {
+		// Ledger account names can contain single spaces.
+		separator = strings.Index(value, "  ")
+		if tab := strings.IndexByte(value, '\t'); tab >= 0 && (separator < 0 || tab < separator) {
+			separator = tab
+		}
+	}
+	if separator >= 0 {
+		account, amountValue = value[:separator], strings.TrimSpace(value[separator:])
+	}
+	// Virtual postings are imported as regular postings.
+	account = strings.Trim(account, "()[]")
+	if account == "" {
+		return nil, fmt.Errorf("posting has no account")
+	}
+	posting := &journalImportPosting{Account: account}
+
+	// Ignore balance assertions.
+	if i := strings.IndexByte(amountValue, '='); i >= 0 {
+		amountValue = strings.TrimSpace(amountValue[:i])
+	}
+	if amountValue == "" {
+		return posting, nil
+	}
+
+	var priceValue string
+	var totalPrice bool
+	if i := strings.IndexByte(amountValue, '{'); i >= 0 {
+		end := strings.LastIndexByte(amountValue, '}')
+		if end < i {
+			return nil, fmt.Errorf("unterminated cost %v", amountValue)
+		}
+		priceValue = strings.TrimSpace(amountValue[i+1 : end])
+		totalPrice = strings.HasPrefix(priceValue, "{")
+		priceValue = strings.Trim(priceValue, "{} ")
+		amountValue = strings.TrimSpace(amountValue[:i] + amountValue[end+1:])
+	}
+	if i := strings.IndexByte(amountValue, '@'); i >= 0 {
+		priceValue = amountValue[i+1:]
+		totalPrice = strings.HasPrefix(priceValue, "@")
+		priceValue = strings.TrimSpace(strings.TrimPrefix(priceValue, "@"))
+		amountValue = strings.TrimSpace(amountValue[:i])
+	}
+
+	amount, currency, err := parseJournalAmount(amountValue)
+	if err != nil {
+		return nil, err
+	}
+	posting.HasAmount = true
+	posting.Amount, posting.Currency = amount, currency
+	posting.Weight, posting.WeightCurrency = amount, currency
+	if priceValue != "" {
+		price, priceCurrency, err := parseJournalPrice(priceValue)
+		if err != nil {
+			return nil, err
+		}
+		// The weight is calculated in amounts, and rounded only once.
+		weight := new(big.Rat)
+		if totalPrice {
+			weight.Abs(price).Mul(weight, big.NewRat(100, 1))
+			if amount < 0 {
+				weight.Neg(weight)
+			}
+		} else {
+			weight.Mul(price, big.NewRat(amount, 1))
+		}
+		if posting.Weight, err = roundJournalAmount(weight); err != nil {
+			return nil, err
+		}
+		posting.WeightCurrency = priceCurrency
+	}
+	return posting, nil
+}
+
+// parseJournalCommentTags returns tags from a ledger or hledger comment:
+// ":tag1:tag2:" tags, a "tags: tag1, tag2" value, "tag1:, tag2:" hledger tags without values
+// or "tag:tag1, tag:tag2" hledger tags.
+func parseJournalCommentTags(comment string) []string {
+	comment = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(comment), ";#"))
+	tags := make([]string, 0)
+	switch {
+	case strings.HasPrefix(comment, "tags:"):
+		for _, tag := range strings.Split(strings.TrimPrefix(comment, "tags:"), ",") {
+			tags = append(tags, strings.TrimSpace(tag))
+		}
+	case len(comment) > 1 && strings.HasPrefix(comment, ":") && strings.HasSuffix(comment, ":") && !strings.ContainsAny(comment, " \t"):
+		tags = append(tags, strings.Split(strings.Trim(comment, ":"), ":")...)
+	default:
+		for _, part := range strings.Split(comment, ",") {
+			part = strings.TrimSpace(part)
+			if strings.HasPrefix(part, "tag:") {
+				tags = append(tags, strings.TrimSpace(strings.TrimPrefix(part, "tag:")))
+			} else if name, value, ok := strings.Cut(part, ":"); ok && name != "" && value == "" && !strings.ContainsAny(name, " \t") {
+				tags = append(tags, name)
+			}
+		}
+	}
+	return tags
+}
+
+// parseBeancountHeader parses the payee, narration and tags of a beancount transaction.
+func parseBeancountHeader(value string, entry *journalImportEntry) error {
+	values := make([]string, 0, 2)
+	for len(value) > 0 {
+		c := value[0]
+		switch {
+		case c == ' ' || c == '\t':
+			value = value[1:]
+		case c == ';':
+			value = ""
+		case c == '"':
+			end := 1
+			for end < len(value) && value[end] != '"' {
+				if value[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(value) {
+				return fmt.Errorf("unterminated string %v", value)
+			}
+			values = append(values, unquoteJournalString(value[:end+1]))
+			value = value[end+1:]
+		default:
+			end := 0
+			for end < len(value) && value[end] != ' ' && value[end] != '\t' {
+				end++
+			}
+			if token := value[:end]; token[0] == '#' {
+				entry.Tags = append(entry.Tags, token[1:])
+			} else if token[0] != '^' {
+				return fmt.Errorf("unexpected token %v", token)
+			}
+			value = value[end:]
+		}
+	}
+	switch len(values) {
+	case 0:
+	case 1:
+		entry.Description = values[0]
+	default:
+		entry.Description = joinDescription(values[0], values[1])
+	}
+	return nil
+}
+
+// parseLedgerHeader parses the description and tags of a ledger transaction.
+func parseLedgerHeader(value string, entry *journalImportEntry) {
+	if i := strings.IndexByte(value, ';'); i >= 0 {
+		entry.Tags = append(entry.Tags, parseJournalCommentTags(value[i+1:])...)
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "*") || strings.HasPrefix(value, "!") {
+		value = strings.TrimSpace(value[1:])
+	}
+	if strings.HasPrefix(value, "(") {
+		if end := strings.IndexByte(value, ')'); end >= 0 {
+			value = strings.TrimSpace(value[end+1:])
+		}
+	}
+	entry.Description = value
+}
+
+// parseJournal parses a beancount or ledger journal.
+// Unsupported directives are added to result.
+func parseJournal(reader io.Reader, format string, result *ImportResult) (*journalImportFile, error) {
+	file := &journalImportFile{AccountCurrencies: make(map[string]string)}
+	var entry *journalImportEntry
+	// skipBlock is true if indented lines belong to an ignored directive.
+	skipBlock := false
+	pushedTags := make([]string, 0)
+
+	finishEntry := func() {
+		if entry != nil {
+			file.Entries = append(file.Entries, entry)
+		}
+		entry = nil
+	}
+	setError := func(err error) {
+		if entry.Err == nil {
+			entry.Err = err
+		}
+	}
+
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		value := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == 1 {
+			value = strings.TrimPrefix(value, "\ufeff")
+		}
+		if strings.TrimSpace(value) == "" {
+			finishEntry()
+			skipBlock = false
+			continue
+		}
+
+		if value[0] == ' ' || value[0] == '\t' {
+			trimmed := strings.TrimSpace(value)
+			switch {
+			case entry == nil || skipBlock:
+			case trimmed[0] == ';' || trimmed[0] == '#':
+				entry.Tags = append(entry.Tags, parseJournalCommentTags(trimmed)...)
+			case format == JournalFormatBeancount && unicode.IsLower(rune(trimmed[0])):
+				// Metadata.
+				if key, metadataValue, ok := strings.Cut(trimmed, ":"); ok && key == "tags" {
+					for _, tag := range strings.Split(unquoteJournalString(metadataValue), ",") {
+						entry.Tags = append(entry.Tags, strings.TrimSpace(tag))
+					}
+				}
+			default:
+				posting, err := parseJournalPosting(trimmed, format)
+				if err != nil {
+					setError(fmt.Errorf("line %v: %w", line, err))
+					continue
+				}
+				entry.Postings = append(entry.Postings, posting)
+			}
+			continue
+		}
+
+		finishEntry()
+		skipBlock = false
+		if strings.ContainsRune(";#%|*", rune(value[0])) {
+			continue
+		}
+
+		directive, rest, _ := strings.Cut(value, " ")
+		rest = strings.TrimSpace(rest)
+		if !unicode.IsDigit(rune(value[0])) {
+			switch {
+			case format == JournalFormatBeancount && directive == "pushtag":
+				pushedTags = append(pushedTags, strings.TrimPrefix(rest, "#"))
+			case format == JournalFormatBeancount && directive == "poptag":
+				tag := strings.TrimPrefix(rest, "#")
+				for i := len(pushedTags) - 1; i >= 0; i-- {
+					if pushedTags[i] == tag {
+						pushedTags = append(pushedTags[:i], pushedTags[i+1:]...)
+						break
+					}
+				}
+			case format != JournalFormatBeancount && directive == "account":
+				file.addAccount(rest, "")
+				skipBlock = true
+			case format != JournalFormatBeancount && directive == "commodity":
+				skipBlock = true
+			default:
+				result.skip(line, fmt.Sprintf("unsupported directive %v", directive))
+				skipBlock = true
+			}
+			continue
+		}
+
+		date, err := parseJournalDate(directive)
+		if err != nil {
+			result.skip(line, err.Error())
+			skipBlock = true
+			continue
+		}
+
+		if format == JournalFormatBeancount {
+			keyword, arguments, _ := strings.Cut(rest, " ")
+			switch keyword {
+			case "open":
+				fields := strings.Fields(arguments)
+				if len(fields) == 0 {
+					result.skip(line, "open directive has no account")
+					continue
+				}
+				currency := ""
+				if len(fields) > 1 {
+					currency, _, _ = strings.Cut(fields[1], ",")
+				}
+				file.addAccount(fields[0], currency)
+				skipBlock = true
+				continue
+			case "commodity":
+				skipBlock = true
+				continue
+			case "*", "!", "txn":
+				entry = &journalImportEntry{Line: line, Date: date, Tags: append([]string{}, pushedTags...)}
+				if err := parseBeancountHeader(arguments, entry); err != nil {
+					setError(err)
+				}
+				continue
+			default:
+				result.skip(line, fmt.Sprintf("unsupported directive %v", keyword))
+				skipBlock = true
+				continue
+			}
+		}
+
+		entry = &journalImportEntry{Line: line, Date: date}
+		parseLedgerHeader(rest, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read journal: %w", err)
+	}
+	finishEntry()
+
+	for _, entry := range file.Entries {
+		if entry.Err != nil {
+			continue
+		}
+		if entry.Balanced, entry.Err = entry.balance(); entry.Err != nil {
+			continue
+		}
+		for _, posting := range entry.Postings {
+			file.addAccount(posting.Account, posting.Currency)
+		}
+	}
+	return file, nil
+}
+
+// balance infers the elided posting amount, and returns true if the entry is balanced in each currency.
+func (entry *journalImportEntry) balance() (bool, error) {
+	residuals := make(map[string]int64)
+	var elided *journalImportPosting
+	for _, posting := range entry.Postings {
+		if !posting.HasAmount {
+			if elided != nil {
+				return false, fmt.Errorf("more than one posting has no amount")
+			}
+			elided = posting
+			continue
+		}
+		residuals[posting.WeightCurrency] += posting.Weight
+	}
+
+	if elided != nil {
+		for currency, residual := range residuals {
+			if residual == 0 {
+				continue
+			}
+			if elided.HasAmount {
+				return false, fmt.Errorf("cannot infer posting amount in multiple currencies")
+			}
+			elided.HasAmount = true
+			elided.Amount, elided.Currency = -residual, currency
+			elided.Weight, elided.WeightCurrency = -residual, currency
+			residuals[currency] = 0
+		}
+		elided.HasAmount = true
+	}
+
+	for _, residual := range residuals {
+		if residual != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// convert creates a Transaction from a journal entry.
+func (entry *journalImportEntry) convert(accounts map[string]*Account) (*importedTransaction, error) {
+	if entry.Err != nil {
+		return nil, entry.Err
+	}
+	if len(entry.Postings) == 0 {
+		return nil, fmt.Errorf("transaction has no postings")
+	}
+	transaction := &Transaction{
+		Description: entry.Description,
+		Type:        TransactionTypeExpenseIncome,
+		Tags:        entry.Tags,
+		Date:        entry.Date,
+		Components:  make([]TransactionComponent, 0, len(entry.Postings)),
+	}
+	accountsUsed := make(map[string]bool)
+	for _, posting := range entry.Postings {
+		account := accounts[posting.Account]
+		if account == nil {
+			return nil, fmt.Errorf("account %v doesn't exist", posting.Account)
+		}
+		if posting.Currency != "" && account.Currency != "" && posting.Currency != account.Currency {
+			return nil, fmt.Errorf("posting currency %v doesn't match account %v currency %v", posting.Currency, account.Name, account.Currency)
+		}
+		accountsUsed[account.UUID] = true
+		transaction.Components = append(transaction.Components, TransactionComponent{AccountUUID: account.UUID, Amount: posting.Amount})
+	}
+	if entry.Balanced && len(accountsUsed) > 1 {
+		transaction.Type = TransactionTypeTransfer
+	}
+	return &importedTransaction{Transaction: transaction}, nil
+}
+
+// ImportJournal imports accounts and transactions from a beancount or ledger (hledger) journal.
+// Accounts are created from open (account) directives and posting account names, and each posting
+// is imported as a separate component. Balanced entries with multiple accounts are imported as transfers.
+func (s *DBService) ImportJournal(user *User, format string, duplicates DuplicateOptions, reader io.Reader) (*ImportResult, error) {
+	switch format {
+	case JournalFormatBeancount, JournalFormatLedger, JournalFormatHledger:
+	default:
+		return nil, fmt.Errorf("unsupported journal format %v", format)
+	}
+
+	result := &ImportResult{}
+	file, err := parseJournal(reader, format, result)
+	if err != nil {
+		return nil, err
+	}
+
+	newAccount := func(name string) (*Account, error) {
+		return &Account{Name: name, Currency: file.AccountCurrencies[name], IncludeInTotal: true, ShowInList: true}, nil
+	}
+	err = s.update(user, func(s *DBService) error {
+		accounts, err := s.getImportAccounts(user, file.Accounts, newAccount, result)
+		if err != nil {
+			return err
+		}
+
+		transactions := make([]*importedTransaction, 0, len(file.Entries))
+		for _, entry := range file.Entries {
+			transaction, err := entry.convert(accounts)
+			if err != nil {
+				result.skip(entry.Line, err.Error())
+				continue
+			}
+			transactions = append(transactions, transaction)
+		}
+		return s.importTransactions(user, transactions, duplicates, result)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import transactions: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,286 @@
+package data
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBeancount = `; Beancount journal
+option "title" "Test"
+
+2019-01-01 open Assets:Checking USD
+2019-01-01 open Assets:Savings EUR
+2019-01-01 commodity USD
+
+pushtag #trip
+2019-01-02 * "Supermarket" "Weekly shopping" #food
+  Assets:Checking  -15.00 USD
+  Expenses:Groceries
+poptag #trip
+
+2019-01-03 txn "Exchange"
+  tags: "Savings"
+  Assets:Checking  -100.00 USD @@ 90.00 EUR
+  Assets:Savings  90.00 EUR
+
+2019-01-04 balance Assets:Checking  -115.00 USD
+
+2019-01-05 * "Refund"
+  Assets:Checking  5.00 USD
+  Expenses:Groceries
+  Income:Other
+
+2019-01-06 * "Broken"
+  Assets:Checking  1.00 EUR
+  Income:Other  -1.00 EUR
+`
+
+const testLedger = `; Ledger journal
+account Assets:Checking account
+    note Main account
+
+P 2019/01/01 EUR $1.10
+
+2019/01/02 * (123) Supermarket  ; :food:trip:
+    Expenses:Groceries  $15.00
+    Assets:Checking account
+
+2019-01-03=2019-01-04 Salary
+    ; tags: Salary
+    Assets:Checking account  $1,000.00 = $985.00
+    [Income:Salary]  -1000.00 USD
+
+2019/01/05 Cash
+    ; tag:Cash
+    Assets:Checking account  -20 USD
+
+~ Monthly
+    Expenses:Rent  $500.00
+    Assets:Checking account
+`
+
+func TestParseJournalAmount(t *testing.T) {
+	tests := map[string]struct {
+		amount   int64
+		currency string
+	}{
+		"12.50 USD":  {1250, "USD"},
+		"-1,000 EUR": {-100000, "EUR"},
+		"$-12.50":    {-1250, "USD"},
+		"-$12.50":    {-1250, "USD"},
+		"12.50€":     {1250, "EUR"},
+		"GBP 3":      {300, "GBP"},
+		`"ABC" 2.1`:  {210, "ABC"},
+		"42":         {4200, ""},
+	}
+	for value, expected := range tests {
+		amount, currency, err := parseJournalAmount(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected.amount, amount, value)
+		assert.Equal(t, expected.currency, currency, value)
+	}
+
+	for _, value := range []string{"", "USD", "$12 USD", "1 2 3", "1.234 USD"} {
+		_, _, err := parseJournalAmount(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseJournalCommentTags(t *testing.T) {
+	tests := map[string][]string{
+		"; :food:trip:":             {"food", "trip"},
+		"; tags: Food, Household":   {"Food", "Household"},
+		"; tag:Cash, tag:Food":      {"Cash", "Food"},
+		"; Food:, Household_items:": {"Food", "Household_items"},
+		"; Payee: Supermarket":      {},
+		"; Just a comment":          {},
+	}
+	for comment, expected := range tests {
+		assert.Equal(t, expected, parseJournalCommentTags(comment), comment)
+	}
+}
+
+func TestParseJournalPostingPrice(t *testing.T) {
+	tests := map[string]struct {
+		weight         int64
+		weightCurrency string
+	}{
+		"Assets:Checking  -100.00 USD @ 1.0865 EUR":  {-10865, "EUR"},
+		"Assets:Checking  3.00 USD @ 1.23456 EUR":    {370, "EUR"},
+		"Assets:Checking  1.00 USD @ 1.005 EUR":      {101, "EUR"},
+		"Assets:Checking  -1.00 USD @ 1.005 EUR":     {-101, "EUR"},
+		"Assets:Checking  -100.00 USD @@ 90.125 EUR": {-9013, "EUR"},
+		"Assets:Checking  10 ABC {1,000.50 USD}":     {1000500, "USD"},
+		"Assets:Checking  12.50 USD":                 {1250, "USD"},
+	}
+	for value, expected := range tests {
+		posting, err := parseJournalPosting(value, JournalFormatLedger)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected.weight, posting.Weight, value)
+		assert.Equal(t, expected.weightCurrency, posting.WeightCurrency, value)
+	}
+
+	for _, value := range []string{"Assets:Checking  1.00 USD @ 1.0.0 EUR", "Assets:Checking  1.00 USD @ 100000000000000000000 EUR"} {
+		_, err := parseJournalPosting(value, JournalFormatLedger)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestImportBeancount(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportJournal(&testUser, JournalFormatBeancount, DuplicateOptions{}, strings.NewReader(testBeancount))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 2,
+		Skipped: []string{
+			"line 2: unsupported directive option",
+			"line 19: unsupported directive balance",
+			"line 21: more than one posting has no amount",
+			"line 26: posting currency EUR doesn't match account Assets:Checking currency USD",
+		},
+		CreatedAccounts: []string{"Assets:Checking", "Assets:Savings", "Expenses:Groceries", "Income:Other"},
+	}, result)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	accountUUIDs := make(map[string]string)
+	currencies := make(map[string]string)
+	for _, account := range accounts {
+		accountUUIDs[account.Name] = account.UUID
+		currencies[account.Name] = account.Currency
+	}
+	assert.Equal(t, map[string]string{
+		"Test 1":             "USD",
+		"Test 2":             "EUR",
+		"Assets:Checking":    "USD",
+		"Assets:Savings":     "EUR",
+		"Expenses:Groceries": "USD",
+		"Income:Other":       "EUR",
+	}, currencies)
+
+	assert.Equal(t, []*Transaction{{
+		Description: "Exchange",
+		Type:        TransactionTypeTransfer,
+		Tags:        []string{"Savings"},
+		Date:        "2019-01-03",
+		Components: []TransactionComponent{
+			{AccountUUID: accountUUIDs["Assets:Checking"], Amount: -10000},
+			{AccountUUID: accountUUIDs["Assets:Savings"], Amount: 9000},
+		},
+	}, {
+		Description: "Supermarket - Weekly shopping",
+		Type:        TransactionTypeTransfer,
+		Tags:        []string{"food", "trip"},
+		Date:        "2019-01-02",
+		Components: []TransactionComponent{
+			{AccountUUID: accountUUIDs["Assets:Checking"], Amount: -1500},
+			{AccountUUID: accountUUIDs["Expenses:Groceries"], Amount: 1500},
+		},
+	}}, getImportedTransactions(t))
+}
+
+func TestImportLedger(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportJournal(&testUser, JournalFormatLedger, DuplicateOptions{}, strings.NewReader(testLedger))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 3,
+		Skipped: []string{
+			"line 5: unsupported directive P",
+			"line 20: unsupported directive ~",
+		},
+		CreatedAccounts: []string{"Assets:Checking account", "Expenses:Groceries", "Income:Salary"},
+	}, result)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	accountUUIDs := make(map[string]string)
+	for _, account := range accounts {
+		accountUUIDs[account.Name] = account.UUID
+	}
+
+	assert.Equal(t, []*Transaction{{
+		Description: "Cash",
+		Type:        TransactionTypeExpenseIncome,
+		Tags:        []string{"Cash"},
+		Date:        "2019-01-05",
+		Components:  []TransactionComponent{{AccountUUID: accountUUIDs["Assets:Checking account"], Amount: -2000}},
+	}, {
+		Description: "Salary",
+		Type:        TransactionTypeTransfer,
+		Tags:        []string{"Salary"},
+		Date:        "2019-01-03",
+		Components: []TransactionComponent{
+			{AccountUUID: accountUUIDs["Assets:Checking account"], Amount: 100000},
+			{AccountUUID: accountUUIDs["Income:Salary"], Amount: -100000},
+		},
+	}, {
+		Description: "Supermarket",
+		Type:        TransactionTypeTransfer,
+		Tags:        []string{"food", "trip"},
+		Date:        "2019-01-02",
+		Components: []TransactionComponent{
+			{AccountUUID: accountUUIDs["Expenses:Groceries"], Amount: 1500},
+			{AccountUUID: accountUUIDs["Assets:Checking account"], Amount: -1500},
+		},
+	}}, getImportedTransactions(t))
+}
+
+func TestImportExportedHledger(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+	createJournalTestTransactions(t)
+
+	var journal bytes.Buffer
+	err = dbService.ExportJournal(&testUser, JournalFormatHledger, TransactionFilterOptions{FilterTags: []string{"Food"}}, &journal)
+	assert.NoError(t, err)
+
+	err = resetDb()
+	assert.NoError(t, err)
+	result, err := dbService.ImportJournal(&testUser, JournalFormatHledger, DuplicateOptions{}, &journal)
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported:        1,
+		CreatedAccounts: []string{"Assets:Test 1", "Assets:Test 2", "Expenses:Food"},
+	}, result)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	accountUUIDs := make(map[string]string)
+	for _, account := range accounts {
+		accountUUIDs[account.Name] = account.UUID
+	}
+	// hledger tag names cannot contain whitespace.
+	assert.Equal(t, []*Transaction{{
+		Description: `Groceries "Corner shop"`,
+		Type:        TransactionTypeTransfer,
+		Tags:        []string{"Food", "Household_items"},
+		Date:        "2019-01-02",
+		Components: []TransactionComponent{
+			{AccountUUID: accountUUIDs["Assets:Test 1"], Amount: -1250},
+			{AccountUUID: accountUUIDs["Expenses:Food"], Amount: 1250},
+		},
+	}}, getImportedTransactions(t))
+}
+
+func TestImportJournalUnsupportedFormat(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportJournal(&testUser, "gnucash", DuplicateOptions{}, strings.NewReader(testLedger))
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,427 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mt940DateFormat is the format of dates in MT940 fields.
+const mt940DateFormat = "060102"
+
+// mt940Field is a field (tag and value) of an MT940 message.
+type mt940Field struct {
+	Line  int
+	Tag   string
+	Value string
+}
+
+// mt940Balance is an opening (:60F:) or closing (:62F:) balance.
+type mt940Balance struct {
+	Date     string
+	Currency string
+	Amount   int64
+}
+
+// mt940Entry is a statement line (:61:) and its information to account owner (:86:).
+type mt940Entry struct {
+	Line        int
+	Value       string
+	Information string
+}
+
+// mt940Statement is a statement from an MT940 message.
+type mt940Statement struct {
+	Reference string
+	// Account is the account identification (:25:), for example an IBAN or a bank code and account number.
+	Account string
+	Opening *mt940Balance
+	Closing *mt940Balance
+	Entries []*mt940Entry
+}
+
+// mt940BalanceCheck is a statement balance which should match the account's running balance.
+type mt940BalanceCheck struct {
+	Description string
+	Date        string
+	Amount      int64
+}
+
+// readMT940Fields splits an MT940 file into fields.
+// SWIFT message blocks are removed, and a statement end (-) is returned as a field with a "-" tag.
+func readMT940Fields(reader io.Reader) ([]*mt940Field, error) {
+	fields := make([]*mt940Field, 0)
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		value := strings.TrimRight(scanner.Text(), " \r")
+		if strings.HasPrefix(value, "{") {
+			blockStart := strings.Index(value, "{4:")
+			if blockStart < 0 {
+				continue
+			}
+			value = value[blockStart+3:]
+		}
+		if value == "" {
+			continue
+		}
+
+		if value == "-" || value == "-}" {
+			fields = append(fields, &mt940Field{Line: line, Tag: "-"})
+			continue
+		}
+		if tagEnd := strings.IndexByte(value[1:], ':'); value[0] == ':' && tagEnd > 0 {
+			fields = append(fields, &mt940Field{Line: line, Tag: value[1 : tagEnd+1], Value: value[tagEnd+2:]})
+			continue
+		}
+		if len(fields) > 0 {
+			fields[len(fields)-1].Value += "\n" + value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read MT940 file: %w", err)
+	}
+	return fields, nil
+}
+
+// parseMT940Balance parses a balance field, for example C190301EUR1000,00.
+func parseMT940Balance(value string) (*mt940Balance, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 11 {
+		return nil, fmt.Errorf("invalid balance %v", value)
+	}
+	date, err := time.Parse(mt940DateFormat, value[1:7])
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse balance date %v: %w", value, err)
+	}
+	amount, err := parseAmount(value[10:], ",")
+	if err != nil {
+		return nil, err
+	}
+	switch value[0] {
+	case 'C':
+	case 'D':
+		amount = -amount
+	default:
+		return nil, fmt.Errorf("invalid balance credit/debit mark %v", value[:1])
+	}
+	return &mt940Balance{Date: date.Format(dateFormat), Currency: value[7:10], Amount: amount}, nil
+}
+
+// parseMT940Statements groups MT940 fields into statements.
+func parseMT940Statements(fields []*mt940Field) ([]*mt940Statement, error) {
+	statements := make([]*mt940Statement, 0)
+	var statement *mt940Statement
+	var entry *mt940Entry
+	for _, field := range fields {
+		if statement == nil && field.Tag != "-" {
+			statement = &mt940Statement{}
+			statements = append(statements, statement)
+		}
+		switch field.Tag {
+		case "20":
+			if statement.Reference != "" || len(statement.Entries) > 0 {
+				statement = &mt940Statement{}
+				statements = append(statements, statement)
+			}
+			statement.Reference = strings.TrimSpace(field.Value)
+			entry = nil
+		case "25":
+			statement.Account = strings.TrimSpace(field.Value)
+		case "60F", "60M":
+			balance, err := parseMT940Balance(field.Value)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %w", field.Line, err)
+			}
+			statement.Opening = balance
+		case "62F", "62M":
+			balance, err := parseMT940Balance(field.Value)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %w", field.Line, err)
+			}
+			statement.Closing = balance
+			entry = nil
+		case "61":
+			entry = &mt940Entry{Line: field.Line, Value: field.Value}
+			statement.Entries = append(statement.Entries, entry)
+		case "86":
+			if entry != nil {
+				entry.Information = field.Value
+			}
+		case "-":
+			statement = nil
+			entry = nil
+		}
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("file has no MT940 statements")
+	}
+	return statements, nil
+}
+
+// parseMT940Information extracts the counterparty name and purpose from a :86: field.
+// Structured information (used by German banks) consists of ?NN subfields.
+func parseMT940Information(value string) (name string, purpose string) {
+	if len(value) < 4 || value[3] != '?' {
+		return "", strings.Join(strings.Fields(value), " ")
+	}
+	if _, err := strconv.Atoi(value[:3]); err != nil {
+		return "", strings.Join(strings.Fields(value), " ")
+	}
+
+	var postingText string
+	subfields := strings.Split(strings.ReplaceAll(value[4:], "\n", ""), "?")
+	for _, subfield := range subfields {
+		if len(subfield) < 2 {
+			continue
+		}
+		code, content := subfield[:2], subfield[2:]
+		switch {
+		case code == "00":
+			postingText = content
+		case (code >= "20" && code <= "29") || (code >= "60" && code <= "63"):
+			purpose += content
+		case code == "32" || code == "33":
+			name += content
+		}
+	}
+	if purpose == "" {
+		purpose = postingText
+	}
+	return strings.TrimSpace(name), strings.TrimSpace(purpose)
+}
+
+// getMT940Account returns the account identification of statements.
+// All statements should belong to the same account.
+func getMT940Account(statements []*mt940Statement) (string, error) {
+	var accountStatement *mt940Statement
+	for _, statement := range statements {
+		if statement.Account == "" {
+			continue
+		}
+		if accountStatement == nil {
+			accountStatement = statement
+		} else if statement.Account != accountStatement.Account {
+			return "", fmt.Errorf("statement %v account %v doesn't match statement %v account %v",
+				statement.Reference, statement.Account, accountStatement.Reference, accountStatement.Account)
+		}
+	}
+	if accountStatement == nil {
+		return "", nil
+	}
+	return accountStatement.Account, nil
+}
+
+// mt940AccountMatches returns true if the account name contains the account identification
+// or its account number (the part after the bank code).
+func mt940AccountMatches(identification string, name string) bool {
+	name = strings.ToUpper(strings.ReplaceAll(name, " ", ""))
+	identification = strings.ToUpper(strings.ReplaceAll(identification, " ", ""))
+	if strings.Contains(name, identification) {
+		return true
+	}
+	number := ""
+	if i := strings.LastIndexByte(identification, '/'); i >= 0 {
+		number = strings.TrimLeft(identification[i+1:], "0")
+	}
+	return number != "" && strings.Contains(name, number)
+}
+
+// convert creates a Transaction from a statement line, for example 1903010301C500,00NTRFNONREF//REF1.
+func (entry *mt940Entry) convert(accountUUID string) (*importedTransaction, error) {
+	statementLine, supplementaryDetails := entry.Value, ""
+	if i := strings.IndexByte(statementLine, '\n'); i >= 0 {
+		statementLine, supplementaryDetails = statementLine[:i], strings.TrimSpace(statementLine[i+1:])
+	}
+	statementLine = strings.TrimSpace(statementLine)
+	if len(statementLine) < len(mt940DateFormat) {
+		return nil, fmt.Errorf("invalid statement line %v", statementLine)
+	}
+
+	date, err := time.Parse(mt940DateFormat, statementLine[:len(mt940DateFormat)])
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse value date %v: %w", statementLine, err)
+	}
+	rest := statementLine[len(mt940DateFormat):]
+	if len(rest) >= 4 {
+		// Use the optional entry (booking) date, which can be in a different year than the value date.
+		month, monthErr := strconv.Atoi(rest[:2])
+		day, dayErr := strconv.Atoi(rest[2:4])
+		if monthErr == nil && dayErr == nil {
+			entryDate := time.Date(date.Year(), time.Month(month), day, 0, 0, 0, 0, time.UTC)
+			if entryDate.Month() != time.Month(month) || entryDate.Day() != day {
+				return nil, fmt.Errorf("invalid entry date %v", rest[:4])
+			}
+			if entryDate.Sub(date) > 180*24*time.Hour {
+				entryDate = entryDate.AddDate(-1, 0, 0)
+			} else if date.Sub(entryDate) > 180*24*time.Hour {
+				entryDate = entryDate.AddDate(1, 0, 0)
+			}
+			date = entryDate
+			rest = rest[4:]
+		}
+	}
+
+	var negative bool
+	switch {
+	case strings.HasPrefix(rest, "RC"):
+		negative, rest = true, rest[2:]
+	case strings.HasPrefix(rest, "RD"):
+		rest = rest[2:]
+	case strings.HasPrefix(rest, "C"):
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "D"):
+		negative, rest = true, rest[1:]
+	default:
+		return nil, fmt.Errorf("invalid credit/debit mark in statement line %v", statementLine)
+	}
+	if len(rest) > 0 && (rest[0] < '0' || rest[0] > '9') {
+		// Skip the funds code.
+		rest = rest[1:]
+	}
+	amountEnd := strings.IndexFunc(rest, func(r rune) bool {
+		return (r < '0' || r > '9') && r != ','
+	})
+	if amountEnd < 0 {
+		amountEnd = len(rest)
+	}
+	amount, err := parseAmount(rest[:amountEnd], ",")
+	if err != nil {
+		return nil, err
+	}
+	if negative {
+		amount = -amount
+	}
+
+	// Skip the transaction type identification code.
+	rest = rest[amountEnd:]
+	if len(rest) >= 4 {
+		rest = rest[4:]
+	}
+	entryID := ""
+	if i := strings.Index(rest, "//"); i >= 0 {
+		entryID = strings.TrimSpace(rest[i+2:])
+		rest = rest[:i]
+	}
+	if customerReference := strings.TrimSpace(rest); entryID == "" && customerReference != "NONREF" {
+		entryID = customerReference
+	}
+
+	name, purpose := parseMT940Information(entry.Information)
+	description := joinDescription(name, purpose)
+	if description == "" {
+		description = supplementaryDetails
+	}
+
+	return &importedTransaction{
+		Transaction: &Transaction{
+			Description: description,
+			Type:        TransactionTypeExpenseIncome,
+			Date:        date.Format(dateFormat),
+			Components:  []TransactionComponent{{AccountUUID: accountUUID, Amount: amount}},
+		},
+		AccountUUID: accountUUID,
+		EntryID:     entryID,
+	}, nil
+}
+
+// ImportMT940 imports transactions from an MT940 file into accountUUID.
+// After importing, the statements' opening and closing balances are compared with the account's running balance,
+// and discrepancies are reported as warnings.
+// Statements for different accounts cannot be imported together; if the account name doesn't contain
+// the statements' account identification (:25:), a warning is reported.
+func (s *DBService) ImportMT940(user *User, accountUUID string, duplicates DuplicateOptions, reader io.Reader) (*ImportResult, error) {
+	fields, err := readMT940Fields(reader)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := parseMT940Statements(fields)
+	if err != nil {
+		return nil, err
+	}
+	statementAccount, err := getMT940Account(statements)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{}
+	transactions := make([]*importedTransaction, 0)
+	balanceChecks := make([]mt940BalanceCheck, 0, len(statements)*2)
+	for _, statement := range statements {
+		firstDate := ""
+		for _, entry := range statement.Entries {
+			transaction, err := entry.convert(accountUUID)
+			if err != nil {
+				result.skip(entry.Line, err.Error())
+				continue
+			}
+			if firstDate == "" || transaction.Date < firstDate {
+				firstDate = transaction.Date
+			}
+			transactions = append(transactions, transaction)
+		}
+
+		if statement.Opening != nil {
+			// The opening balance should include all transactions before the statement's first entry.
+			openingDate := statement.Opening.Date
+			if firstDate != "" {
+				date, err := time.Parse(dateFormat, firstDate)
+				if err != nil {
+					return nil, err
+				}
+				openingDate = date.AddDate(0, 0, -1).Format(dateFormat)
+			}
+			balanceChecks = append(balanceChecks, mt940BalanceCheck{
+				Description: fmt.Sprintf("statement %v opening balance", statement.Reference),
+				Date:        openingDate,
+				Amount:      statement.Opening.Amount,
+			})
+		}
+		if statement.Closing != nil {
+			balanceChecks = append(balanceChecks, mt940BalanceCheck{
+				Description: fmt.Sprintf("statement %v closing balance", statement.Reference),
+				Date:        statement.Closing.Date,
+				Amount:      statement.Closing.Amount,
+			})
+		}
+	}
+
+	err = s.update(user, func(s *DBService) error {
+		account, err := s.getImportAccount(user, accountUUID, "")
+		if err != nil {
+			return err
+		}
+		for _, statement := range statements {
+			for _, balance := range []*mt940Balance{statement.Opening, statement.Closing} {
+				if balance != nil && balance.Currency != account.Currency {
+					return fmt.Errorf("statement %v currency %v doesn't match account %v currency %v", statement.Reference, balance.Currency, account.Name, account.Currency)
+				}
+			}
+		}
+		if statementAccount != "" && !mt940AccountMatches(statementAccount, account.Name) {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("statement account %v doesn't match account %v", statementAccount, account.Name))
+		}
+
+		if err := s.importTransactions(user, transactions, duplicates, result); err != nil {
+			return err
+		}
+		for _, check := range balanceChecks {
+			balance, err := s.getAccountBalance(user, accountUUID, check.Date)
+			if err != nil {
+				return err
+			}
+			if balance != check.Amount {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%v %v doesn't match account balance %v on %v",
+					check.Description, formatAmount(check.Amount), formatAmount(balance), check.Date))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import transactions: %w", err)
+	}
+	return result, nil
+}
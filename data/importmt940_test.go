@@ -0,0 +1,145 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMT940 = `{1:F01BANKDEFFXXXX0000000000}{2:I940BANKDEFFXXXXN}{4:
+:20:STMT1
+:25:12345678/0001234567
+:28C:1/1
+:60F:C190228EUR0,00
+:61:1903010301C1000,00NTRFNONREF//REF1
+:86:166?00GUTSCHRIFT?20Salary ?21March?32Employer GmbH
+:61:1903020302D12,50NMSCNONREF
+Card payment
+:61:1903021302D1,00NMSCNONREF
+:61:1903030303D3,00NCHGFEE1
+:86:Account
+ fee
+:62F:C190303EUR984,50
+-}
+`
+
+func TestImportMT940(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportMT940(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testMT940))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 3,
+		Skipped:  []string{"line 10: invalid entry date 1302"},
+		Warnings: []string{"statement account 12345678/0001234567 doesn't match account Test 2"},
+	}, result)
+
+	assert.Equal(t, []*Transaction{{
+		Description: "Account fee",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-03",
+		Components:  []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: -300}},
+	}, {
+		Description: "Card payment",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-02",
+		Components:  []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: -1250}},
+	}, {
+		Description: "Employer GmbH - Salary March",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-01",
+		Components:  []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: 100000}},
+	}}, getImportedTransactions(t))
+}
+
+func TestImportMT940BalanceMismatch(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	transaction := &Transaction{
+		Description: "Previous transaction",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-02-01",
+		Components:  []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: 500}},
+	}
+	err = dbService.CreateTransaction(&testUser, transaction)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportMT940(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testMT940))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"statement account 12345678/0001234567 doesn't match account Test 2",
+		"statement STMT1 opening balance 0.00 doesn't match account balance 5.00 on 2019-02-28",
+		"statement STMT1 closing balance 984.50 doesn't match account balance 989.50 on 2019-03-03",
+	}, result.Warnings)
+
+	result, err = dbService.ImportMT940(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testMT940))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, 2, result.AlreadyImported)
+}
+
+func TestImportMT940Account(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	account := testAccount2
+	account.Name = "Business 123 4567"
+	err = dbService.UpdateAccount(&testUser, &account)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportMT940(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testMT940))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Imported)
+	assert.Empty(t, result.Warnings)
+
+	assert.True(t, mt940AccountMatches("DE89 3704 0044 0532 0130 00", "Checking DE89370400440532013000"))
+	assert.False(t, mt940AccountMatches("12345678/0000000000", "Business"))
+	assert.False(t, mt940AccountMatches("DE89370400440532013000", "Checking"))
+}
+
+func TestImportMT940AccountMismatch(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	otherStatement := strings.NewReplacer(":20:STMT1", ":20:STMT2", ":25:12345678/0001234567", ":25:12345678/0007654321").Replace(testMT940)
+	result, err := dbService.ImportMT940(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testMT940+otherStatement))
+	assert.EqualError(t, err, "statement STMT2 account 12345678/0007654321 doesn't match statement STMT1 account 12345678/0001234567")
+	assert.Nil(t, result)
+	assert.Empty(t, getImportedTransactions(t))
+}
+
+func TestImportMT940CurrencyMismatch(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportMT940(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader(testMT940))
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Empty(t, getImportedTransactions(t))
+}
+
+func TestImportInvalidMT940(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	_, err = dbService.ImportMT940(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader("Date,Amount\n"))
+	assert.Error(t, err)
+
+	_, err = dbService.ImportMT940(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(":20:STMT1\n:60F:X190228EUR0,00\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package data
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// ofxDateFormat is the date part of an OFX datetime value.
+const ofxDateFormat = "20060102"
+
+// ofxTransaction contains values of a STMTTRN element.
+type ofxTransaction map[string]string
+
+// ofxStatement contains values parsed from a STMTRS or CCSTMTRS element of an OFX file.
+type ofxStatement struct {
+	Currency     string
+	Transactions []ofxTransaction
+}
+
+// parseOFX parses the statements of an OFX 1.x (SGML) or OFX 2.x (XML) file.
+// SGML elements don't need to be closed, so only the values of opening tags are used.
+func parseOFX(reader io.Reader) ([]*ofxStatement, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read OFX file: %w", err)
+	}
+	content := string(value)
+
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("file has no OFX element")
+	}
+	content = content[start:]
+
+	statements := make([]*ofxStatement, 0, 1)
+	var statement *ofxStatement
+	startStatement := func() {
+		statement = &ofxStatement{Transactions: []ofxTransaction{}}
+		statements = append(statements, statement)
+	}
+	var transaction ofxTransaction
+	for len(content) > 0 {
+		tagStart := strings.IndexByte(content, '<')
+		if tagStart < 0 {
+			break
+		}
+		tagEnd := strings.IndexByte(content[tagStart:], '>')
+		if tagEnd < 0 {
+			return nil, fmt.Errorf("unterminated OFX tag")
+		}
+		tag := strings.ToUpper(strings.TrimSpace(content[tagStart+1 : tagStart+tagEnd]))
+		content = content[tagStart+tagEnd+1:]
+
+		valueEnd := strings.IndexByte(content, '<')
+		if valueEnd < 0 {
+			valueEnd = len(content)
+		}
+		tagValue := strings.TrimSpace(html.UnescapeString(content[:valueEnd]))
+
+		switch {
+		case tag == "STMTRS" || tag == "CCSTMTRS":
+			startStatement()
+		case tag == "/STMTRS" || tag == "/CCSTMTRS":
+			statement = nil
+		case tag == "STMTTRN":
+			transaction = make(ofxTransaction)
+		case tag == "/STMTTRN":
+			if transaction != nil {
+				if statement == nil {
+					startStatement()
+				}
+				statement.Transactions = append(statement.Transactions, transaction)
+			}
+			transaction = nil
+		case tag == "CURDEF":
+			if statement == nil {
+				startStatement()
+			}
+			statement.Currency = tagValue
+		case transaction != nil && !strings.HasPrefix(tag, "/"):
+			transaction[tag] = tagValue
+		}
+	}
+	if transaction != nil {
+		return nil, fmt.Errorf("unterminated STMTTRN element")
+	}
+	return statements, nil
+}
+
+// convert creates a Transaction from an OFX STMTTRN element.
+func (transaction ofxTransaction) convert(accountUUID string) (*importedTransaction, error) {
+	datePosted := transaction["DTPOSTED"]
+	if len(datePosted) < len(ofxDateFormat) {
+		return nil, fmt.Errorf("invalid date %v", datePosted)
+	}
+	date, err := time.Parse(ofxDateFormat, datePosted[:len(ofxDateFormat)])
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse date %v: %w", datePosted, err)
+	}
+
+	amountValue := transaction["TRNAMT"]
+	decimalSeparator := "."
+	if !strings.Contains(amountValue, ".") && strings.Contains(amountValue, ",") {
+		decimalSeparator = ","
+	}
+	amount, err := parseAmount(amountValue, decimalSeparator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importedTransaction{
+		Transaction: &Transaction{
+			Description: joinDescription(transaction["NAME"], transaction["MEMO"]),
+			Type:        TransactionTypeExpenseIncome,
+			Date:        date.Format(dateFormat),
+			Components:  []TransactionComponent{{AccountUUID: accountUUID, Amount: amount}},
+		},
+		AccountUUID: accountUUID,
+		EntryID:     transaction["FITID"],
+	}, nil
+}
+
+// ImportOFX imports transactions from the OFX or QFX statements into accountUUID.
+// The currency of every statement has to match the account currency.
+// Entries are identified by their FITID, so that importing an overlapping statement doesn't create duplicates.
+func (s *DBService) ImportOFX(user *User, accountUUID string, duplicates DuplicateOptions, reader io.Reader) (*ImportResult, error) {
+	statements, err := parseOFX(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	transactions := make([]*importedTransaction, 0)
+	number := 0
+	for _, statement := range statements {
+		for _, ofxTransaction := range statement.Transactions {
+			number++
+			transaction, err := ofxTransaction.convert(accountUUID)
+			if err != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("transaction %v (FITID %v): %v", number, ofxTransaction["FITID"], err))
+				continue
+			}
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	err = s.update(user, func(s *DBService) error {
+		account, err := s.getImportAccount(user, accountUUID, "")
+		if err != nil {
+			return err
+		}
+		for i, statement := range statements {
+			if statement.Currency != "" && statement.Currency != account.Currency {
+				return fmt.Errorf("statement %v currency %v doesn't match account %v currency %v", i+1, statement.Currency, account.Name, account.Currency)
+			}
+		}
+		return s.importTransactions(user, transactions, duplicates, result)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import transactions: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,215 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testOFXSGML = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20190305120000<LANGUAGE>ENG</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<STMTRS>
+<CURDEF>USD
+<BANKTRANLIST>
+<DTSTART>20190301
+<DTEND>20190305
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20190301120000.000[-5:EST]
+<TRNAMT>1000.00
+<FITID>201903011
+<NAME>Salary
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20190302
+<TRNAMT>-12.50
+<FITID>201903021
+<NAME>Groceries &amp; more
+<MEMO>Card payment
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>2019
+<TRNAMT>-1.00
+<FITID>201903031
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+const testOFXXML = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <BANKMSGSRSV1>
+    <STMTTRNRS>
+      <STMTRS>
+        <CURDEF>USD</CURDEF>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20190302</DTPOSTED>
+            <TRNAMT>-12.50</TRNAMT>
+            <FITID>201903021</FITID>
+            <NAME>Groceries &amp; more</NAME>
+            <MEMO>Card payment</MEMO>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20190306</DTPOSTED>
+            <TRNAMT>-5.00</TRNAMT>
+            <FITID>201903061</FITID>
+            <MEMO>Coffee</MEMO>
+          </STMTTRN>
+        </BANKTRANLIST>
+      </STMTRS>
+    </STMTTRNRS>
+  </BANKMSGSRSV1>
+</OFX>
+`
+
+func getImportedTransactions(t *testing.T) []*Transaction {
+	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	for _, transaction := range transactions {
+		transaction.UUID = ""
+	}
+	return transactions
+}
+
+func TestImportOFX(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportOFX(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader(testOFXSGML))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 2,
+		Skipped:  []string{"transaction 3 (FITID 201903031): invalid date 2019"},
+	}, result)
+
+	assert.Equal(t, []*Transaction{{
+		Description: "Groceries & more - Card payment",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-02",
+		Components:  []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: -1250}},
+	}, {
+		Description: "Salary",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-01",
+		Components:  []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 100000}},
+	}}, getImportedTransactions(t))
+}
+
+func TestImportOverlappingOFX(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	_, err = dbService.ImportOFX(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader(testOFXSGML))
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportOFX(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader(testOFXXML))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{Imported: 1, AlreadyImported: 1}, result)
+
+	transactions := getImportedTransactions(t)
+	assert.Len(t, transactions, 3)
+	assert.Equal(t, &Transaction{
+		Description: "Coffee",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-03-06",
+		Components:  []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: -500}},
+	}, transactions[0])
+
+	account, err := dbService.GetAccount(&testUser, testAccount1.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(98250), account.Balance)
+}
+
+func TestImportOFXAfterDeletingTransaction(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	_, err = dbService.ImportOFX(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader(testOFXXML))
+	assert.NoError(t, err)
+
+	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	err = dbService.DeleteTransaction(&testUser, transactions[0].UUID, false)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportOFX(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader(testOFXXML))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{Imported: 1, AlreadyImported: 1}, result)
+}
+
+func TestImportOFXCurrencyMismatch(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportOFX(&testUser, testAccount2.UUID, DuplicateOptions{}, strings.NewReader(testOFXXML))
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Empty(t, getImportedTransactions(t))
+}
+
+func TestImportOFXStatementCurrencies(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	// Every statement should be checked, not only the last one.
+	eurStatement := strings.Replace(testOFXSGML, "<CURDEF>USD", "<CURDEF>EUR", 1)
+	start, end := strings.Index(eurStatement, "<STMTRS>"), strings.Index(eurStatement, "</STMTRS>")+len("</STMTRS>")
+	eurStatement = eurStatement[start:end]
+	ofx := strings.Replace(testOFXSGML, "<STMTRS>", eurStatement+"\n<STMTRS>", 1)
+
+	statements, err := parseOFX(strings.NewReader(ofx))
+	assert.NoError(t, err)
+	assert.Len(t, statements, 2)
+	assert.Equal(t, "EUR", statements[0].Currency)
+	assert.Equal(t, "USD", statements[1].Currency)
+
+	result, err := dbService.ImportOFX(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader(ofx))
+	assert.ErrorContains(t, err, "statement 1 currency EUR doesn't match account")
+	assert.Nil(t, result)
+	assert.Empty(t, getImportedTransactions(t))
+}
+
+func TestImportInvalidOFX(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	_, err = dbService.ImportOFX(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader("Date,Amount\n"))
+	assert.Error(t, err)
+
+	_, err = dbService.ImportOFX(&testUser, testAccount1.UUID, DuplicateOptions{}, strings.NewReader("<OFX><STMTTRN><TRNAMT>1.00"))
+	assert.Error(t, err)
+}
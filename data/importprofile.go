@@ -0,0 +1,192 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ImportProfile keeps the CSV column mapping used to import statements from a bank.
+// Columns are referenced by their header names; an empty column name means the column is not used.
+type ImportProfile struct {
+	UUID              string
+	Name              string
+	AccountUUID       string
+	Delimiter         string
+	DateColumn        string
+	DateFormat        string
+	AmountColumn      string
+	DebitColumn       string
+	CreditColumn      string
+	DecimalSeparator  string
+	DescriptionColumn string
+}
+
+// encode serializes an ImportProfile.
+func (profile *ImportProfile) encode() ([]byte, error) {
+	var value bytes.Buffer
+	if err := gob.NewEncoder(&value).Encode(profile); err != nil {
+		return nil, err
+	}
+	return value.Bytes(), nil
+}
+
+// decode deserializes an ImportProfile.
+func (profile *ImportProfile) decode(val []byte) error {
+	return gob.NewDecoder(bytes.NewBuffer(val)).Decode(profile)
+}
+
+// validate checks that profile has all required columns.
+func (profile *ImportProfile) validate() error {
+	if profile.AccountUUID == "" {
+		return fmt.Errorf("import profile %v has no account", profile.Name)
+	}
+	if profile.DateColumn == "" {
+		return fmt.Errorf("import profile %v has no date column", profile.Name)
+	}
+	if profile.AmountColumn == "" && profile.DebitColumn == "" && profile.CreditColumn == "" {
+		return fmt.Errorf("import profile %v has no amount, debit or credit columns", profile.Name)
+	}
+	if len([]rune(profile.Delimiter)) > 1 {
+		return fmt.Errorf("import profile %v delimiter %v is longer than one character", profile.Name, profile.Delimiter)
+	}
+	return nil
+}
+
+// createImportProfile creates and saves the specified import profile.
+// The profile UUID is not generated here and should be generated before
+// calling this method.
+func (s *DBService) createImportProfile(user *User, profile *ImportProfile) error {
+	key := user.createImportProfileKey(profile)
+	value, err := profile.encode()
+	if err != nil {
+		return fmt.Errorf("cannot encode import profile: %w", err)
+	}
+
+	if err := s.addReferencedKey([]byte(user.createImportProfileKeyPrefix()), []byte(profile.UUID), false); err != nil {
+		return fmt.Errorf("cannot add import profile to index: %w", err)
+	}
+
+	return s.db.Put(key, value)
+}
+
+// CreateImportProfile creates and saves the specified import profile.
+// It generates and sets the ID for the new profile.
+func (s *DBService) CreateImportProfile(user *User, profile *ImportProfile) error {
+	if err := profile.validate(); err != nil {
+		return err
+	}
+	profile.UUID = uuid.NewString()
+
+	return s.update(user, func(s *DBService) error {
+		return s.createImportProfile(user, profile)
+	})
+}
+
+// UpdateImportProfile saves an already existing import profile.
+// If the profile doesn't exist, it returns an error.
+func (s *DBService) UpdateImportProfile(user *User, profile *ImportProfile) error {
+	if err := profile.validate(); err != nil {
+		return err
+	}
+	return s.update(user, func(s *DBService) error {
+		key := user.createImportProfileKey(profile)
+
+		exists, err := s.db.Has(key)
+		if err != nil {
+			return fmt.Errorf("cannot check if import profile exists %v: %w", string(key), err)
+		} else if !exists {
+			return fmt.Errorf("cannot update import profile %v if it doesn't exist", string(key))
+		}
+
+		value, err := profile.encode()
+		if err != nil {
+			return fmt.Errorf("cannot encode import profile: %w", err)
+		}
+		return s.db.Put(key, value)
+	})
+}
+
+// getImportProfile returns an ImportProfile by its UUID.
+// If the ImportProfile doesn't exist, it returns nil.
+func (s *DBService) getImportProfile(user *User, profileUUID string) (*ImportProfile, error) {
+	key := user.createImportProfileKeyFromUUID(profileUUID)
+
+	value, err := s.db.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import profile %v: %w", string(key), err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	profile := &ImportProfile{}
+	if err := profile.decode(value); err != nil {
+		return nil, fmt.Errorf("failed to read value for import profile %v: %w", string(key), err)
+	}
+	return profile, nil
+}
+
+// GetImportProfile returns an ImportProfile by its UUID.
+// If the ImportProfile doesn't exist, it returns nil.
+func (s *DBService) GetImportProfile(user *User, profileUUID string) (*ImportProfile, error) {
+	var profile *ImportProfile
+	err := s.view(user, func() error {
+		var err error
+		profile, err = s.getImportProfile(user, profileUUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// GetImportProfiles returns all import profiles for user.
+func (s *DBService) GetImportProfiles(user *User) ([]*ImportProfile, error) {
+	profiles := make([]*ImportProfile, 0)
+	err := s.view(user, func() error {
+		profilesUUIDs, err := s.getReferencedKeys([]byte(user.createImportProfileKeyPrefix()))
+		if err != nil {
+			return fmt.Errorf("cannot get import profile UUIDs for user: %w", err)
+		}
+
+		for _, profileUUID := range profilesUUIDs {
+			profile, err := s.getImportProfile(user, string(profileUUID))
+			if err != nil {
+				return err
+			}
+			if profile == nil {
+				continue
+			}
+			profiles = append(profiles, profile)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// DeleteImportProfile deletes an import profile by its UUID.
+// If the profile doesn't exist, it returns an error.
+func (s *DBService) DeleteImportProfile(user *User, profileUUID string) error {
+	key := user.createImportProfileKeyFromUUID(profileUUID)
+	return s.update(user, func(s *DBService) error {
+		exists, err := s.db.Has(key)
+		if err != nil {
+			return fmt.Errorf("cannot check if import profile exists %v: %w", profileUUID, err)
+		} else if !exists {
+			return fmt.Errorf("cannot delete import profile %v because it doesn't exist", profileUUID)
+		}
+
+		if err := s.db.Delete(key); err != nil {
+			return fmt.Errorf("cannot delete import profile %v: %w", profileUUID, err)
+		}
+
+		return s.deleteReferencedKey([]byte(user.createImportProfileKeyPrefix()), []byte(profileUUID))
+	})
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestImportProfile() ImportProfile {
+	return ImportProfile{
+		Name:              "Bank",
+		AccountUUID:       "uuid1",
+		Delimiter:         ";",
+		DateColumn:        "Date",
+		DateFormat:        "02.01.2006",
+		AmountColumn:      "Amount",
+		DecimalSeparator:  ",",
+		DescriptionColumn: "Description",
+	}
+}
+
+func TestCreateImportProfile(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	profile := createTestImportProfile()
+	saveProfile := profile
+	err = dbService.CreateImportProfile(&testUser, &saveProfile)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, saveProfile.UUID)
+	profile.UUID = saveProfile.UUID
+
+	profiles, err := dbService.GetImportProfiles(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ImportProfile{&profile}, profiles)
+
+	dbProfile, err := dbService.GetImportProfile(&testUser, profile.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, &profile, dbProfile)
+}
+
+func TestCreateInvalidImportProfile(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	profile := createTestImportProfile()
+	profile.AmountColumn = ""
+	err = dbService.CreateImportProfile(&testUser, &profile)
+	assert.Error(t, err)
+
+	profile = createTestImportProfile()
+	profile.DateColumn = ""
+	err = dbService.CreateImportProfile(&testUser, &profile)
+	assert.Error(t, err)
+
+	profiles, err := dbService.GetImportProfiles(&testUser)
+	assert.NoError(t, err)
+	assert.Empty(t, profiles)
+}
+
+func TestUpdateImportProfile(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	profile := createTestImportProfile()
+	err = dbService.CreateImportProfile(&testUser, &profile)
+	assert.NoError(t, err)
+
+	profile.AmountColumn = ""
+	profile.DebitColumn = "Debit"
+	profile.CreditColumn = "Credit"
+	saveProfile := profile
+	err = dbService.UpdateImportProfile(&testUser, &saveProfile)
+	assert.NoError(t, err)
+
+	profiles, err := dbService.GetImportProfiles(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ImportProfile{&profile}, profiles)
+
+	missingProfile := createTestImportProfile()
+	missingProfile.UUID = "non-existing"
+	err = dbService.UpdateImportProfile(&testUser, &missingProfile)
+	assert.Error(t, err)
+}
+
+func TestDeleteImportProfile(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	profile1 := createTestImportProfile()
+	err = dbService.CreateImportProfile(&testUser, &profile1)
+	assert.NoError(t, err)
+	profile2 := createTestImportProfile()
+	profile2.Name = "Other bank"
+	err = dbService.CreateImportProfile(&testUser, &profile2)
+	assert.NoError(t, err)
+
+	err = dbService.DeleteImportProfile(&testUser, profile1.UUID)
+	assert.NoError(t, err)
+
+	profiles, err := dbService.GetImportProfiles(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ImportProfile{&profile2}, profiles)
+
+	err = dbService.DeleteImportProfile(&testUser, profile1.UUID)
+	assert.Error(t, err)
+}
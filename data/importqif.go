@@ -0,0 +1,406 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// qifSplit is a split (S, E and $ fields) of a QIF transaction.
+type qifSplit struct {
+	Category string
+	Memo     string
+	Amount   string
+}
+
+// qifRecord contains the raw values of a QIF transaction.
+type qifRecord struct {
+	// Line is the line where the transaction starts.
+	Line int
+	// Account is the name of the account from the preceding !Account block.
+	// An empty value means that the transaction belongs to the default account.
+	Account  string
+	Date     string
+	Amount   string
+	Payee    string
+	Memo     string
+	Category string
+	Splits   []*qifSplit
+}
+
+// qifFile contains accounts and transactions parsed from a QIF file.
+type qifFile struct {
+	Accounts []string
+	Records  []*qifRecord
+}
+
+// qifTransactionSections are the supported QIF register types.
+// Cash and asset/liability registers use the same fields as bank accounts.
+var qifTransactionSections = map[string]bool{
+	"!type:bank":  true,
+	"!type:ccard": true,
+	"!type:cash":  true,
+	"!type:oth a": true,
+	"!type:oth l": true,
+}
+
+// qifIgnoredFields are transaction fields which are known but not imported.
+const qifIgnoredFields = "CNAUKX%"
+
+// parseQIF parses a QIF file.
+// Lines which cannot be imported are added to result.
+func parseQIF(reader io.Reader, result *ImportResult) (*qifFile, error) {
+	const (
+		sectionNone = iota
+		sectionAccount
+		sectionTransactions
+		sectionUnsupported
+	)
+
+	file := &qifFile{}
+	section := sectionNone
+	sectionHeader := ""
+	currentAccount := ""
+	var accountName string
+	var record *qifRecord
+	var split *qifSplit
+
+	finishRecord := func() {
+		if record != nil {
+			file.Records = append(file.Records, record)
+		}
+		record = nil
+		split = nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		value := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == 1 {
+			value = strings.TrimPrefix(value, "\ufeff")
+		}
+		if value == "" {
+			continue
+		}
+
+		if value[0] == '!' {
+			finishRecord()
+			header := strings.ToLower(strings.TrimSpace(value))
+			sectionHeader = strings.TrimSpace(value)
+			switch {
+			case header == "!account":
+				section = sectionAccount
+				accountName = ""
+			case qifTransactionSections[header]:
+				section = sectionTransactions
+			case strings.HasPrefix(header, "!option:") || strings.HasPrefix(header, "!clear:"):
+			default:
+				section = sectionUnsupported
+				result.skip(line, fmt.Sprintf("unsupported section %v", value))
+			}
+			continue
+		}
+
+		code, fieldValue := value[0], strings.TrimSpace(value[1:])
+		switch section {
+		case sectionAccount:
+			switch code {
+			case 'N':
+				accountName = fieldValue
+			case '^':
+				if accountName != "" {
+					currentAccount = accountName
+					file.Accounts = append(file.Accounts, accountName)
+				}
+				accountName = ""
+			}
+		case sectionTransactions:
+			if code == '^' {
+				finishRecord()
+				continue
+			}
+			if record == nil {
+				record = &qifRecord{Line: line, Account: currentAccount}
+			}
+			switch code {
+			case 'D':
+				record.Date = fieldValue
+			case 'T':
+				record.Amount = fieldValue
+			case 'P':
+				record.Payee = fieldValue
+			case 'M':
+				record.Memo = fieldValue
+			case 'L':
+				record.Category = fieldValue
+			case 'S':
+				split = &qifSplit{Category: fieldValue}
+				record.Splits = append(record.Splits, split)
+			case 'E', '$':
+				if split == nil {
+					result.skip(line, fmt.Sprintf("split field %v without a split category", string(code)))
+				} else if code == 'E' {
+					split.Memo = fieldValue
+				} else {
+					split.Amount = fieldValue
+				}
+			default:
+				if !strings.ContainsRune(qifIgnoredFields, rune(code)) {
+					result.skip(line, fmt.Sprintf("unsupported field %v", string(code)))
+				}
+			}
+		case sectionUnsupported:
+			result.skip(line, fmt.Sprintf("line is in unsupported section %v", sectionHeader))
+		case sectionNone:
+			result.skip(line, "line is not in a !Type section")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read QIF file: %w", err)
+	}
+	finishRecord()
+	return file, nil
+}
+
+// parseQIFDate parses a QIF date.
+// Quicken uses the M/D/Y format, where two-digit years after an apostrophe are in the 2000s;
+// dates separated with dots are in the D.M.Y format, and Y-M-D is also supported.
+func parseQIFDate(value string) (string, error) {
+	normalized := strings.ReplaceAll(value, " ", "")
+	century := 1900
+	if strings.Contains(normalized, "'") {
+		normalized = strings.Replace(normalized, "'", "/", 1)
+		century = 2000
+	}
+	parts := strings.FieldsFunc(normalized, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.'
+	})
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid date %v", value)
+	}
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return "", fmt.Errorf("invalid date %v", value)
+		}
+		numbers[i] = number
+	}
+
+	var year, month, day int
+	switch {
+	case len(parts[0]) == 4:
+		year, month, day = numbers[0], numbers[1], numbers[2]
+	case strings.Contains(normalized, "."):
+		day, month, year = numbers[0], numbers[1], numbers[2]
+	default:
+		month, day, year = numbers[0], numbers[1], numbers[2]
+	}
+	if len(parts[0]) != 4 && len(parts[2]) <= 2 {
+		if century == 1900 && year < 70 {
+			century = 2000
+		}
+		year += century
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Year() != year || date.Month() != time.Month(month) || date.Day() != day {
+		return "", fmt.Errorf("invalid date %v", value)
+	}
+	return date.Format(dateFormat), nil
+}
+
+// parseQIFCategory returns the category (without a class) or the transfer account name.
+func parseQIFCategory(value string) (category string, transferAccount string) {
+	if strings.HasPrefix(value, "[") {
+		if end := strings.IndexByte(value, ']'); end > 0 {
+			return "", strings.TrimSpace(value[1:end])
+		}
+	}
+	if classStart := strings.IndexByte(value, '/'); classStart >= 0 {
+		value = value[:classStart]
+	}
+	return strings.TrimSpace(value), ""
+}
+
+// qifTransfers keeps track of transfer legs, to match both sides of a transfer.
+// Quicken exports a transfer in the registers of both accounts, but it should be imported only once.
+type qifTransfers map[string]int
+
+// mirrored returns true if the transfer leg was already imported from the other account's register.
+// Otherwise, it remembers the transfer leg.
+func (transfers qifTransfers) mirrored(date, accountUUID, transferAccountUUID string, amount int64) bool {
+	fromAccount, toAccount := accountUUID, transferAccountUUID
+	if fromAccount > toAccount {
+		fromAccount, toAccount, amount = toAccount, fromAccount, -amount
+	}
+	leg := fmt.Sprintf("%v/%v/%v/%v", date, fromAccount, toAccount, amount)
+
+	mirrorKey := leg + "/" + transferAccountUUID
+	if transfers[mirrorKey] > 0 {
+		transfers[mirrorKey]--
+		return true
+	}
+	transfers[leg+"/"+accountUUID]++
+	return false
+}
+
+// convert creates a Transaction from a QIF record.
+// If all parts of record were already imported from another register, returns nil.
+func (record *qifRecord) convert(accountUUIDs map[string]string, defaultAccountUUID string, transfers qifTransfers) (*importedTransaction, error) {
+	accountUUID := defaultAccountUUID
+	if record.Account != "" {
+		accountUUID = accountUUIDs[record.Account]
+	}
+	if accountUUID == "" {
+		return nil, fmt.Errorf("transaction has no account")
+	}
+
+	date, err := parseQIFDate(record.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	splits := record.Splits
+	if len(splits) == 0 {
+		splits = []*qifSplit{{Category: record.Category, Amount: record.Amount}}
+	}
+
+	transaction := &Transaction{
+		Description: joinDescription(record.Payee, record.Memo),
+		Type:        TransactionTypeTransfer,
+		Date:        date,
+		Components:  make([]TransactionComponent, 0, len(splits)),
+	}
+	var splitsTotal int64
+	for _, split := range splits {
+		amount, err := parseAmount(split.Amount, ".")
+		if err != nil {
+			return nil, err
+		}
+		splitsTotal += amount
+	}
+	if len(record.Splits) > 0 && record.Amount != "" {
+		total, err := parseAmount(record.Amount, ".")
+		if err != nil {
+			return nil, err
+		}
+		if total != splitsTotal {
+			return nil, fmt.Errorf("total of splits %v doesn't match transaction amount %v", formatAmount(splitsTotal), formatAmount(total))
+		}
+	}
+
+	for _, split := range splits {
+		amount, err := parseAmount(split.Amount, ".")
+		if err != nil {
+			return nil, err
+		}
+		category, transferAccount := parseQIFCategory(split.Category)
+		transferAccountUUID := accountUUIDs[transferAccount]
+		if transferAccountUUID == "" || transferAccountUUID == accountUUID {
+			// A transfer into the same account is used for opening balances.
+			transaction.Type = TransactionTypeExpenseIncome
+			transaction.Tags = append(transaction.Tags, category)
+			transaction.Components = append(transaction.Components, TransactionComponent{AccountUUID: accountUUID, Amount: amount})
+			continue
+		}
+		if transfers.mirrored(date, accountUUID, transferAccountUUID, amount) {
+			continue
+		}
+		transaction.Components = append(transaction.Components,
+			TransactionComponent{AccountUUID: accountUUID, Amount: amount},
+			TransactionComponent{AccountUUID: transferAccountUUID, Amount: -amount},
+		)
+	}
+	if len(transaction.Components) == 0 {
+		return nil, nil
+	}
+	return &importedTransaction{Transaction: transaction, AccountUUID: accountUUID}, nil
+}
+
+// getQIFAccounts maps account names used in file to account UUIDs.
+// Accounts which don't exist are created with the specified currency.
+// This method should be called from an update transaction.
+func (s *DBService) getQIFAccounts(user *User, file *qifFile, currency string, result *ImportResult) (map[string]string, error) {
+	names := append([]string{}, file.Accounts...)
+	for _, record := range file.Records {
+		splits := record.Splits
+		if len(splits) == 0 {
+			splits = []*qifSplit{{Category: record.Category}}
+		}
+		for _, split := range splits {
+			if _, transferAccount := parseQIFCategory(split.Category); transferAccount != "" {
+				names = append(names, transferAccount)
+			}
+		}
+	}
+
+	newAccount := func(name string) (*Account, error) {
+		if currency == "" {
+			return nil, fmt.Errorf("cannot create account %v without a currency", name)
+		}
+		return &Account{Name: name, Currency: currency, IncludeInTotal: true, ShowInList: true}, nil
+	}
+	accounts, err := s.getImportAccounts(user, names, newAccount, result)
+	if err != nil {
+		return nil, err
+	}
+	accountUUIDs := make(map[string]string, len(accounts))
+	for name, account := range accounts {
+		accountUUIDs[name] = account.UUID
+	}
+	return accountUUIDs, nil
+}
+
+// ImportQIF imports transactions from a QIF file.
+// Transactions from !Account blocks are imported into the account with the same name,
+// other transactions are imported into accountUUID (which can be empty if the file has !Account blocks).
+// Missing accounts are created with currency; if currency is empty, the currency of accountUUID is used.
+// Categories are saved as tags, and [Account] categories are imported as transfers.
+func (s *DBService) ImportQIF(user *User, accountUUID string, currency string, duplicates DuplicateOptions, reader io.Reader) (*ImportResult, error) {
+	result := &ImportResult{}
+	file, err := parseQIF(reader, result)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.update(user, func(s *DBService) error {
+		if accountUUID != "" {
+			account, err := s.getImportAccount(user, accountUUID, "")
+			if err != nil {
+				return err
+			}
+			if currency == "" {
+				currency = account.Currency
+			}
+		}
+
+		accountUUIDs, err := s.getQIFAccounts(user, file, currency, result)
+		if err != nil {
+			return err
+		}
+
+		transfers := make(qifTransfers)
+		transactions := make([]*importedTransaction, 0, len(file.Records))
+		for _, record := range file.Records {
+			transaction, err := record.convert(accountUUIDs, accountUUID, transfers)
+			if err != nil {
+				result.skip(record.Line, err.Error())
+				continue
+			}
+			if transaction != nil {
+				transactions = append(transactions, transaction)
+			}
+		}
+		return s.importTransactions(user, transactions, duplicates, result)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import transactions: %w", err)
+	}
+	return result, nil
+}
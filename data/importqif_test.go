@@ -0,0 +1,223 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testQIF = `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Account
+NSavings
+TBank
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 1'19
+T1,000.00
+POpening Balance
+L[Checking]
+^
+D1/ 2'19
+T-150.00
+PSupermarket
+MWeekly shopping
+SFood:Groceries
+$-100.00
+SHousehold/Home
+EDetergent
+$-50.00
+^
+D1/ 3'19
+T-200.00
+PSavings
+L[Savings]
+^
+D13/45'19
+T-1.00
+^
+D1/ 4'19
+T-100.00
+PHardware store
+SHousehold
+$-60.00
+SFood
+$-30.00
+^
+!Account
+NSavings
+TBank
+^
+!Type:Bank
+D1/ 3'19
+T200.00
+PSavings
+L[Checking]
+^
+!Account
+NCredit card
+TCCard
+^
+!Type:CCard
+D01/04/2019
+T-25.50
+PRestaurant
+LDining
+QUnknown
+^
+!Type:Invst
+D1/ 5'19
+NBuy
+^
+`
+
+func TestParseQIFDate(t *testing.T) {
+	tests := map[string]string{
+		"1/ 2'19":    "2019-01-02",
+		"12/31/1999": "1999-12-31",
+		"12/31/99":   "1999-12-31",
+		"1/2/05":     "2005-01-02",
+		"31.12.2020": "2020-12-31",
+		"2020-02-29": "2020-02-29",
+	}
+	for value, expected := range tests {
+		date, err := parseQIFDate(value)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, date, value)
+	}
+
+	for _, value := range []string{"", "1/2", "2/30/2020", "a/b/c"} {
+		_, err := parseQIFDate(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestImportQIF(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportQIF(&testUser, "", "USD", DuplicateOptions{}, strings.NewReader(testQIF))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 4,
+		Skipped: []string{
+			"line 66: unsupported field Q",
+			"line 68: unsupported section !Type:Invst",
+			"line 69: line is in unsupported section !Type:Invst",
+			"line 70: line is in unsupported section !Type:Invst",
+			"line 71: line is in unsupported section !Type:Invst",
+			"line 36: invalid date 13/45'19",
+			"line 39: total of splits -90.00 doesn't match transaction amount -100.00",
+		},
+		CreatedAccounts: []string{"Checking", "Savings", "Credit card"},
+	}, result)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 5)
+	accountUUIDs := make(map[string]string)
+	balances := make(map[string]int64)
+	for _, account := range accounts {
+		accountUUIDs[account.Name] = account.UUID
+		balances[account.Name] = account.Balance
+	}
+	assert.Equal(t, map[string]int64{
+		"Test 1":      0,
+		"Test 2":      0,
+		"Checking":    65000,
+		"Savings":     20000,
+		"Credit card": -2550,
+	}, balances)
+
+	assert.Equal(t, []*Transaction{{
+		Description: "Restaurant",
+		Type:        TransactionTypeExpenseIncome,
+		Tags:        []string{"Dining"},
+		Date:        "2019-01-04",
+		Components:  []TransactionComponent{{AccountUUID: accountUUIDs["Credit card"], Amount: -2550}},
+	}, {
+		Description: "Savings",
+		Type:        TransactionTypeTransfer,
+		Date:        "2019-01-03",
+		Components: []TransactionComponent{
+			{AccountUUID: accountUUIDs["Checking"], Amount: -20000},
+			{AccountUUID: accountUUIDs["Savings"], Amount: 20000},
+		},
+	}, {
+		Description: "Supermarket - Weekly shopping",
+		Type:        TransactionTypeExpenseIncome,
+		Tags:        []string{"Food:Groceries", "Household"},
+		Date:        "2019-01-02",
+		Components: []TransactionComponent{
+			{AccountUUID: accountUUIDs["Checking"], Amount: -10000},
+			{AccountUUID: accountUUIDs["Checking"], Amount: -5000},
+		},
+	}, {
+		Description: "Opening Balance",
+		Type:        TransactionTypeExpenseIncome,
+		Date:        "2019-01-01",
+		Components:  []TransactionComponent{{AccountUUID: accountUUIDs["Checking"], Amount: 100000}},
+	}}, getImportedTransactions(t))
+}
+
+func TestImportQIFIntoExistingAccount(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	qif := "!Type:Bank\nD3/1/2019\nT-10.00\nPCoffee\nL[Test 2]\n^\nD3/2/2019\nT-5.00\nL[Cash]\n^\n"
+	result, err := dbService.ImportQIF(&testUser, testAccount1.UUID, "", DuplicateOptions{}, strings.NewReader(qif))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{Imported: 2, CreatedAccounts: []string{"Cash"}}, result)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 3)
+	assert.Equal(t, "Cash", accounts[2].Name)
+	assert.Equal(t, "USD", accounts[2].Currency)
+	assert.Equal(t, int64(500), accounts[2].Balance)
+
+	transactions := getImportedTransactions(t)
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, &Transaction{
+		Description: "Coffee",
+		Type:        TransactionTypeTransfer,
+		Date:        "2019-03-01",
+		Components: []TransactionComponent{
+			{AccountUUID: testAccount1.UUID, Amount: -1000},
+			{AccountUUID: testAccount2.UUID, Amount: 1000},
+		},
+	}, transactions[1])
+}
+
+func TestImportQIFWithoutAccount(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	qif := "D3/1/2019\n!Type:Bank\nD3/1/2019\nT-10.00\n^\n"
+	result, err := dbService.ImportQIF(&testUser, "", "USD", DuplicateOptions{}, strings.NewReader(qif))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{Skipped: []string{
+		"line 1: line is not in a !Type section",
+		"line 3: transaction has no account",
+	}}, result)
+
+	result, err = dbService.ImportQIF(&testUser, "", "", DuplicateOptions{}, strings.NewReader("!Account\nNNew\n^\n"))
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Empty(t, getImportedTransactions(t))
+}
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ecbBaseCurrency is the currency of all ECB reference rates.
+const ecbBaseCurrency = "EUR"
+
+// ecbDocument is an ECB euro foreign exchange reference rates document, such as eurofxref-hist.xml.
+// Namespaces are ignored.
+type ecbDocument struct {
+	Days []ecbDay `xml:"Cube>Cube"`
+}
+
+// ecbDay contains the rates published on a day.
+type ecbDay struct {
+	Time  string    `xml:"time,attr"`
+	Rates []ecbRate `xml:"Cube"`
+}
+
+// ecbRate is the amount in Currency for one euro.
+type ecbRate struct {
+	Currency string `xml:"currency,attr"`
+	Rate     string `xml:"rate,attr"`
+}
+
+// parseExchangeRate parses and validates a rate to convert an amount from one currency to another.
+func parseExchangeRate(date, from, to, value string) (*ExchangeRate, error) {
+	rateValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse exchange rate %v: %w", value, err)
+	}
+	rate := &ExchangeRate{
+		Date: strings.TrimSpace(date),
+		From: from,
+		To:   to,
+		Rate: rateValue,
+	}
+	if err := rate.normalize(); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// parseExchangeRateRecord parses a Date,From,To,Rate or a Date,Currency,Rate CSV record.
+// Records with a Currency column contain the amount in Currency for one unit of base.
+func parseExchangeRateRecord(base string, record []string) (*ExchangeRate, error) {
+	switch len(record) {
+	case 4:
+		return parseExchangeRate(record[0], record[1], record[2], record[3])
+	case 3:
+		if base == "" {
+			return nil, fmt.Errorf("base currency is required for Date,Currency,Rate records")
+		}
+		return parseExchangeRate(record[0], base, record[1], record[2])
+	default:
+		return nil, fmt.Errorf("expected 3 or 4 columns, got %v", len(record))
+	}
+}
+
+// importExchangeRates saves imported rates.
+func (s *DBService) importExchangeRates(user *User, rates []*ExchangeRate, result *ImportResult) (*ImportResult, error) {
+	err := s.update(user, func(s *DBService) error {
+		return s.saveExchangeRates(user, rates)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import exchange rates: %w", err)
+	}
+	result.Imported = len(rates)
+	return result, nil
+}
+
+// ImportExchangeRatesCSV imports exchange rates from a CSV file with Date,From,To,Rate
+// or Date,Currency,Rate columns; the latter contain the amount in Currency for one unit of base.
+// The header line is optional.
+func (s *DBService) ImportExchangeRatesCSV(user *User, base string, reader io.Reader) (*ImportResult, error) {
+	base = strings.TrimSpace(base)
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	result := &ImportResult{}
+	rates := make([]*ExchangeRate, 0)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseError *csv.ParseError
+			if errors.As(err, &parseError) {
+				result.skip(parseError.Line, parseError.Err.Error())
+				continue
+			}
+			return nil, fmt.Errorf("cannot read CSV file: %w", err)
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		line, _ := csvReader.FieldPos(0)
+		if line == 1 && strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff")), "date") {
+			continue
+		}
+
+		rate, err := parseExchangeRateRecord(base, record)
+		if err != nil {
+			result.skip(line, err.Error())
+			continue
+		}
+		rates = append(rates, rate)
+	}
+
+	return s.importExchangeRates(user, rates, result)
+}
+
+// ImportExchangeRatesECB imports euro reference rates from an ECB XML file,
+// such as eurofxref-daily.xml or eurofxref-hist.xml.
+func (s *DBService) ImportExchangeRatesECB(user *User, reader io.Reader) (*ImportResult, error) {
+	document := &ecbDocument{}
+	if err := xml.NewDecoder(reader).Decode(document); err != nil {
+		return nil, fmt.Errorf("cannot parse ECB document: %w", err)
+	}
+	if len(document.Days) == 0 {
+		return nil, fmt.Errorf("ECB document has no exchange rates")
+	}
+
+	result := &ImportResult{}
+	rates := make([]*ExchangeRate, 0)
+	for _, day := range document.Days {
+		for _, ecbRate := range day.Rates {
+			rate, err := parseExchangeRate(day.Time, ecbBaseCurrency, ecbRate.Currency, ecbRate.Rate)
+			if err != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%v %v: %v", day.Time, ecbRate.Currency, err.Error()))
+				continue
+			}
+			rates = append(rates, rate)
+		}
+	}
+
+	return s.importExchangeRates(user, rates, result)
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportExchangeRatesCSV(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	file := "\ufeffDate,From,To,Rate\n" +
+		"2015-01-01,EUR,USD,1.1\n" +
+		"\n" +
+		"2015-01-02, EUR, USD, 1.2\n" +
+		"2015-01-02,EUR,USD\n" +
+		"2015-01-03,EUR,USD,abc\n" +
+		"2015-01-03,CHF,EUR,0.9\n"
+
+	result, err := dbService.ImportExchangeRatesCSV(&testUser, "", strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 3,
+		Skipped: []string{
+			"line 5: base currency is required for Date,Currency,Rate records",
+			`line 6: cannot parse exchange rate abc: strconv.ParseFloat: parsing "abc": invalid syntax`,
+		},
+	}, result)
+
+	rates, err := dbService.GetExchangeRates(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ExchangeRate{
+		{Date: "2015-01-03", From: "CHF", To: "EUR", Rate: 0.9},
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: 1.1},
+		{Date: "2015-01-02", From: "EUR", To: "USD", Rate: 1.2},
+	}, rates)
+}
+
+func TestImportExchangeRatesCSVWithBase(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	file := "date,currency,rate\n" +
+		"2015-01-01,USD,1.1\n" +
+		"2015-01-01,CHF,1.2\n" +
+		"2015-01-02,GBP\n" +
+		"2015-01-02,EUR,USD,1.15\n"
+
+	result, err := dbService.ImportExchangeRatesCSV(&testUser, "EUR", strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 3,
+		Skipped:  []string{"line 4: expected 3 or 4 columns, got 2"},
+	}, result)
+
+	rates, err := dbService.GetExchangeRates(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ExchangeRate{
+		{Date: "2015-01-01", From: "EUR", To: "CHF", Rate: 1.2},
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: 1.1},
+		{Date: "2015-01-02", From: "EUR", To: "USD", Rate: 1.15},
+	}, rates)
+}
+
+const testECBRates = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender>
+		<gesmes:name>European Central Bank</gesmes:name>
+	</gesmes:Sender>
+	<Cube>
+		<Cube time="2015-01-05">
+			<Cube currency="USD" rate="1.1915"/>
+			<Cube currency="CHF" rate="1.2027"/>
+		</Cube>
+		<Cube time="2015-01-02">
+			<Cube currency="USD" rate="1.2043"/>
+			<Cube currency="CHF" rate="1.2022"/>
+			<Cube currency="XXX" rate="N/A"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>
+`
+
+func TestImportExchangeRatesECB(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	result, err := dbService.ImportExchangeRatesECB(&testUser, strings.NewReader(testECBRates))
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{
+		Imported: 4,
+		Skipped:  []string{`2015-01-02 XXX: cannot parse exchange rate N/A: strconv.ParseFloat: parsing "N/A": invalid syntax`},
+	}, result)
+
+	rates, err := dbService.GetExchangeRates(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ExchangeRate{
+		{Date: "2015-01-02", From: "EUR", To: "CHF", Rate: 1.2022},
+		{Date: "2015-01-05", From: "EUR", To: "CHF", Rate: 1.2027},
+		{Date: "2015-01-02", From: "EUR", To: "USD", Rate: 1.2043},
+		{Date: "2015-01-05", From: "EUR", To: "USD", Rate: 1.1915},
+	}, rates)
+}
+
+func TestImportExchangeRatesECBInvalid(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.ImportExchangeRatesECB(&testUser, strings.NewReader("<Envelope><Cube></Cube></Envelope>"))
+	assert.Error(t, err)
+
+	_, err = dbService.ImportExchangeRatesECB(&testUser, strings.NewReader("not xml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,434 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Plain-text accounting formats supported by ExportJournal.
+const (
+	JournalFormatLedger    = "ledger"
+	JournalFormatHledger   = "hledger"
+	JournalFormatBeancount = "beancount"
+)
+
+const (
+	// journalOpeningBalancesAccount balances the opening balances entry.
+	journalOpeningBalancesAccount = "Equity:Opening-Balances"
+	// journalConversionsAccount balances transfers between different currencies.
+	journalConversionsAccount = "Equity:Conversions"
+	// journalUncategorized is the category of expenses and income without tags.
+	journalUncategorized = "Uncategorized"
+	// journalUnknownCurrency is used for accounts without a currency.
+	journalUnknownCurrency = "XXX"
+	// journalDefaultOpenDate is used to open accounts if there are no transactions.
+	journalDefaultOpenDate = "1970-01-01"
+)
+
+// journalPosting is a posting of a journal entry.
+type journalPosting struct {
+	Account  string
+	Amount   int64
+	Currency string
+}
+
+// journalEntry is a journal transaction.
+type journalEntry struct {
+	Date        string
+	Description string
+	Tags        []string
+	Postings    []journalPosting
+}
+
+// journalFormat renders a plain-text accounting format.
+type journalFormat struct {
+	accountName  func(name string) string
+	currencyName func(currency string) string
+	commodity    func(date, currency string) string
+	open         func(date, account, currency string) string
+	header       func(entry *journalEntry) string
+	tags         func(tags []string) string
+	indent       string
+}
+
+// journalFormats contains renderers for all supported formats.
+var journalFormats = map[string]*journalFormat{
+	JournalFormatLedger: {
+		accountName:  ledgerAccountName,
+		currencyName: ledgerCurrencyName,
+		commodity: func(date, currency string) string {
+			return fmt.Sprintf("commodity %v\n", currency)
+		},
+		open:   ledgerOpen,
+		header: ledgerHeader,
+		tags: func(tags []string) string {
+			return fmt.Sprintf("    ; tags: %v\n", strings.Join(tags, ", "))
+		},
+		indent: "    ",
+	},
+	JournalFormatHledger: {
+		accountName:  ledgerAccountName,
+		currencyName: ledgerCurrencyName,
+		commodity: func(date, currency string) string {
+			return fmt.Sprintf("commodity 1000.00 %v\n", currency)
+		},
+		open:   ledgerOpen,
+		header: ledgerHeader,
+		tags: func(tags []string) string {
+			values := make([]string, len(tags))
+			for i, tag := range tags {
+				values[i] = hledgerTagName(tag) + ":"
+			}
+			return fmt.Sprintf("    ; %v\n", strings.Join(values, ", "))
+		},
+		indent: "    ",
+	},
+	JournalFormatBeancount: {
+		accountName:  beancountAccountName,
+		currencyName: beancountCurrencyName,
+		commodity: func(date, currency string) string {
+			return fmt.Sprintf("%v commodity %v\n", date, currency)
+		},
+		open: func(date, account, currency string) string {
+			if currency == "" {
+				return fmt.Sprintf("%v open %v\n", date, account)
+			}
+			return fmt.Sprintf("%v open %v %v\n", date, account, currency)
+		},
+		header: func(entry *journalEntry) string {
+			return fmt.Sprintf("%v * %v\n", entry.Date, beancountString(entry.Description))
+		},
+		tags: func(tags []string) string {
+			return fmt.Sprintf("  tags: %v\n", beancountString(strings.Join(tags, ", ")))
+		},
+		indent: "  ",
+	},
+}
+
+// ledgerAccountName removes whitespace which cannot be used in ledger account names.
+func ledgerAccountName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ledgerCurrencyName quotes currencies which contain non-letter characters.
+func ledgerCurrencyName(currency string) string {
+	for _, c := range currency {
+		if !unicode.IsLetter(c) {
+			return `"` + strings.ReplaceAll(currency, `"`, "") + `"`
+		}
+	}
+	return currency
+}
+
+// hledgerTagName replaces characters which cannot be used in hledger tag names with underscores:
+// a tag name ends with a colon, cannot contain whitespace and a comma ends the tag value.
+func hledgerTagName(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ':' || r == ',' || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, tag)
+}
+
+// ledgerOpen returns a ledger account directive.
+func ledgerOpen(date, account, currency string) string {
+	return fmt.Sprintf("account %v\n", account)
+}
+
+// ledgerHeader returns the first line of a ledger transaction.
+func ledgerHeader(entry *journalEntry) string {
+	return strings.TrimSpace(entry.Date+" "+strings.Join(strings.Fields(entry.Description), " ")) + "\n"
+}
+
+// beancountAccountName converts name into a valid beancount account name:
+// each component starts with an uppercase letter or digit, and only contains letters, digits and dashes.
+func beancountAccountName(name string) string {
+	components := strings.Split(name, ":")
+	for i, component := range components {
+		runes := []rune(strings.TrimSpace(component))
+		for j, c := range runes {
+			if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+				runes[j] = '-'
+			}
+		}
+		if len(runes) == 0 {
+			runes = []rune("Unnamed")
+		} else if runes[0] == '-' {
+			runes = append([]rune("X"), runes...)
+		}
+		runes[0] = unicode.ToUpper(runes[0])
+		components[i] = string(runes)
+	}
+	return strings.Join(components, ":")
+}
+
+// beancountCurrencyName converts currency into a valid beancount commodity name.
+func beancountCurrencyName(currency string) string {
+	var name strings.Builder
+	for _, c := range strings.ToUpper(currency) {
+		if (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			name.WriteRune(c)
+		}
+	}
+	if name.Len() == 0 {
+		return journalUnknownCurrency
+	}
+	if value := name.String(); value[0] < 'A' || value[0] > 'Z' {
+		return "C" + value
+	}
+	return name.String()
+}
+
+// beancountString returns value as a quoted beancount string.
+func beancountString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + strings.Join(strings.Fields(value), " ") + `"`
+}
+
+// journalExport converts accounts and transactions into journal entries.
+type journalExport struct {
+	format *journalFormat
+	// accountNames maps account UUIDs to journal account names.
+	accountNames map[string]string
+	// accountCurrencies maps account UUIDs to journal currencies.
+	accountCurrencies map[string]string
+	// usedAccounts contains the journal accounts referenced by postings and their currency constraints.
+	usedAccounts map[string]string
+	// accountsOrder contains the order in which accounts should be opened.
+	accountsOrder []string
+	entries       []*journalEntry
+}
+
+// newJournalExport creates a journalExport and assigns unique journal names to accounts.
+func newJournalExport(format *journalFormat, accounts []*Account) *journalExport {
+	export := &journalExport{
+		format:            format,
+		accountNames:      make(map[string]string),
+		accountCurrencies: make(map[string]string),
+		usedAccounts:      make(map[string]string),
+		accountsOrder:     make([]string, 0, len(accounts)),
+	}
+	for _, account := range accounts {
+		name := format.accountName("Assets:" + account.Name)
+		if _, ok := export.usedAccounts[name]; ok {
+			name = format.accountName(fmt.Sprintf("Assets:%v-%v", account.Name, account.UUID))
+		}
+		currency := account.Currency
+		if currency == "" {
+			currency = journalUnknownCurrency
+		}
+		currency = format.currencyName(currency)
+
+		export.accountNames[account.UUID] = name
+		export.accountCurrencies[account.UUID] = currency
+		export.useAccount(name, currency)
+	}
+	return export
+}
+
+// useAccount registers a journal account, so that it will be opened.
+func (export *journalExport) useAccount(name string, currency string) {
+	if _, ok := export.usedAccounts[name]; ok {
+		return
+	}
+	export.usedAccounts[name] = currency
+	export.accountsOrder = append(export.accountsOrder, name)
+}
+
+// addEntry adds an entry with postings for components.
+// Postings are added to balance the entry in each currency;
+// balanceAccount returns the account to use for the balancing posting.
+func (export *journalExport) addEntry(entry *journalEntry, components []TransactionComponent, balanceAccount func(amount int64) string) {
+	residuals := make(map[string]int64)
+	currencies := make([]string, 0, 1)
+	for _, component := range components {
+		name, ok := export.accountNames[component.AccountUUID]
+		currency := export.accountCurrencies[component.AccountUUID]
+		if !ok {
+			name = export.format.accountName("Assets:Unknown-" + component.AccountUUID)
+			currency = export.format.currencyName(journalUnknownCurrency)
+			export.accountNames[component.AccountUUID] = name
+			export.accountCurrencies[component.AccountUUID] = currency
+			export.useAccount(name, currency)
+		}
+		if _, ok := residuals[currency]; !ok {
+			currencies = append(currencies, currency)
+		}
+		residuals[currency] += component.Amount
+		entry.Postings = append(entry.Postings, journalPosting{Account: name, Amount: component.Amount, Currency: currency})
+	}
+	for _, currency := range currencies {
+		residual := residuals[currency]
+		if residual == 0 {
+			continue
+		}
+		name := balanceAccount(residual)
+		export.useAccount(name, "")
+		entry.Postings = append(entry.Postings, journalPosting{Account: name, Amount: -residual, Currency: currency})
+	}
+	export.entries = append(export.entries, entry)
+}
+
+// addTransaction adds a journal entry for transaction.
+// Expenses and income are balanced with a category account based on the first tag,
+// transfers between currencies are balanced with a conversions account.
+func (export *journalExport) addTransaction(transaction *Transaction) {
+	entry := &journalEntry{
+		Date:        transaction.Date,
+		Description: transaction.Description,
+		Tags:        transaction.Tags,
+	}
+	balanceAccount := func(amount int64) string {
+		if transaction.Type == TransactionTypeTransfer {
+			return journalConversionsAccount
+		}
+		category := journalUncategorized
+		if len(transaction.Tags) > 0 {
+			category = transaction.Tags[0]
+		}
+		if amount < 0 {
+			return export.format.accountName("Expenses:" + category)
+		}
+		return export.format.accountName("Income:" + category)
+	}
+	export.addEntry(entry, transaction.Components, balanceAccount)
+}
+
+// addOpeningBalances adds an entry with the balances of accounts on date.
+func (export *journalExport) addOpeningBalances(date string, accounts []*Account, balances map[string]int64) {
+	components := make([]TransactionComponent, 0, len(accounts))
+	for _, account := range accounts {
+		if balance := balances[account.UUID]; balance != 0 {
+			components = append(components, TransactionComponent{AccountUUID: account.UUID, Amount: balance})
+		}
+	}
+	if len(components) == 0 {
+		return
+	}
+	entry := &journalEntry{Date: date, Description: "Opening balances"}
+	export.addEntry(entry, components, func(int64) string { return journalOpeningBalancesAccount })
+}
+
+// write renders the journal.
+func (export *journalExport) write(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	format := export.format
+
+	openDate := journalDefaultOpenDate
+	if len(export.entries) > 0 {
+		openDate = export.entries[0].Date
+	}
+
+	fmt.Fprint(w, "; Exported from Vogon\n\n")
+
+	currencies := make([]string, 0)
+	for _, account := range export.accountsOrder {
+		if currency := export.usedAccounts[account]; currency != "" {
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Strings(currencies)
+	for i, currency := range currencies {
+		if i == 0 || currencies[i-1] != currency {
+			fmt.Fprint(w, format.commodity(openDate, currency))
+		}
+	}
+	fmt.Fprintln(w)
+
+	for _, account := range export.accountsOrder {
+		fmt.Fprint(w, format.open(openDate, account, export.usedAccounts[account]))
+	}
+
+	for _, entry := range export.entries {
+		fmt.Fprintln(w)
+		fmt.Fprint(w, format.header(entry))
+		if len(entry.Tags) > 0 {
+			fmt.Fprint(w, format.tags(entry.Tags))
+		}
+		for _, posting := range entry.Postings {
+			fmt.Fprintf(w, "%v%v  %v %v\n", format.indent, posting.Account, formatAmount(posting.Amount), posting.Currency)
+		}
+	}
+	return w.Flush()
+}
+
+// ExportJournal writes accounts and transactions for user in a plain-text accounting format (ledger, hledger or beancount).
+// Only transactions matching options are exported.
+// If options has a start date, the journal starts with the opening balances of all accounts on that date.
+func (s *DBService) ExportJournal(user *User, format string, options TransactionFilterOptions, writer io.Writer) error {
+	journalFormat, ok := journalFormats[format]
+	if !ok {
+		return fmt.Errorf("unsupported journal format %v", format)
+	}
+
+	var accounts []*Account
+	var transactionUUIDs []string
+	var openingDate string
+	var openingBalances map[string]int64
+	err := s.view(user, func() error {
+		var err error
+		accounts, err = s.getAccounts(user)
+		if err != nil {
+			return fmt.Errorf("failed to get accounts: %w", err)
+		}
+
+		if err := options.normalizeDates(); err != nil {
+			return err
+		}
+		if options.FilterFromDate != "" {
+			fromDate, err := time.Parse(dateFormat, options.FilterFromDate)
+			if err != nil {
+				return err
+			}
+			openingDate = options.FilterFromDate
+			openingBalances, err = s.getAccountBalances(user, TransactionFilterOptions{
+				FilterToDate: fromDate.AddDate(0, 0, -1).Format(dateFormat),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to get opening balances: %w", err)
+			}
+		}
+
+		transactionUUIDs, err = s.getMatchingTransactionUUIDs(user, &options)
+		if err != nil {
+			return fmt.Errorf("failed to get transactions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get data to export: %w", err)
+	}
+
+	export := newJournalExport(journalFormat, accounts)
+	if openingBalances != nil {
+		export.addOpeningBalances(openingDate, accounts, openingBalances)
+	}
+	// Add transactions in reverse order, oldest first.
+	for i, j := 0, len(transactionUUIDs)-1; i < j; i, j = i+1, j-1 {
+		transactionUUIDs[i], transactionUUIDs[j] = transactionUUIDs[j], transactionUUIDs[i]
+	}
+	emptyFilter := options.IsEmpty()
+	err = s.iterateTransactionPages(user, transactionUUIDs, nil, func(transactions []*Transaction) error {
+		for _, transaction := range transactions {
+			if emptyFilter || options.Matches(transaction) {
+				export.addTransaction(transaction)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get transactions to export: %w", err)
+	}
+
+	if err := export.write(writer); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createJournalTestTransactions(t *testing.T) {
+	transactions := []*Transaction{{
+		Description: "Salary",
+		Type:        TransactionTypeExpenseIncome,
+		Tags:        []string{"Salary"},
+		Date:        "2019-01-01",
+		Components:  []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 100000}},
+	}, {
+		Description: `Groceries "Corner shop"`,
+		Type:        TransactionTypeExpenseIncome,
+		Tags:        []string{"Food", "Household items"},
+		Date:        "2019-01-02",
+		Components:  []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: -1250}},
+	}, {
+		Description: "Exchange",
+		Type:        TransactionTypeTransfer,
+		Date:        "2019-01-03",
+		Components: []TransactionComponent{
+			{AccountUUID: testAccount1.UUID, Amount: -10000},
+			{AccountUUID: testAccount2.UUID, Amount: 9000},
+		},
+	}}
+	for _, transaction := range transactions {
+		err := dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+}
+
+func TestExportBeancount(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+	createJournalTestTransactions(t)
+
+	var journal bytes.Buffer
+	err = dbService.ExportJournal(&testUser, JournalFormatBeancount, TransactionFilterOptions{}, &journal)
+	assert.NoError(t, err)
+	assert.Equal(t, `; Exported from Vogon
+
+2019-01-01 commodity EUR
+2019-01-01 commodity USD
+
+2019-01-01 open Assets:Test-1 USD
+2019-01-01 open Assets:Test-2 EUR
+2019-01-01 open Income:Salary
+2019-01-01 open Expenses:Food
+2019-01-01 open Equity:Conversions
+
+2019-01-01 * "Salary"
+  tags: "Salary"
+  Assets:Test-1  1000.00 USD
+  Income:Salary  -1000.00 USD
+
+2019-01-02 * "Groceries \"Corner shop\""
+  tags: "Food, Household items"
+  Assets:Test-1  -12.50 USD
+  Expenses:Food  12.50 USD
+
+2019-01-03 * "Exchange"
+  Assets:Test-1  -100.00 USD
+  Assets:Test-2  90.00 EUR
+  Equity:Conversions  100.00 USD
+  Equity:Conversions  -90.00 EUR
+`, journal.String())
+}
+
+func TestExportLedgerDateRange(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+	createJournalTestTransactions(t)
+
+	var journal bytes.Buffer
+	options := TransactionFilterOptions{FilterFromDate: "2019-1-2", FilterToDate: "2019-01-02"}
+	err = dbService.ExportJournal(&testUser, JournalFormatLedger, options, &journal)
+	assert.NoError(t, err)
+	assert.Equal(t, `; Exported from Vogon
+
+commodity EUR
+commodity USD
+
+account Assets:Test 1
+account Assets:Test 2
+account Equity:Opening-Balances
+account Expenses:Food
+
+2019-01-02 Opening balances
+    Assets:Test 1  1000.00 USD
+    Equity:Opening-Balances  -1000.00 USD
+
+2019-01-02 Groceries "Corner shop"
+    ; tags: Food, Household items
+    Assets:Test 1  -12.50 USD
+    Expenses:Food  12.50 USD
+`, journal.String())
+}
+
+func TestExportHledger(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+	createJournalTestTransactions(t)
+
+	var journal bytes.Buffer
+	options := TransactionFilterOptions{FilterTags: []string{"Food"}}
+	err = dbService.ExportJournal(&testUser, JournalFormatHledger, options, &journal)
+	assert.NoError(t, err)
+	assert.Equal(t, `; Exported from Vogon
+
+commodity 1000.00 EUR
+commodity 1000.00 USD
+
+account Assets:Test 1
+account Assets:Test 2
+account Expenses:Food
+
+2019-01-02 Groceries "Corner shop"
+    ; Food:, Household_items:
+    Assets:Test 1  -12.50 USD
+    Expenses:Food  12.50 USD
+`, journal.String())
+}
+
+func TestExportJournalUnsupportedFormat(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	var journal bytes.Buffer
+	err = dbService.ExportJournal(&testUser, "gnucash", TransactionFilterOptions{}, &journal)
+	assert.Error(t, err)
+	assert.Empty(t, journal.String())
+}
+
+func TestHledgerTagName(t *testing.T) {
+	assert.Equal(t, "Food", hledgerTagName("Food"))
+	assert.Equal(t, "Household_items", hledgerTagName("Household items"))
+	assert.Equal(t, "a_b_c_d", hledgerTagName("a:b,c\td"))
+}
+
+func TestBeancountNames(t *testing.T) {
+	assert.Equal(t, "Assets:Credit-card", beancountAccountName("Assets:credit card"))
+	assert.Equal(t, "Expenses:X-gifts:Unnamed", beancountAccountName("Expenses:(gifts:"))
+	assert.Equal(t, "Assets:Ünicode", beancountAccountName("Assets:ünicode"))
+	assert.Equal(t, "USD", beancountCurrencyName("usd"))
+	assert.Equal(t, "C1INCH", beancountCurrencyName("1inch"))
+	assert.Equal(t, "XXX", beancountCurrencyName("€"))
+}
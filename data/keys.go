@@ -3,6 +3,7 @@ package data
 import (
 	"encoding/base64"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -23,6 +24,14 @@ func decodePart(part string) (string, error) {
 	return string(res), nil
 }
 
+// journalKey is the key of the write-ahead journal header.
+const journalKey = "journal"
+
+// createJournalChunkKey creates a key for a chunk of the write-ahead journal.
+func createJournalChunkKey(chunk int) []byte {
+	return []byte(journalKey + separator + strconv.Itoa(chunk))
+}
+
 // userKeyPrefix is the key prefix for User entries.
 const userKeyPrefix = "user" + separator
 
@@ -96,3 +105,153 @@ const serverConfigKeyPrefix = "serverconfig" + separator
 func createServerConfigKey(varName string) []byte {
 	return []byte(serverConfigKeyPrefix + encodePart(varName))
 }
+
+// importProfileKeyPrefix is the key prefix for ImportProfile.
+const importProfileKeyPrefix = "importprofile" + separator
+
+// createImportProfileKeyPrefix creates an ImportProfile key prefix for user.
+func (user *User) createImportProfileKeyPrefix() string {
+	return importProfileKeyPrefix + user.UUID
+}
+
+// createImportProfileKeyFromUUID creates a key for an ImportProfile based on its UUID.
+func (user *User) createImportProfileKeyFromUUID(profileUUID string) []byte {
+	return []byte(user.createImportProfileKeyPrefix() + separator + profileUUID)
+}
+
+// createImportProfileKey creates a key for an ImportProfile entry.
+func (user *User) createImportProfileKey(profile *ImportProfile) []byte {
+	return user.createImportProfileKeyFromUUID(profile.UUID)
+}
+
+// importKeyPrefix is the key prefix for imported statement entries.
+const importKeyPrefix = "importkey" + separator
+
+// createImportKey creates a key for a statement entry imported into an Account.
+func (user *User) createImportKey(accountUUID, entryID string) []byte {
+	return []byte(importKeyPrefix + user.UUID + separator + accountUUID + separator + encodePart(entryID))
+}
+
+// recurringKeyPrefix is the key prefix for RecurringTransaction.
+const recurringKeyPrefix = "recurring" + separator
+
+// recurringUsersKey is the index key for users who have recurring transactions.
+const recurringUsersKey = "recurringusers"
+
+// createRecurringKeyPrefix creates a RecurringTransaction key prefix for user.
+func (user *User) createRecurringKeyPrefix() string {
+	return recurringKeyPrefix + user.UUID
+}
+
+// createRecurringKeyFromUUID creates a key for a RecurringTransaction based on its UUID.
+func (user *User) createRecurringKeyFromUUID(recurringUUID string) []byte {
+	return []byte(user.createRecurringKeyPrefix() + separator + recurringUUID)
+}
+
+// createRecurringKey creates a key for a RecurringTransaction entry.
+func (user *User) createRecurringKey(recurring *RecurringTransaction) []byte {
+	return user.createRecurringKeyFromUUID(recurring.UUID)
+}
+
+// budgetKeyPrefix is the key prefix for Budget.
+const budgetKeyPrefix = "budget" + separator
+
+// createBudgetKeyPrefix creates a Budget key prefix for user.
+func (user *User) createBudgetKeyPrefix() string {
+	return budgetKeyPrefix + user.UUID
+}
+
+// createBudgetKeyFromUUID creates a key for a Budget based on its UUID.
+func (user *User) createBudgetKeyFromUUID(budgetUUID string) []byte {
+	return []byte(user.createBudgetKeyPrefix() + separator + budgetUUID)
+}
+
+// createBudgetKey creates a key for a Budget entry.
+func (user *User) createBudgetKey(budget *Budget) []byte {
+	return user.createBudgetKeyFromUUID(budget.UUID)
+}
+
+// exchangeRateKeyPrefix is the key prefix for exchange rates.
+const exchangeRateKeyPrefix = "exchangerate" + separator
+
+// createExchangeRateKeyPrefix creates an exchange rate key prefix for user.
+func (user *User) createExchangeRateKeyPrefix() string {
+	return exchangeRateKeyPrefix + user.UUID
+}
+
+// createExchangeRatePair creates an index entry for a currency pair.
+func createExchangeRatePair(from, to string) string {
+	return encodePart(from) + separator + encodePart(to)
+}
+
+// decodeExchangeRatePair decodes the currencies from an index entry of a currency pair.
+func decodeExchangeRatePair(pair []byte) (string, string, error) {
+	parts := strings.Split(string(pair), separator)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid format of exchange rate pair: %v", string(pair))
+	}
+	from, err := decodePart(parts[0])
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode currency %v: %w", parts[0], err)
+	}
+	to, err := decodePart(parts[1])
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode currency %v: %w", parts[1], err)
+	}
+	return from, to, nil
+}
+
+// createExchangeRateKey creates a key for the exchange rates of a currency pair.
+func (user *User) createExchangeRateKey(from, to string) []byte {
+	return []byte(user.createExchangeRateKeyPrefix() + separator + createExchangeRatePair(from, to))
+}
+
+// indexVersionKey is the key for the version of indexes, used to build new indexes for existing items.
+const indexVersionKey = "indexversion"
+
+// tagIndexKeyPrefix is the key prefix for the index of transactions by tag.
+const tagIndexKeyPrefix = "tagindex" + separator
+
+// createTagIndexKeyPrefix creates a tag index key prefix for user.
+// The prefix itself is the index key for the list of user's tags.
+func (user *User) createTagIndexKeyPrefix() string {
+	return tagIndexKeyPrefix + user.UUID
+}
+
+// createTagIndexKey creates an index key for transactions with tag.
+func (user *User) createTagIndexKey(tag string) []byte {
+	return []byte(user.createTagIndexKeyPrefix() + separator + encodePart(tag))
+}
+
+// createSecondaryIndexBucketKey creates a key for a bucket of a tag, account or full-text index key.
+// The index key itself is the index key for the sorted list of its buckets.
+func createSecondaryIndexBucketKey(indexKey []byte, bucket string) []byte {
+	return []byte(string(indexKey) + separator + bucket)
+}
+
+// accountTransactionsIndexKeyPrefix is the key prefix for the index of transactions by account.
+const accountTransactionsIndexKeyPrefix = "accounttransactions" + separator
+
+// createAccountTransactionsIndexKeyPrefix creates an account transactions index key prefix for user.
+func (user *User) createAccountTransactionsIndexKeyPrefix() string {
+	return accountTransactionsIndexKeyPrefix + user.UUID
+}
+
+// createAccountTransactionsIndexKey creates an index key for transactions with components in accountUUID.
+func (user *User) createAccountTransactionsIndexKey(accountUUID string) []byte {
+	return []byte(user.createAccountTransactionsIndexKeyPrefix() + separator + accountUUID)
+}
+
+// searchIndexKeyPrefix is the key prefix for the full-text index of transaction descriptions.
+const searchIndexKeyPrefix = "searchindex" + separator
+
+// createSearchIndexKeyPrefix creates a full-text index key prefix for user.
+// The prefix itself is the index key for the sorted list of indexed words.
+func (user *User) createSearchIndexKeyPrefix() string {
+	return searchIndexKeyPrefix + user.UUID
+}
+
+// createSearchIndexKey creates an index key for transactions with word in their description.
+func (user *User) createSearchIndexKey(word string) []byte {
+	return []byte(user.createSearchIndexKeyPrefix() + separator + encodePart(word))
+}
@@ -0,0 +1,199 @@
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrReconciledComponent is an error when a transaction update would change a reconciled component.
+var ErrReconciledComponent = fmt.Errorf("reconciled transaction components cannot be changed")
+
+// ErrReconciliationDifference is an error when a reconciliation cannot be finished because the cleared balance doesn't match the statement.
+var ErrReconciliationDifference = fmt.Errorf("cleared balance doesn't match closing balance")
+
+// Reconciliation compares the cleared balance of an account with a bank statement.
+type Reconciliation struct {
+	AccountUUID    string
+	StatementDate  string
+	ClosingBalance int64
+	// ClearedBalance is the total of cleared and reconciled components up to StatementDate.
+	ClearedBalance int64
+	// Difference is the amount missing from ClearedBalance to match ClosingBalance.
+	Difference int64
+	// Uncleared lists transactions up to StatementDate with uncleared components for the account.
+	Uncleared []*Transaction
+}
+
+// checkReconciledComponents checks that transaction keeps all reconciled components of previousTransaction
+// and doesn't reconcile any new components.
+func checkReconciledComponents(previousTransaction, transaction *Transaction) error {
+	reconciledComponents := make([]TransactionComponent, 0, len(transaction.Components))
+	for _, component := range transaction.Components {
+		if component.Status == ComponentStatusReconciled {
+			reconciledComponents = append(reconciledComponents, component)
+		}
+	}
+
+	hasReconciled := false
+	for _, previousComponent := range previousTransaction.Components {
+		if previousComponent.Status != ComponentStatusReconciled {
+			continue
+		}
+		hasReconciled = true
+		found := false
+		for i, component := range reconciledComponents {
+			if component == previousComponent {
+				reconciledComponents = append(reconciledComponents[:i], reconciledComponents[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrReconciledComponent
+		}
+	}
+	if len(reconciledComponents) > 0 {
+		// Components can only be reconciled by finishing a reconciliation.
+		return ErrReconciledComponent
+	}
+	if !hasReconciled {
+		return nil
+	}
+
+	previousDate, err := time.Parse(inputDateFormat, previousTransaction.Date)
+	if err != nil {
+		return fmt.Errorf("cannot parse date %v: %w", previousTransaction.Date, err)
+	}
+	date, err := time.Parse(inputDateFormat, transaction.Date)
+	if err != nil {
+		return fmt.Errorf("cannot parse date %v: %w", transaction.Date, err)
+	}
+	if !date.Equal(previousDate) {
+		return ErrReconciledComponent
+	}
+	return nil
+}
+
+// clearReconciledComponents changes the status of reconciled components to cleared.
+// Components can only be reconciled by finishing a reconciliation, and not by creating a transaction.
+func clearReconciledComponents(transaction *Transaction) {
+	for i := range transaction.Components {
+		if transaction.Components[i].Status == ComponentStatusReconciled {
+			transaction.Components[i].Status = ComponentStatusCleared
+		}
+	}
+}
+
+// reconcile compares the cleared balance of accountUUID with closingBalance.
+// Also returns the transactions which have cleared, but not yet reconciled components for the account.
+func (s *DBService) reconcile(user *User, accountUUID string, statementDate string, closingBalance int64) (*Reconciliation, []*Transaction, error) {
+	date, err := time.Parse(inputDateFormat, statementDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse statement date %v: %w", statementDate, err)
+	}
+	statementDate = date.Format(dateFormat)
+
+	account, err := s.getAccount(user, accountUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if account == nil {
+		return nil, nil, fmt.Errorf("account %v doesn't exist", accountUUID)
+	}
+
+	options := GetAllTransactionsOptions
+	options.FilterAccounts = []string{accountUUID}
+	options.FilterToDate = statementDate
+	transactions, err := s.getTransactions(user, options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+
+	reconciliation := &Reconciliation{
+		AccountUUID:    accountUUID,
+		StatementDate:  statementDate,
+		ClosingBalance: closingBalance,
+		Uncleared:      make([]*Transaction, 0),
+	}
+	cleared := make([]*Transaction, 0)
+	for _, transaction := range transactions {
+		hasUncleared, hasCleared := false, false
+		for _, component := range transaction.Components {
+			if component.AccountUUID != accountUUID {
+				continue
+			}
+			switch component.Status {
+			case ComponentStatusUncleared:
+				hasUncleared = true
+			case ComponentStatusCleared:
+				hasCleared = true
+				reconciliation.ClearedBalance += component.Amount
+			case ComponentStatusReconciled:
+				reconciliation.ClearedBalance += component.Amount
+			}
+		}
+		if hasUncleared {
+			reconciliation.Uncleared = append(reconciliation.Uncleared, transaction)
+		}
+		if hasCleared {
+			cleared = append(cleared, transaction)
+		}
+	}
+	reconciliation.Difference = closingBalance - reconciliation.ClearedBalance
+	return reconciliation, cleared, nil
+}
+
+// Reconcile compares the cleared balance of an account with a statement's closingBalance on statementDate.
+func (s *DBService) Reconcile(user *User, accountUUID string, statementDate string, closingBalance int64) (*Reconciliation, error) {
+	var reconciliation *Reconciliation
+	err := s.view(user, func() error {
+		var err error
+		reconciliation, _, err = s.reconcile(user, accountUUID, statementDate, closingBalance)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile account %v: %w", accountUUID, err)
+	}
+	return reconciliation, nil
+}
+
+// FinishReconciliation marks all cleared components of an account up to statementDate as reconciled,
+// locking them from further changes.
+// Returns an error if the cleared balance doesn't match closingBalance.
+func (s *DBService) FinishReconciliation(user *User, accountUUID string, statementDate string, closingBalance int64) (*Reconciliation, error) {
+	var reconciliation *Reconciliation
+	err := s.update(user, func(s *DBService) error {
+		var cleared []*Transaction
+		var err error
+		reconciliation, cleared, err = s.reconcile(user, accountUUID, statementDate, closingBalance)
+		if err != nil {
+			return err
+		}
+		if reconciliation.Difference != 0 {
+			return fmt.Errorf("cleared balance %v, closing balance %v: %w", formatAmount(reconciliation.ClearedBalance), formatAmount(closingBalance), ErrReconciliationDifference)
+		}
+
+		for _, transaction := range cleared {
+			for i := range transaction.Components {
+				component := &transaction.Components[i]
+				if component.AccountUUID == accountUUID && component.Status == ComponentStatusCleared {
+					component.Status = ComponentStatusReconciled
+				}
+			}
+
+			key := user.createTransactionKey(transaction)
+			value, err := transaction.encode()
+			if err != nil {
+				return fmt.Errorf("cannot encode transaction: %w", err)
+			}
+			if err := s.db.Put(key, value); err != nil {
+				return fmt.Errorf("cannot save transaction %v: %w", string(key), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish reconciliation for account %v: %w", accountUUID, err)
+	}
+	return reconciliation, nil
+}
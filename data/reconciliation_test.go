@@ -0,0 +1,190 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createReconciliationTransactions(t *testing.T) (*Transaction, *Transaction, *Transaction) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{
+		Description: "t1",
+		Date:        "2019-03-10",
+		Type:        TransactionTypeExpenseIncome,
+		Components: []TransactionComponent{
+			{AccountUUID: testAccount1.UUID, Amount: 10000, Status: ComponentStatusCleared},
+		},
+	}
+	transaction2 := &Transaction{
+		Description: "t2",
+		Date:        "2019-03-15",
+		Type:        TransactionTypeTransfer,
+		Components: []TransactionComponent{
+			{AccountUUID: testAccount1.UUID, Amount: -2500, Status: ComponentStatusCleared},
+			{AccountUUID: testAccount2.UUID, Amount: 2500},
+		},
+	}
+	transaction3 := &Transaction{
+		Description: "t3",
+		Date:        "2019-03-20",
+		Type:        TransactionTypeExpenseIncome,
+		Components: []TransactionComponent{
+			{AccountUUID: testAccount1.UUID, Amount: -1000},
+		},
+	}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+	return transaction1, transaction2, transaction3
+}
+
+func TestReconcile(t *testing.T) {
+	_, _, transaction3 := createReconciliationTransactions(t)
+
+	reconciliation, err := dbService.Reconcile(&testUser, testAccount1.UUID, "2019-3-31", 6500)
+	assert.NoError(t, err)
+	assert.Equal(t, &Reconciliation{
+		AccountUUID:    testAccount1.UUID,
+		StatementDate:  "2019-03-31",
+		ClosingBalance: 6500,
+		ClearedBalance: 7500,
+		Difference:     -1000,
+		Uncleared:      []*Transaction{transaction3},
+	}, reconciliation)
+
+	reconciliation, err = dbService.Reconcile(&testUser, testAccount1.UUID, "2019-03-12", 10000)
+	assert.NoError(t, err)
+	assert.Equal(t, &Reconciliation{
+		AccountUUID:    testAccount1.UUID,
+		StatementDate:  "2019-03-12",
+		ClosingBalance: 10000,
+		ClearedBalance: 10000,
+		Uncleared:      []*Transaction{},
+	}, reconciliation)
+
+	_, err = dbService.Reconcile(&testUser, "missing", "2019-03-12", 10000)
+	assert.Error(t, err)
+	_, err = dbService.Reconcile(&testUser, testAccount1.UUID, "2019-13-12", 10000)
+	assert.Error(t, err)
+}
+
+func TestFinishReconciliation(t *testing.T) {
+	transaction1, transaction2, transaction3 := createReconciliationTransactions(t)
+
+	_, err := dbService.FinishReconciliation(&testUser, testAccount1.UUID, "2019-03-31", 6500)
+	assert.ErrorIs(t, err, ErrReconciliationDifference)
+
+	reconciliation, err := dbService.FinishReconciliation(&testUser, testAccount1.UUID, "2019-03-31", 7500)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), reconciliation.Difference)
+	assert.Equal(t, []*Transaction{transaction3}, reconciliation.Uncleared)
+
+	transaction1.Components[0].Status = ComponentStatusReconciled
+	transaction2.Components[0].Status = ComponentStatusReconciled
+	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction3, transaction2, transaction1}, transactions)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10000-2500-1000), accounts[0].Balance)
+}
+
+func TestUpdateReconciledTransaction(t *testing.T) {
+	transaction1, transaction2, _ := createReconciliationTransactions(t)
+
+	_, err := dbService.FinishReconciliation(&testUser, testAccount1.UUID, "2019-03-31", 7500)
+	assert.NoError(t, err)
+	transaction1.Components[0].Status = ComponentStatusReconciled
+	transaction2.Components[0].Status = ComponentStatusReconciled
+
+	// Unlocked fields and components can be changed.
+	update := *transaction2
+	update.Description = "t2-"
+	update.Tags = []string{"t4"}
+	update.Components = []TransactionComponent{transaction2.Components[0], {AccountUUID: testAccount2.UUID, Amount: 2000}}
+	err = dbService.UpdateTransaction(&testUser, &update, false)
+	assert.NoError(t, err)
+
+	for _, rejected := range []Transaction{
+		{UUID: transaction1.UUID, Date: "2019-03-10", Components: []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 9000, Status: ComponentStatusReconciled}}},
+		{UUID: transaction1.UUID, Date: "2019-03-10", Components: []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 10000, Status: ComponentStatusCleared}}},
+		{UUID: transaction1.UUID, Date: "2019-03-10"},
+		{UUID: transaction1.UUID, Date: "2019-03-11", Components: transaction1.Components},
+		{UUID: update.UUID, Date: update.Date, Components: []TransactionComponent{update.Components[0], {AccountUUID: testAccount2.UUID, Amount: 2000, Status: ComponentStatusReconciled}}},
+	} {
+		saveTransaction := rejected
+		err = dbService.UpdateTransaction(&testUser, &saveTransaction, false)
+		assert.ErrorIs(t, err, ErrReconciledComponent)
+	}
+
+	transaction, err := dbService.GetTransaction(&testUser, transaction1.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, transaction1, transaction)
+
+	update = *transaction1
+	update.Components = []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 9000}}
+	err = dbService.UpdateTransaction(&testUser, &update, true)
+	assert.NoError(t, err)
+
+	transaction, err = dbService.GetTransaction(&testUser, transaction1.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, &update, transaction)
+}
+
+func TestDeleteReconciledTransaction(t *testing.T) {
+	transaction1, _, transaction3 := createReconciliationTransactions(t)
+
+	_, err := dbService.FinishReconciliation(&testUser, testAccount1.UUID, "2019-03-31", 7500)
+	assert.NoError(t, err)
+
+	err = dbService.DeleteTransaction(&testUser, transaction1.UUID, false)
+	assert.ErrorIs(t, err, ErrReconciledComponent)
+	transaction, err := dbService.GetTransaction(&testUser, transaction1.UUID)
+	assert.NoError(t, err)
+	assert.NotNil(t, transaction)
+
+	// Transactions without reconciled components can be deleted.
+	err = dbService.DeleteTransaction(&testUser, transaction3.UUID, false)
+	assert.NoError(t, err)
+
+	err = dbService.DeleteTransaction(&testUser, transaction1.UUID, true)
+	assert.NoError(t, err)
+	transaction, err = dbService.GetTransaction(&testUser, transaction1.UUID)
+	assert.NoError(t, err)
+	assert.Nil(t, transaction)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-2500), accounts[0].Balance)
+}
+
+func TestCreateReconciledTransaction(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	transaction := &Transaction{
+		Description: "t1",
+		Date:        "2019-03-10",
+		Type:        TransactionTypeExpenseIncome,
+		Components: []TransactionComponent{
+			{AccountUUID: testAccount1.UUID, Amount: 10000, Status: ComponentStatusReconciled},
+			{AccountUUID: testAccount2.UUID, Amount: 500},
+		},
+	}
+	err = dbService.CreateTransaction(&testUser, transaction)
+	assert.NoError(t, err)
+
+	saved, err := dbService.GetTransaction(&testUser, transaction.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, ComponentStatusCleared, saved.Components[0].Status)
+	assert.Equal(t, ComponentStatusUncleared, saved.Components[1].Status)
+}
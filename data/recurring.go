@@ -0,0 +1,511 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RecurrenceDaily repeats a transaction every Interval days.
+	RecurrenceDaily = iota
+	// RecurrenceWeekly repeats a transaction every Interval weeks.
+	RecurrenceWeekly
+	// RecurrenceMonthly repeats a transaction every Interval months.
+	RecurrenceMonthly
+	// RecurrenceYearly repeats a transaction every Interval years.
+	RecurrenceYearly
+)
+
+// recurringNamespace is the namespace for generating UUIDs of recurring transaction occurrences.
+var recurringNamespace = uuid.MustParse("5d7f5c4e-2c1a-4d8e-9f1b-6a0e3b7c9d21")
+
+// RecurringTransaction is a template Transaction which is created according to a schedule.
+type RecurringTransaction struct {
+	UUID string
+	// Template is the transaction to create; its UUID and Date are ignored.
+	Template  Transaction
+	Frequency int
+	// Interval is the number of days, weeks, months or years between occurrences; 0 is the same as 1.
+	Interval  int
+	StartDate string
+	// DayOfMonth is the day of monthly and yearly occurrences: 0 uses the day of StartDate,
+	// negative values count from the end of the month (-1 is the last day).
+	// Days that don't exist in a month are moved to the last day of the month.
+	DayOfMonth int
+	// EndDate is the date of the last possible occurrence; an empty value means there is no end date.
+	EndDate string
+	// Count is the maximum number of occurrences; 0 means there is no limit.
+	Count  int
+	Paused bool
+	// Blocked is set while the template references an account which doesn't exist.
+	// Occurrences of a blocked recurring transaction are kept pending until the account exists again.
+	Blocked bool
+
+	// LastDate is the date of the last occurrence which was created or skipped.
+	LastDate string
+	// Occurrences is the number of occurrences which were created or skipped.
+	Occurrences int
+	// NextDate is the date of the next occurrence, or an empty string if there are no more occurrences.
+	NextDate string
+}
+
+// encode serializes a RecurringTransaction.
+func (recurring *RecurringTransaction) encode() ([]byte, error) {
+	var value bytes.Buffer
+	if err := gob.NewEncoder(&value).Encode(recurring); err != nil {
+		return nil, err
+	}
+	return value.Bytes(), nil
+}
+
+// decode deserializes a RecurringTransaction.
+func (recurring *RecurringTransaction) decode(val []byte) error {
+	return gob.NewDecoder(bytes.NewBuffer(val)).Decode(recurring)
+}
+
+// normalize validates the schedule and reformats its dates.
+func (recurring *RecurringTransaction) normalize() error {
+	switch recurring.Frequency {
+	case RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly, RecurrenceYearly:
+	default:
+		return fmt.Errorf("unsupported frequency %v", recurring.Frequency)
+	}
+	if recurring.Interval < 0 {
+		return fmt.Errorf("invalid interval %v", recurring.Interval)
+	}
+	if recurring.Count < 0 {
+		return fmt.Errorf("invalid count %v", recurring.Count)
+	}
+	if recurring.DayOfMonth < -31 || recurring.DayOfMonth > 31 {
+		return fmt.Errorf("invalid day of month %v", recurring.DayOfMonth)
+	}
+	for _, value := range []*string{&recurring.StartDate, &recurring.EndDate} {
+		if *value == "" {
+			continue
+		}
+		date, err := time.Parse(inputDateFormat, *value)
+		if err != nil {
+			return fmt.Errorf("cannot parse date %v: %w", *value, err)
+		}
+		*value = date.Format(dateFormat)
+	}
+	if recurring.StartDate == "" {
+		return fmt.Errorf("recurring transaction has no start date")
+	}
+
+	recurring.Template.UUID = ""
+	recurring.Template.Date = recurring.StartDate
+	clearReconciledComponents(&recurring.Template)
+	if err := recurring.Template.normalize(); err != nil {
+		return err
+	}
+	return recurring.updateNextDate()
+}
+
+// occurrenceDate returns the date of occurrence n, without checking the start and end dates.
+func (recurring *RecurringTransaction) occurrenceDate(start time.Time, n int) time.Time {
+	interval := recurring.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	var month time.Time
+	switch recurring.Frequency {
+	case RecurrenceDaily:
+		return start.AddDate(0, 0, n*interval)
+	case RecurrenceWeekly:
+		return start.AddDate(0, 0, 7*n*interval)
+	case RecurrenceMonthly:
+		month = time.Date(start.Year(), start.Month()+time.Month(n*interval), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		month = time.Date(start.Year()+n*interval, start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	lastDay := month.AddDate(0, 1, -1).Day()
+	day := recurring.DayOfMonth
+	if day == 0 {
+		day = start.Day()
+	} else if day < 0 {
+		day = lastDay + 1 + day
+	}
+	if day < 1 {
+		day = 1
+	} else if day > lastDay {
+		day = lastDay
+	}
+	return month.AddDate(0, 0, day-1)
+}
+
+// nextOccurrence returns the date of the first occurrence after LastDate,
+// or an empty string if there are no more occurrences.
+func (recurring *RecurringTransaction) nextOccurrence() (string, error) {
+	if recurring.Count > 0 && recurring.Occurrences >= recurring.Count {
+		return "", nil
+	}
+	start, err := time.Parse(dateFormat, recurring.StartDate)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse start date %v: %w", recurring.StartDate, err)
+	}
+	// Occurrence dates are always increasing, so this loop will end.
+	for n := 0; ; n++ {
+		date := recurring.occurrenceDate(start, n).Format(dateFormat)
+		if date < recurring.StartDate || date <= recurring.LastDate {
+			continue
+		}
+		if recurring.EndDate != "" && date > recurring.EndDate {
+			return "", nil
+		}
+		return date, nil
+	}
+}
+
+// updateNextDate sets NextDate to the date of the next occurrence.
+func (recurring *RecurringTransaction) updateNextDate() error {
+	nextDate, err := recurring.nextOccurrence()
+	if err != nil {
+		return err
+	}
+	recurring.NextDate = nextDate
+	return nil
+}
+
+// advance marks the next occurrence as created or skipped.
+func (recurring *RecurringTransaction) advance() error {
+	recurring.LastDate = recurring.NextDate
+	recurring.Occurrences++
+	return recurring.updateNextDate()
+}
+
+// occurrenceUUID returns the UUID of a transaction created for the occurrence on date.
+// The UUID is the same every time, so that an occurrence is never created twice.
+func (recurring *RecurringTransaction) occurrenceUUID(date string) string {
+	return uuid.NewSHA1(recurringNamespace, []byte(recurring.UUID+separator+date)).String()
+}
+
+// addRecurringUser adds user to the index of users who have recurring transactions.
+// The index is shared by all users, and is updated separately from the user's recurring transactions.
+func (s *DBService) addRecurringUser(user *User) error {
+	return s.update(nil, func(s *DBService) error {
+		if err := s.addReferencedKey([]byte(recurringUsersKey), []byte(user.UUID), false); err != nil {
+			return fmt.Errorf("cannot add user to recurring transactions index: %w", err)
+		}
+		return nil
+	})
+}
+
+// saveRecurringTransaction saves recurring and adds it to the index.
+func (s *DBService) saveRecurringTransaction(user *User, recurring *RecurringTransaction) error {
+	value, err := recurring.encode()
+	if err != nil {
+		return fmt.Errorf("cannot encode recurring transaction: %w", err)
+	}
+	if err := s.addReferencedKey([]byte(user.createRecurringKeyPrefix()), []byte(recurring.UUID), false); err != nil {
+		return fmt.Errorf("cannot add recurring transaction to index: %w", err)
+	}
+	return s.db.Put(user.createRecurringKey(recurring), value)
+}
+
+// getRecurringTransaction returns a RecurringTransaction by its UUID.
+// If the RecurringTransaction doesn't exist, it returns nil.
+func (s *DBService) getRecurringTransaction(user *User, recurringUUID string) (*RecurringTransaction, error) {
+	key := user.createRecurringKeyFromUUID(recurringUUID)
+
+	value, err := s.db.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recurring transaction %v: %w", string(key), err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	recurring := &RecurringTransaction{}
+	if err := recurring.decode(value); err != nil {
+		return nil, fmt.Errorf("failed to read value for recurring transaction %v: %w", string(key), err)
+	}
+	return recurring, nil
+}
+
+// getRecurringTransactions returns all recurring transactions for user.
+func (s *DBService) getRecurringTransactions(user *User) ([]*RecurringTransaction, error) {
+	recurringUUIDs, err := s.getReferencedKeys([]byte(user.createRecurringKeyPrefix()))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get recurring transaction UUIDs for user: %w", err)
+	}
+
+	recurringTransactions := make([]*RecurringTransaction, 0, len(recurringUUIDs))
+	for _, recurringUUID := range recurringUUIDs {
+		recurring, err := s.getRecurringTransaction(user, string(recurringUUID))
+		if err != nil {
+			return nil, err
+		}
+		if recurring == nil {
+			continue
+		}
+		recurringTransactions = append(recurringTransactions, recurring)
+	}
+	return recurringTransactions, nil
+}
+
+// CreateRecurringTransaction validates and saves a new RecurringTransaction.
+// It generates and sets the ID for the new recurring transaction.
+func (s *DBService) CreateRecurringTransaction(user *User, recurring *RecurringTransaction) error {
+	recurring.UUID = uuid.NewString()
+	recurring.LastDate = ""
+	recurring.Occurrences = 0
+	recurring.Blocked = false
+	if err := recurring.normalize(); err != nil {
+		return err
+	}
+	if err := s.addRecurringUser(user); err != nil {
+		return err
+	}
+
+	return s.update(user, func(s *DBService) error {
+		return s.saveRecurringTransaction(user, recurring)
+	})
+}
+
+// UpdateRecurringTransaction saves an already existing RecurringTransaction.
+// Occurrences which were already created or skipped are kept, even if the schedule is changed.
+// If the recurring transaction doesn't exist, it returns an error.
+func (s *DBService) UpdateRecurringTransaction(user *User, recurring *RecurringTransaction) error {
+	return s.update(user, func(s *DBService) error {
+		previous, err := s.getRecurringTransaction(user, recurring.UUID)
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			return fmt.Errorf("cannot update recurring transaction %v if it doesn't exist", recurring.UUID)
+		}
+
+		recurring.LastDate = previous.LastDate
+		recurring.Occurrences = previous.Occurrences
+		recurring.Blocked = previous.Blocked
+		if err := recurring.normalize(); err != nil {
+			return err
+		}
+		return s.saveRecurringTransaction(user, recurring)
+	})
+}
+
+// GetRecurringTransaction returns a RecurringTransaction by its UUID.
+// If the RecurringTransaction doesn't exist, it returns nil.
+func (s *DBService) GetRecurringTransaction(user *User, recurringUUID string) (*RecurringTransaction, error) {
+	var recurring *RecurringTransaction
+	err := s.view(user, func() error {
+		var err error
+		recurring, err = s.getRecurringTransaction(user, recurringUUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recurring, nil
+}
+
+// GetRecurringTransactions returns all recurring transactions for user.
+func (s *DBService) GetRecurringTransactions(user *User) ([]*RecurringTransaction, error) {
+	var recurringTransactions []*RecurringTransaction
+	err := s.view(user, func() error {
+		var err error
+		recurringTransactions, err = s.getRecurringTransactions(user)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recurring transactions: %w", err)
+	}
+	return recurringTransactions, nil
+}
+
+// DeleteRecurringTransaction deletes a RecurringTransaction by its UUID.
+// Transactions which were already created are not deleted.
+// If the recurring transaction doesn't exist, it returns an error.
+func (s *DBService) DeleteRecurringTransaction(user *User, recurringUUID string) error {
+	key := user.createRecurringKeyFromUUID(recurringUUID)
+	return s.update(user, func(s *DBService) error {
+		exists, err := s.db.Has(key)
+		if err != nil {
+			return fmt.Errorf("cannot check if recurring transaction exists %v: %w", recurringUUID, err)
+		} else if !exists {
+			return fmt.Errorf("cannot delete recurring transaction %v because it doesn't exist", recurringUUID)
+		}
+
+		if err := s.db.Delete(key); err != nil {
+			return fmt.Errorf("cannot delete recurring transaction %v: %w", recurringUUID, err)
+		}
+
+		return s.deleteReferencedKey([]byte(user.createRecurringKeyPrefix()), []byte(recurringUUID))
+	})
+}
+
+// modifyRecurringTransaction applies modifyFn to an existing RecurringTransaction and saves it.
+func (s *DBService) modifyRecurringTransaction(user *User, recurringUUID string, modifyFn func(*RecurringTransaction) error) (*RecurringTransaction, error) {
+	var recurring *RecurringTransaction
+	err := s.update(user, func(s *DBService) error {
+		var err error
+		recurring, err = s.getRecurringTransaction(user, recurringUUID)
+		if err != nil {
+			return err
+		}
+		if recurring == nil {
+			return fmt.Errorf("recurring transaction %v doesn't exist", recurringUUID)
+		}
+		if err := modifyFn(recurring); err != nil {
+			return err
+		}
+		return s.saveRecurringTransaction(user, recurring)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recurring, nil
+}
+
+// SkipRecurringTransaction skips the next occurrence of a RecurringTransaction without creating a transaction.
+func (s *DBService) SkipRecurringTransaction(user *User, recurringUUID string) (*RecurringTransaction, error) {
+	return s.modifyRecurringTransaction(user, recurringUUID, func(recurring *RecurringTransaction) error {
+		if recurring.NextDate == "" {
+			return fmt.Errorf("recurring transaction %v has no more occurrences", recurringUUID)
+		}
+		return recurring.advance()
+	})
+}
+
+// PauseRecurringTransaction pauses or resumes a RecurringTransaction.
+// Occurrences which are due while a recurring transaction is paused are skipped.
+func (s *DBService) PauseRecurringTransaction(user *User, recurringUUID string, paused bool) (*RecurringTransaction, error) {
+	return s.modifyRecurringTransaction(user, recurringUUID, func(recurring *RecurringTransaction) error {
+		recurring.Paused = paused
+		return nil
+	})
+}
+
+// getMissingTemplateAccount returns the UUID of an account referenced by the template of recurring which doesn't exist,
+// or an empty string if all accounts exist.
+func (s *DBService) getMissingTemplateAccount(user *User, recurring *RecurringTransaction) (string, error) {
+	for _, component := range recurring.Template.Components {
+		exists, err := s.db.Has(user.createAccountKeyFromUUID(component.AccountUUID))
+		if err != nil {
+			return "", fmt.Errorf("cannot check if account %v exists: %w", component.AccountUUID, err)
+		}
+		if !exists {
+			return component.AccountUUID, nil
+		}
+	}
+	return "", nil
+}
+
+// materializeRecurringTransaction creates all occurrences of recurring which are due on or before today.
+// If the template references an account which doesn't exist, recurring is blocked instead,
+// and its occurrences are created once the account exists.
+// Returns the number of created transactions.
+// This method should be called from an update transaction.
+func (s *DBService) materializeRecurringTransaction(user *User, recurring *RecurringTransaction, today string) (int, error) {
+	if err := recurring.updateNextDate(); err != nil {
+		return 0, err
+	}
+	if !recurring.Paused && recurring.NextDate != "" && recurring.NextDate <= today {
+		missingAccountUUID, err := s.getMissingTemplateAccount(user, recurring)
+		if err != nil {
+			return 0, err
+		}
+		if missingAccountUUID != "" {
+			if !recurring.Blocked {
+				log.WithField("user", user.UUID).WithField("recurring", recurring.UUID).WithField("account", missingAccountUUID).
+					Warn("Blocking recurring transaction which references a missing account")
+			}
+			recurring.Blocked = true
+			return 0, s.saveRecurringTransaction(user, recurring)
+		}
+		recurring.Blocked = false
+	}
+
+	created := 0
+	for recurring.NextDate != "" && recurring.NextDate <= today {
+		if !recurring.Paused {
+			transaction := recurring.Template
+			transaction.UUID = recurring.occurrenceUUID(recurring.NextDate)
+			transaction.Date = recurring.NextDate
+			transaction.Tags = append([]string(nil), recurring.Template.Tags...)
+			transaction.Components = append([]TransactionComponent(nil), recurring.Template.Components...)
+
+			exists, err := s.db.Has(user.createTransactionKey(&transaction))
+			if err != nil {
+				return created, fmt.Errorf("cannot check if transaction %v exists: %w", transaction.UUID, err)
+			}
+			// The transaction could have been created before a crash.
+			if !exists {
+				if err := s.createTransaction(user, &transaction); err != nil {
+					return created, fmt.Errorf("failed to create transaction %v: %w", transaction.UUID, err)
+				}
+				created++
+			}
+		}
+		if err := recurring.advance(); err != nil {
+			return created, err
+		}
+	}
+	return created, s.saveRecurringTransaction(user, recurring)
+}
+
+// MaterializeRecurringTransactions creates transactions for all occurrences of recurring transactions
+// which are due on or before today, for all users.
+// Every recurring transaction is processed in a separate update, so that a failure doesn't block other
+// recurring transactions; failures are logged, and an error is returned after all users were processed.
+// Returns the number of created transactions.
+func (s *DBService) MaterializeRecurringTransactions(today time.Time) (int, error) {
+	todayDate := today.Format(dateFormat)
+	var userUUIDs [][]byte
+	err := s.view(nil, func() error {
+		var err error
+		userUUIDs, err = s.getReferencedKeys([]byte(recurringUsersKey))
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get users with recurring transactions: %w", err)
+	}
+
+	created, failed := 0, 0
+	for _, userUUID := range userUUIDs {
+		user := &User{UUID: string(userUUID)}
+		var recurringUUIDs [][]byte
+		err := s.view(user, func() error {
+			var err error
+			recurringUUIDs, err = s.getReferencedKeys([]byte(user.createRecurringKeyPrefix()))
+			return err
+		})
+		if err != nil {
+			log.WithField("user", user.UUID).WithError(err).Error("Failed to get recurring transactions")
+			failed++
+			continue
+		}
+
+		for _, recurringUUID := range recurringUUIDs {
+			var count int
+			err := s.update(user, func(s *DBService) error {
+				recurring, err := s.getRecurringTransaction(user, string(recurringUUID))
+				if err != nil || recurring == nil {
+					return err
+				}
+				count, err = s.materializeRecurringTransaction(user, recurring, todayDate)
+				return err
+			})
+			if err != nil {
+				log.WithField("user", user.UUID).WithField("recurring", string(recurringUUID)).WithError(err).
+					Error("Failed to create recurring transactions")
+				failed++
+				continue
+			}
+			created += count
+		}
+	}
+	if failed > 0 {
+		return created, fmt.Errorf("failed to create occurrences of %v recurring transactions", failed)
+	}
+	return created, nil
+}
@@ -0,0 +1,309 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestRecurringTransaction() RecurringTransaction {
+	return RecurringTransaction{
+		Template: Transaction{
+			Description: "Rent",
+			Type:        TransactionTypeExpenseIncome,
+			Tags:        []string{"Home"},
+			Components: []TransactionComponent{
+				{Amount: -100000, AccountUUID: testAccount1.UUID},
+			},
+		},
+		Frequency:  RecurrenceMonthly,
+		StartDate:  "2015-1-31",
+		DayOfMonth: 0,
+	}
+}
+
+func occurrenceDates(t *testing.T, recurring RecurringTransaction, n int) []string {
+	assert.NoError(t, recurring.normalize())
+	dates := make([]string, 0, n)
+	for i := 0; i < n && recurring.NextDate != ""; i++ {
+		dates = append(dates, recurring.NextDate)
+		assert.NoError(t, recurring.advance())
+	}
+	return dates
+}
+
+func TestRecurringTransactionOccurrences(t *testing.T) {
+	recurring := createTestRecurringTransaction()
+	assert.Equal(t, []string{"2015-01-31", "2015-02-28", "2015-03-31", "2015-04-30"}, occurrenceDates(t, recurring, 4))
+
+	recurring.DayOfMonth = -2
+	assert.Equal(t, []string{"2015-02-27", "2015-03-30", "2015-04-29"}, occurrenceDates(t, recurring, 3))
+
+	recurring.DayOfMonth = 15
+	recurring.Interval = 2
+	assert.Equal(t, []string{"2015-03-15", "2015-05-15", "2015-07-15"}, occurrenceDates(t, recurring, 3))
+
+	recurring = createTestRecurringTransaction()
+	recurring.Frequency = RecurrenceDaily
+	recurring.Interval = 3
+	recurring.EndDate = "2015-2-6"
+	assert.Equal(t, []string{"2015-01-31", "2015-02-03", "2015-02-06"}, occurrenceDates(t, recurring, 10))
+
+	recurring = createTestRecurringTransaction()
+	recurring.Frequency = RecurrenceWeekly
+	recurring.Count = 2
+	assert.Equal(t, []string{"2015-01-31", "2015-02-07"}, occurrenceDates(t, recurring, 10))
+
+	recurring = createTestRecurringTransaction()
+	recurring.Frequency = RecurrenceYearly
+	recurring.StartDate = "2016-2-29"
+	assert.Equal(t, []string{"2016-02-29", "2017-02-28", "2018-02-28"}, occurrenceDates(t, recurring, 3))
+}
+
+func TestCreateInvalidRecurringTransaction(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	recurring := createTestRecurringTransaction()
+	recurring.Frequency = 10
+	err = dbService.CreateRecurringTransaction(&testUser, &recurring)
+	assert.Error(t, err)
+
+	recurring = createTestRecurringTransaction()
+	recurring.StartDate = ""
+	err = dbService.CreateRecurringTransaction(&testUser, &recurring)
+	assert.Error(t, err)
+
+	recurring = createTestRecurringTransaction()
+	recurring.EndDate = "2015-13-01"
+	err = dbService.CreateRecurringTransaction(&testUser, &recurring)
+	assert.Error(t, err)
+
+	recurringTransactions, err := dbService.GetRecurringTransactions(&testUser)
+	assert.NoError(t, err)
+	assert.Empty(t, recurringTransactions)
+}
+
+func TestCreateUpdateDeleteRecurringTransaction(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	recurring := createTestRecurringTransaction()
+	err = dbService.CreateRecurringTransaction(&testUser, &recurring)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, recurring.UUID)
+	assert.Equal(t, "2015-01-31", recurring.StartDate)
+	assert.Equal(t, "2015-01-31", recurring.NextDate)
+
+	dbRecurring, err := dbService.GetRecurringTransaction(&testUser, recurring.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, &recurring, dbRecurring)
+
+	dbRecurring, err = dbService.SkipRecurringTransaction(&testUser, recurring.UUID)
+	assert.NoError(t, err)
+	recurring = *dbRecurring
+	assert.Equal(t, "2015-01-31", recurring.LastDate)
+	assert.Equal(t, 1, recurring.Occurrences)
+	assert.Equal(t, "2015-02-28", recurring.NextDate)
+
+	updateRecurring := recurring
+	updateRecurring.DayOfMonth = 10
+	updateRecurring.LastDate = ""
+	updateRecurring.Occurrences = 0
+	err = dbService.UpdateRecurringTransaction(&testUser, &updateRecurring)
+	assert.NoError(t, err)
+	assert.Equal(t, "2015-01-31", updateRecurring.LastDate)
+	assert.Equal(t, 1, updateRecurring.Occurrences)
+	assert.Equal(t, "2015-02-10", updateRecurring.NextDate)
+
+	recurringTransactions, err := dbService.GetRecurringTransactions(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []*RecurringTransaction{&updateRecurring}, recurringTransactions)
+
+	missingRecurring := createTestRecurringTransaction()
+	missingRecurring.UUID = "missing"
+	err = dbService.UpdateRecurringTransaction(&testUser, &missingRecurring)
+	assert.Error(t, err)
+
+	err = dbService.DeleteRecurringTransaction(&testUser, recurring.UUID)
+	assert.NoError(t, err)
+	err = dbService.DeleteRecurringTransaction(&testUser, recurring.UUID)
+	assert.Error(t, err)
+
+	recurringTransactions, err = dbService.GetRecurringTransactions(&testUser)
+	assert.NoError(t, err)
+	assert.Empty(t, recurringTransactions)
+}
+
+func TestMaterializeRecurringTransactions(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	recurring := createTestRecurringTransaction()
+	err = dbService.CreateRecurringTransaction(&testUser, &recurring)
+	assert.NoError(t, err)
+
+	today := time.Date(2015, time.April, 1, 12, 0, 0, 0, time.UTC)
+	created, err := dbService.MaterializeRecurringTransactions(today)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, created)
+
+	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 3)
+	for i, date := range []string{"2015-03-31", "2015-02-28", "2015-01-31"} {
+		assert.Equal(t, date, transactions[i].Date)
+		assert.Equal(t, "Rent", transactions[i].Description)
+		assert.Equal(t, []string{"Home"}, transactions[i].Tags)
+		assert.Equal(t, recurring.occurrenceUUID(date), transactions[i].UUID)
+	}
+
+	account, err := dbService.GetAccount(&testUser, testAccount1.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-300000), account.Balance)
+
+	dbRecurring, err := dbService.GetRecurringTransaction(&testUser, recurring.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "2015-03-31", dbRecurring.LastDate)
+	assert.Equal(t, 3, dbRecurring.Occurrences)
+	assert.Equal(t, "2015-04-30", dbRecurring.NextDate)
+
+	// Running again shouldn't create anything.
+	created, err = dbService.MaterializeRecurringTransactions(today)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), count)
+}
+
+func TestMaterializeRecurringTransactionsAfterCrash(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	recurring := createTestRecurringTransaction()
+	err = dbService.CreateRecurringTransaction(&testUser, &recurring)
+	assert.NoError(t, err)
+
+	// Simulate a crash after the first occurrence was created, but before the schedule was saved.
+	transaction := recurring.Template
+	transaction.UUID = recurring.occurrenceUUID("2015-01-31")
+	transaction.Date = "2015-01-31"
+	err = dbService.update(&testUser, func(s *DBService) error {
+		return dbService.createTransaction(&testUser, &transaction)
+	})
+	assert.NoError(t, err)
+
+	created, err := dbService.MaterializeRecurringTransactions(time.Date(2015, time.February, 28, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), count)
+
+	account, err := dbService.GetAccount(&testUser, testAccount1.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-200000), account.Balance)
+}
+
+func TestMaterializePausedRecurringTransaction(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	recurring := createTestRecurringTransaction()
+	err = dbService.CreateRecurringTransaction(&testUser, &recurring)
+	assert.NoError(t, err)
+
+	dbRecurring, err := dbService.PauseRecurringTransaction(&testUser, recurring.UUID, true)
+	assert.NoError(t, err)
+	assert.True(t, dbRecurring.Paused)
+
+	created, err := dbService.MaterializeRecurringTransactions(time.Date(2015, time.February, 28, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	dbRecurring, err = dbService.PauseRecurringTransaction(&testUser, recurring.UUID, false)
+	assert.NoError(t, err)
+	assert.False(t, dbRecurring.Paused)
+	assert.Equal(t, "2015-03-31", dbRecurring.NextDate)
+
+	created, err = dbService.MaterializeRecurringTransactions(time.Date(2015, time.March, 31, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+
+	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, "2015-03-31", transactions[0].Date)
+}
+
+func TestMaterializeRecurringTransactionMissingAccount(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	missingRecurring := createTestRecurringTransaction()
+	missingRecurring.Template.Components[0].AccountUUID = "missing"
+	err = dbService.CreateRecurringTransaction(&testUser, &missingRecurring)
+	assert.NoError(t, err)
+
+	recurring := createTestRecurringTransaction()
+	err = dbService.CreateRecurringTransaction(&testUser, &recurring)
+	assert.NoError(t, err)
+
+	// A template with a missing account shouldn't block other recurring transactions.
+	created, err := dbService.MaterializeRecurringTransactions(time.Date(2015, time.February, 28, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, created)
+
+	dbRecurring, err := dbService.GetRecurringTransaction(&testUser, missingRecurring.UUID)
+	assert.NoError(t, err)
+	assert.False(t, dbRecurring.Paused)
+	assert.True(t, dbRecurring.Blocked)
+	assert.Equal(t, "", dbRecurring.LastDate)
+	assert.Equal(t, "2015-01-31", dbRecurring.NextDate)
+
+	dbRecurring, err = dbService.GetRecurringTransaction(&testUser, recurring.UUID)
+	assert.NoError(t, err)
+	assert.False(t, dbRecurring.Paused)
+	assert.Equal(t, "2015-02-28", dbRecurring.LastDate)
+
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), count)
+
+	// Blocked occurrences are not skipped by later runs.
+	created, err = dbService.MaterializeRecurringTransactions(time.Date(2015, time.March, 31, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	dbRecurring, err = dbService.GetRecurringTransaction(&testUser, missingRecurring.UUID)
+	assert.NoError(t, err)
+	assert.True(t, dbRecurring.Blocked)
+	assert.Equal(t, "2015-01-31", dbRecurring.NextDate)
+
+	// Once the template is fixed, all pending occurrences are created.
+	dbRecurring.Template.Components[0].AccountUUID = testAccount1.UUID
+	err = dbService.UpdateRecurringTransaction(&testUser, dbRecurring)
+	assert.NoError(t, err)
+	created, err = dbService.MaterializeRecurringTransactions(time.Date(2015, time.March, 31, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, created)
+	dbRecurring, err = dbService.GetRecurringTransaction(&testUser, missingRecurring.UUID)
+	assert.NoError(t, err)
+	assert.False(t, dbRecurring.Blocked)
+	assert.Equal(t, "2015-03-31", dbRecurring.LastDate)
+}
@@ -0,0 +1,414 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// gzipMagic is the header of gzip-compressed data.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// restoreChunkSize is the approximate size of changes, including deleted items and index updates,
+// which are kept in memory before a restore saves them.
+var restoreChunkSize = 16 * 1024 * 1024
+
+// RestoreMode specifies how a restore handles existing data.
+type RestoreMode int
+
+const (
+	// RestoreReplace deletes all existing data before restoring a backup.
+	RestoreReplace RestoreMode = iota
+	// RestoreMerge keeps existing data and adds records from a backup, matching them by UUID.
+	RestoreMerge
+)
+
+// ParseRestoreMode returns the RestoreMode with the specified name (replace or merge).
+// An empty name is the same as replace.
+func ParseRestoreMode(name string) (RestoreMode, error) {
+	switch name {
+	case "", "replace":
+		return RestoreReplace, nil
+	case "merge":
+		return RestoreMerge, nil
+	default:
+		return RestoreReplace, fmt.Errorf("unsupported restore mode %v", name)
+	}
+}
+
+// RestoreConflict specifies which version is kept when a merged record exists and was changed.
+type RestoreConflict int
+
+const (
+	// RestoreKeepExisting keeps the existing record.
+	RestoreKeepExisting RestoreConflict = iota
+	// RestoreUseBackup replaces the existing record with the one from the backup.
+	RestoreUseBackup
+)
+
+// ParseRestoreConflict returns the RestoreConflict with the specified name (existing or backup).
+// An empty name is the same as existing.
+func ParseRestoreConflict(name string) (RestoreConflict, error) {
+	switch name {
+	case "", "existing":
+		return RestoreKeepExisting, nil
+	case "backup":
+		return RestoreUseBackup, nil
+	default:
+		return RestoreKeepExisting, fmt.Errorf("unsupported restore conflict resolution %v", name)
+	}
+}
+
+// RestoreOptions specifies options for restoring a backup.
+type RestoreOptions struct {
+	Mode RestoreMode
+	// Conflicts specifies how records that exist with different values are merged.
+	Conflicts RestoreConflict
+	// DryRun validates the backup and returns the changes without saving them.
+	DryRun bool
+	// Passphrase is used to decrypt encrypted backups.
+	Passphrase string
+}
+
+// RestoreResult contains the number of accounts and transactions processed by a restore.
+type RestoreResult struct {
+	Inserted int
+	Updated  int
+	// Skipped is the number of records which already existed and were kept unchanged.
+	Skipped int
+	// Diff contains the changes made by a dry run, or nil if this wasn't a dry run.
+	Diff *RestoreDiff `json:",omitempty"`
+}
+
+// openBackup returns a reader for the decrypted and uncompressed contents of r.
+// Encrypted and compressed backups are detected automatically.
+func openBackup(r io.Reader, passphrase string) (io.Reader, error) {
+	bufferedReader := bufio.NewReader(r)
+	encrypted, err := isEncryptedBackup(bufferedReader)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return decompressBackup(bufferedReader)
+	}
+	decryptedReader, err := newEncryptedBackupReader(bufferedReader, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return decompressBackup(decryptedReader)
+}
+
+// decompressBackup returns a reader for the uncompressed contents of r.
+// Compressed backups are detected automatically.
+func decompressBackup(r io.Reader) (io.Reader, error) {
+	bufferedReader, ok := r.(*bufio.Reader)
+	if !ok {
+		bufferedReader = bufio.NewReader(r)
+	}
+	header, err := bufferedReader.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read backup header: %w", err)
+	}
+	if !bytes.Equal(header, gzipMagic) {
+		return bufferedReader, nil
+	}
+	gzipReader, err := gzip.NewReader(bufferedReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress backup: %w", err)
+	}
+	return gzipReader, nil
+}
+
+// readBackup reads a serialized backup from r, calling accountFn for every account
+// and transactionFn for every transaction, in the order they appear in the backup.
+func readBackup(r io.Reader, accountFn func(*Account) error, transactionFn func(*Transaction) error) error {
+	decoder := json.NewDecoder(r)
+
+	expectDelim := func(delim json.Delim) (bool, error) {
+		token, err := decoder.Token()
+		if err != nil {
+			return false, fmt.Errorf("error unmarshaling json: %w", err)
+		}
+		if token == nil {
+			return false, nil
+		}
+		if token != delim {
+			return false, fmt.Errorf("error unmarshaling json: unexpected token %v, expected %v", token, delim)
+		}
+		return true, nil
+	}
+	readList := func(decodeFn func() error) error {
+		if ok, err := expectDelim('['); err != nil || !ok {
+			return err
+		}
+		for decoder.More() {
+			if err := decodeFn(); err != nil {
+				return err
+			}
+		}
+		_, err := expectDelim(']')
+		return err
+	}
+
+	if ok, err := expectDelim('{'); err != nil || !ok {
+		return err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("error unmarshaling json: %w", err)
+		}
+		switch token {
+		case "Accounts":
+			err = readList(func() error {
+				account := &Account{}
+				if err := decoder.Decode(account); err != nil {
+					return fmt.Errorf("error unmarshaling account: %w", err)
+				}
+				return accountFn(account)
+			})
+		case "Transactions":
+			err = readList(func() error {
+				transaction := &Transaction{}
+				if err := decoder.Decode(transaction); err != nil {
+					return fmt.Errorf("error unmarshaling transaction: %w", err)
+				}
+				return transactionFn(transaction)
+			})
+		default:
+			// Skip unknown fields.
+			var value json.RawMessage
+			err = decoder.Decode(&value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := expectDelim('}')
+	return err
+}
+
+// sameEncodedValues returns true if a and b are encoded into the same value.
+func sameEncodedValues(a, b interface{ encode() ([]byte, error) }) (bool, error) {
+	aValue, err := a.encode()
+	if err != nil {
+		return false, err
+	}
+	bValue, err := b.encode()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aValue, bValue), nil
+}
+
+// restoreAccount creates account if it doesn't exist, or merges it into the existing account.
+func (s *DBService) restoreAccount(user *User, account *Account, options RestoreOptions, result *RestoreResult) error {
+	previousAccount, err := s.getAccount(user, account.UUID)
+	if err != nil {
+		return err
+	}
+	if previousAccount == nil {
+		account.Balance = 0
+
+		if err := s.createAccount(user, account); err != nil {
+			return fmt.Errorf("failed to create account %v: %w", account, err)
+		}
+		result.Inserted++
+		return nil
+	}
+
+	account.Balance = previousAccount.Balance
+	same, err := sameEncodedValues(previousAccount, account)
+	if err != nil {
+		return fmt.Errorf("cannot encode account: %w", err)
+	}
+	if same || options.Conflicts == RestoreKeepExisting {
+		result.Skipped++
+		return nil
+	}
+
+	value, err := account.encode()
+	if err != nil {
+		return fmt.Errorf("cannot encode account: %w", err)
+	}
+	if err := s.db.Put(user.createAccountKey(account), value); err != nil {
+		return fmt.Errorf("failed to update account %v: %w", account, err)
+	}
+	result.Updated++
+	return nil
+}
+
+// restoreTransaction creates transaction if it doesn't exist, or merges it into the existing transaction.
+func (s *DBService) restoreTransaction(user *User, transaction *Transaction, options RestoreOptions, result *RestoreResult) error {
+	if err := transaction.normalize(); err != nil {
+		return fmt.Errorf("invalid transaction %v: %w", transaction.UUID, err)
+	}
+
+	previousTransaction, err := s.getTransaction(user, transaction.UUID)
+	if err != nil {
+		return err
+	}
+	if previousTransaction == nil {
+		if err := s.createTransaction(user, transaction); err != nil {
+			return fmt.Errorf("failed to create transaction %v: %w", transaction, err)
+		}
+		result.Inserted++
+		return nil
+	}
+
+	same, err := sameEncodedValues(previousTransaction, transaction)
+	if err != nil {
+		return fmt.Errorf("cannot encode transaction: %w", err)
+	}
+	if same || options.Conflicts == RestoreKeepExisting {
+		result.Skipped++
+		return nil
+	}
+
+	if err := s.updateTransaction(user, previousTransaction, transaction); err != nil {
+		return fmt.Errorf("failed to update transaction %v: %w", transaction, err)
+	}
+	result.Updated++
+	return nil
+}
+
+// recalculateAccountBalances sets the balance of all accounts to the sum of their transaction components.
+func (s *DBService) recalculateAccountBalances(user *User) error {
+	accounts, err := s.getAccounts(user)
+	if err != nil {
+		return fmt.Errorf("failed to get accounts: %w", err)
+	}
+	balances, err := s.getAccountBalances(user, TransactionFilterOptions{})
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if account.Balance == balances[account.UUID] {
+			continue
+		}
+		account.Balance = balances[account.UUID]
+		value, err := account.encode()
+		if err != nil {
+			return fmt.Errorf("cannot encode account: %w", err)
+		}
+		if err := s.db.Put(user.createAccountKey(account), value); err != nil {
+			return fmt.Errorf("cannot save account %v: %w", account.UUID, err)
+		}
+	}
+	return nil
+}
+
+// Restore restores the backup read from r into the data of user.
+// In RestoreReplace mode, all existing data for user is deleted first;
+// in RestoreMerge mode, records from the backup are matched with existing records by UUID,
+// and account balances are recalculated after the merge.
+// A dry run restores the backup without saving the changes, and returns the changes and problems found in the backup;
+// transactions which cannot be restored are skipped instead of failing the dry run.
+// Accounts and transactions are restored one at a time, as they are read; accounts have to be listed before transactions.
+// To restore backups of any size, changes are saved in chunks of about restoreChunkSize bytes, each of them atomically.
+// If a restore fails after a chunk was saved, the data is partially restored; restoring the same backup again
+// completes the restore, as records are matched by UUID.
+// A dry run keeps all changes in memory, as they are discarded when it completes.
+// Encrypted and compressed backups are detected automatically;
+// restoring an encrypted backup returns ErrBackupPassphraseRequired, ErrBackupWrongPassphrase or ErrBackupCorrupted
+// if it cannot be decrypted.
+func (s *DBService) Restore(user *User, r io.Reader, options RestoreOptions) (*RestoreResult, error) {
+	r, err := openBackup(r, options.Passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	result := &RestoreResult{}
+	partial := false
+	err = s.update(user, func(s *DBService) error {
+		var snapshot *restoreSnapshot
+		problems := make([]string, 0)
+		if options.DryRun {
+			var err error
+			snapshot, err = s.getRestoreSnapshot(user)
+			if err != nil {
+				return err
+			}
+		}
+
+		if options.Mode == RestoreReplace {
+			// Delete previous values.
+			if err := s.deleteAccounts(user); err != nil {
+				return fmt.Errorf("failed to cleanup previous accounts: %w", err)
+			}
+			if err := s.deleteTransactions(user); err != nil {
+				return fmt.Errorf("failed to cleanup previous transactions: %w", err)
+			}
+		}
+
+		checkSize := func() error {
+			if options.DryRun || s.pendingSize() < restoreChunkSize {
+				return nil
+			}
+			partial = true
+			return s.commitPending()
+		}
+		if err := checkSize(); err != nil {
+			return err
+		}
+
+		transactionsRestored := false
+		accountFn := func(account *Account) error {
+			if transactionsRestored {
+				return fmt.Errorf("account %v is listed after transactions", account.UUID)
+			}
+			if err := s.restoreAccount(user, account, options, result); err != nil {
+				return err
+			}
+			return checkSize()
+		}
+		transactionFn := func(transaction *Transaction) error {
+			transactionsRestored = true
+			if options.DryRun {
+				transactionProblems, skip, err := s.validateBackupTransaction(user, transaction)
+				if err != nil {
+					return err
+				}
+				problems = append(problems, transactionProblems...)
+				if skip {
+					return nil
+				}
+			}
+			if err := s.restoreTransaction(user, transaction, options, result); err != nil {
+				return err
+			}
+			return checkSize()
+		}
+		if err := readBackup(r, accountFn, transactionFn); err != nil {
+			return err
+		}
+
+		if options.Mode == RestoreMerge {
+			if err := s.recalculateAccountBalances(user); err != nil {
+				return err
+			}
+		}
+
+		if options.DryRun {
+			var err error
+			result.Diff, err = s.diffRestore(user, snapshot, problems)
+			if err != nil {
+				return err
+			}
+			return errRestoreDryRun
+		}
+		return nil
+	})
+	if options.DryRun && errors.Is(err, errRestoreDryRun) {
+		return result, nil
+	} else if err != nil && partial {
+		return nil, fmt.Errorf("failed to restore backup, data was partially restored and the backup should be restored again: %w", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	return result, nil
+}
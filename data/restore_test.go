@@ -0,0 +1,197 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMergeData = `{
+  "Accounts": [
+    {"UUID": "uuid1", "Name": "Orange Bank", "Currency": "PLN", "IncludeInTotal": true, "ShowInList": true},
+    {"UUID": "uuid5", "Name": "Blue Bank", "Currency": "PLN"}
+  ],
+  "Transactions": [
+    {"UUID": "uuid1", "Description": "Widgets", "Type": 0, "Tags": ["Widgets"], "Date": "2015-11-02",
+      "Components": [{"Amount": -10000, "AccountUUID": "uuid2"}]},
+    {"UUID": "uuid6", "Description": "Deposit", "Type": 0, "Date": "2015-11-10",
+      "Components": [{"Amount": 5000, "AccountUUID": "uuid5"}]}
+  ]
+}`
+
+func TestParseRestoreOptions(t *testing.T) {
+	mode, err := ParseRestoreMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, RestoreReplace, mode)
+	mode, err = ParseRestoreMode("merge")
+	assert.NoError(t, err)
+	assert.Equal(t, RestoreMerge, mode)
+	_, err = ParseRestoreMode("append")
+	assert.Error(t, err)
+
+	conflicts, err := ParseRestoreConflict("")
+	assert.NoError(t, err)
+	assert.Equal(t, RestoreKeepExisting, conflicts)
+	conflicts, err = ParseRestoreConflict("backup")
+	assert.NoError(t, err)
+	assert.Equal(t, RestoreUseBackup, conflicts)
+	_, err = ParseRestoreConflict("newest")
+	assert.Error(t, err)
+}
+
+// prepareMergeData restores the test backup and changes some of the restored data.
+func prepareMergeData(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	account, err := dbService.GetAccount(&testUser, "uuid1")
+	assert.NoError(t, err)
+	account.Name = "Local Bank"
+	err = dbService.UpdateAccount(&testUser, account)
+	assert.NoError(t, err)
+
+	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
+	assert.NoError(t, err)
+	for _, transaction := range transactions {
+		if transaction.UUID == "uuid1" {
+			transaction.Components[0].Amount = -20000
+			err = dbService.UpdateTransaction(&testUser, transaction, false)
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestRestoreMergeKeepExisting(t *testing.T) {
+	prepareMergeData(t)
+
+	result, err := dbService.Restore(&testUser, strings.NewReader(testMergeData), RestoreOptions{Mode: RestoreMerge})
+	assert.NoError(t, err)
+	assert.Equal(t, &RestoreResult{Inserted: 2, Skipped: 2}, result)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 5)
+	balances := make(map[string]int64)
+	for _, account := range accounts {
+		balances[account.UUID] = account.Balance
+		if account.UUID == "uuid1" {
+			assert.Equal(t, "Local Bank", account.Name)
+		}
+	}
+	assert.Equal(t, map[string]int64{"uuid1": 99000, "uuid2": 80000, "uuid3": 80000, "uuid4": -8000, "uuid5": 5000}, balances)
+
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), count)
+}
+
+func TestRestoreMergeUseBackup(t *testing.T) {
+	prepareMergeData(t)
+
+	result, err := dbService.Restore(&testUser, strings.NewReader(testMergeData), RestoreOptions{Mode: RestoreMerge, Conflicts: RestoreUseBackup})
+	assert.NoError(t, err)
+	assert.Equal(t, &RestoreResult{Inserted: 2, Updated: 2}, result)
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 5)
+	balances := make(map[string]int64)
+	for _, account := range accounts {
+		balances[account.UUID] = account.Balance
+		if account.UUID == "uuid1" {
+			assert.Equal(t, "Orange Bank", account.Name)
+		}
+	}
+	assert.Equal(t, map[string]int64{"uuid1": 99000, "uuid2": 90000, "uuid3": 80000, "uuid4": -8000, "uuid5": 5000}, balances)
+
+	// Merging the same backup again doesn't change anything.
+	result, err = dbService.Restore(&testUser, strings.NewReader(testMergeData), RestoreOptions{Mode: RestoreMerge, Conflicts: RestoreUseBackup})
+	assert.NoError(t, err)
+	assert.Equal(t, &RestoreResult{Skipped: 4}, result)
+}
+
+func TestRestoreMergeRecalculatesBalances(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	err = dbService.update(&testUser, func(s *DBService) error {
+		return s.updateAccountBalance(&testUser, "uuid1", 1)
+	})
+	assert.NoError(t, err)
+
+	result, err := dbService.Restore(&testUser, strings.NewReader("{}"), RestoreOptions{Mode: RestoreMerge})
+	assert.NoError(t, err)
+	assert.Equal(t, &RestoreResult{}, result)
+
+	account, err := dbService.GetAccount(&testUser, "uuid1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(99000), account.Balance)
+}
+
+// createLargeTestBackup returns a backup with count transactions.
+func createLargeTestBackup(count int) string {
+	var backup strings.Builder
+	backup.WriteString(`{"Accounts": [{"UUID": "uuid1", "Name": "Orange Bank", "Currency": "PLN"}], "Transactions": [`)
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			backup.WriteString(",")
+		}
+		fmt.Fprintf(&backup, `{"UUID": "t%v", "Description": "Widgets %v", "Type": 0, "Tags": ["Widgets"], "Date": "2015-11-%02d",`+
+			`"Components": [{"Amount": -100, "AccountUUID": "uuid1"}]}`, i, i, i%28+1)
+	}
+	backup.WriteString("]}")
+	return backup.String()
+}
+
+func TestRestoreLarge(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testRestoreData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	previousRestoreChunkSize := restoreChunkSize
+	defer func() { restoreChunkSize = previousRestoreChunkSize }()
+	restoreChunkSize = 64 * 1024
+
+	backup := createLargeTestBackup(1000)
+	assert.Greater(t, len(backup), restoreChunkSize)
+
+	// A dry run doesn't save any chunks.
+	result, err := dbService.Restore(&testUser, strings.NewReader(backup), RestoreOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Len(t, result.Diff.AddedTransactions, 1000)
+	var json strings.Builder
+	err = dbService.Backup(&testUser, &json, BackupOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, testBackupData, json.String())
+
+	// A failed restore keeps the chunks which were already saved.
+	_, err = dbService.Restore(&testUser, strings.NewReader(strings.Replace(backup, `"UUID": "t600"`, `"UUID": 600`, 1)), RestoreOptions{})
+	assert.ErrorContains(t, err, "data was partially restored")
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+	assert.NoError(t, err)
+	assert.Greater(t, count, uint64(0))
+	assert.Less(t, count, uint64(1000))
+
+	for _, options := range []RestoreOptions{{Mode: RestoreMerge}, {}} {
+		result, err = dbService.Restore(&testUser, strings.NewReader(backup), options)
+		assert.NoError(t, err)
+
+		account, err := dbService.GetAccount(&testUser, "uuid1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-100000), account.Balance)
+		count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1000), count)
+	}
+	assert.Equal(t, &RestoreResult{Inserted: 1001}, result)
+}
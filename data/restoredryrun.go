@@ -0,0 +1,161 @@
+package data
+
+import (
+	"fmt"
+)
+
+// errRestoreDryRun is returned to discard the changes made by a dry-run restore.
+var errRestoreDryRun = fmt.Errorf("dry run")
+
+// RestoreDiff contains the changes a restore would make.
+type RestoreDiff struct {
+	AddedAccounts   []*Account
+	RemovedAccounts []*Account
+	// ChangedAccounts contains the accounts which would be changed, with their new values.
+	ChangedAccounts []*Account
+
+	AddedTransactions   []*Transaction
+	RemovedTransactions []*Transaction
+	// ChangedTransactions contains the transactions which would be changed, with their new values.
+	ChangedTransactions []*Transaction
+
+	// Accounts contains all accounts after the restore, with their resulting balances.
+	Accounts []*Account
+
+	// Problems lists invalid records found in the backup.
+	Problems []string
+}
+
+// restoreSnapshot contains all accounts and transactions of a user.
+type restoreSnapshot struct {
+	accounts     []*Account
+	transactions []*Transaction
+}
+
+// getRestoreSnapshot returns all accounts and transactions of user.
+func (s *DBService) getRestoreSnapshot(user *User) (*restoreSnapshot, error) {
+	accounts, err := s.getAccounts(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	transactions, err := s.getTransactions(user, GetAllTransactionsOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	return &restoreSnapshot{accounts: accounts, transactions: transactions}, nil
+}
+
+// diffAccounts adds the differences between the previous and new accounts to diff.
+func (diff *RestoreDiff) diffAccounts(previousAccounts, accounts []*Account) error {
+	previous := make(map[string]*Account, len(previousAccounts))
+	for _, account := range previousAccounts {
+		previous[account.UUID] = account
+	}
+	for _, account := range accounts {
+		previousAccount, ok := previous[account.UUID]
+		if !ok {
+			diff.AddedAccounts = append(diff.AddedAccounts, account)
+			continue
+		}
+		delete(previous, account.UUID)
+
+		// Balance changes are reported separately.
+		changedAccount := *account
+		changedAccount.Balance = previousAccount.Balance
+		same, err := sameEncodedValues(previousAccount, &changedAccount)
+		if err != nil {
+			return fmt.Errorf("cannot encode account: %w", err)
+		}
+		if !same {
+			diff.ChangedAccounts = append(diff.ChangedAccounts, account)
+		}
+	}
+	for _, account := range previousAccounts {
+		if _, ok := previous[account.UUID]; ok {
+			diff.RemovedAccounts = append(diff.RemovedAccounts, account)
+		}
+	}
+	diff.Accounts = accounts
+	return nil
+}
+
+// diffTransactions adds the differences between the previous and new transactions to diff.
+func (diff *RestoreDiff) diffTransactions(previousTransactions, transactions []*Transaction) error {
+	previous := make(map[string]*Transaction, len(previousTransactions))
+	for _, transaction := range previousTransactions {
+		previous[transaction.UUID] = transaction
+	}
+	for _, transaction := range transactions {
+		previousTransaction, ok := previous[transaction.UUID]
+		if !ok {
+			diff.AddedTransactions = append(diff.AddedTransactions, transaction)
+			continue
+		}
+		delete(previous, transaction.UUID)
+
+		same, err := sameEncodedValues(previousTransaction, transaction)
+		if err != nil {
+			return fmt.Errorf("cannot encode transaction: %w", err)
+		}
+		if !same {
+			diff.ChangedTransactions = append(diff.ChangedTransactions, transaction)
+		}
+	}
+	for _, transaction := range previousTransactions {
+		if _, ok := previous[transaction.UUID]; ok {
+			diff.RemovedTransactions = append(diff.RemovedTransactions, transaction)
+		}
+	}
+	return nil
+}
+
+// diffRestore returns the differences between snapshot and the current data of user.
+func (s *DBService) diffRestore(user *User, snapshot *restoreSnapshot, problems []string) (*RestoreDiff, error) {
+	restored, err := s.getRestoreSnapshot(user)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &RestoreDiff{Problems: problems}
+	if err := diff.diffAccounts(snapshot.accounts, restored.accounts); err != nil {
+		return nil, err
+	}
+	if err := diff.diffTransactions(snapshot.transactions, restored.transactions); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// validateBackupTransaction returns the problems found in a transaction from a backup.
+// If the transaction cannot be restored, skip is true.
+// Accounts referenced by the transaction have to be already restored.
+func (s *DBService) validateBackupTransaction(user *User, transaction *Transaction) (problems []string, skip bool, err error) {
+	if err := transaction.normalize(); err != nil {
+		problems = append(problems, fmt.Sprintf("transaction %v has an invalid date %v", transaction.UUID, transaction.Date))
+		skip = true
+	}
+
+	currencyTotals := make(map[string]int64)
+	for _, component := range transaction.Components {
+		account, err := s.getAccount(user, component.AccountUUID)
+		if err != nil {
+			return nil, false, err
+		}
+		if account == nil {
+			problems = append(problems, fmt.Sprintf("transaction %v references missing account %v", transaction.UUID, component.AccountUUID))
+			skip = true
+			continue
+		}
+		currencyTotals[account.Currency] += component.Amount
+	}
+
+	// Transfers between currencies are exchanges, and only transfers in one currency have to balance.
+	if transaction.Type == TransactionTypeTransfer && !skip && len(currencyTotals) == 1 {
+		for currency, total := range currencyTotals {
+			if total != 0 {
+				problems = append(problems, fmt.Sprintf("transfer %v doesn't balance in %v, total is %v", transaction.UUID, currency, formatAmount(total)))
+			}
+		}
+	}
+	return problems, skip, nil
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDryRunExistingData = `{
+  "Accounts": [
+    {"UUID": "a1", "Name": "Orange Bank", "Currency": "PLN"},
+    {"UUID": "a2", "Name": "Green Bank", "Currency": "PLN"}
+  ],
+  "Transactions": [
+    {"UUID": "t1", "Description": "Salary", "Type": 0, "Date": "2015-11-01", "Components": [{"Amount": 100, "AccountUUID": "a1"}]},
+    {"UUID": "t2", "Description": "Widgets", "Type": 0, "Date": "2015-11-02", "Components": [{"Amount": -50, "AccountUUID": "a2"}]}
+  ]
+}`
+
+const testDryRunData = `{
+  "Accounts": [
+    {"UUID": "a1", "Name": "Renamed Bank", "Currency": "PLN"},
+    {"UUID": "a3", "Name": "Purple Bank", "Currency": "EUR"},
+    {"UUID": "a4", "Name": "Blue Bank", "Currency": "PLN"}
+  ],
+  "Transactions": [
+    {"UUID": "t1", "Description": "Salary", "Type": 0, "Date": "2015-11-01", "Components": [{"Amount": 100, "AccountUUID": "a1"}]},
+    {"UUID": "t3", "Description": "Exchange", "Type": 1, "Date": "2015-11-03",
+      "Components": [{"Amount": -30, "AccountUUID": "a1"}, {"Amount": 30, "AccountUUID": "a3"}]},
+    {"UUID": "t4", "Description": "Gadgets", "Type": 0, "Date": "2015-11-32", "Components": []},
+    {"UUID": "t6", "Description": "Transfer", "Type": 1, "Date": "2015-11-05",
+      "Components": [{"Amount": -20, "AccountUUID": "a1"}, {"Amount": 15, "AccountUUID": "a4"}]},
+    {"UUID": "t5", "Description": "Stuff", "Type": 0, "Date": "2015-11-04", "Components": [{"Amount": -10, "AccountUUID": "a9"}]}
+  ]
+}`
+
+// getTestBalances returns the balances of accounts.
+func getTestBalances(accounts []*Account) map[string]int64 {
+	balances := make(map[string]int64)
+	for _, account := range accounts {
+		balances[account.UUID] = account.Balance
+	}
+	return balances
+}
+
+// getTestTransactionUUIDs returns the UUIDs of transactions.
+func getTestTransactionUUIDs(transactions []*Transaction) []string {
+	transactionUUIDs := make([]string, 0, len(transactions))
+	for _, transaction := range transactions {
+		transactionUUIDs = append(transactionUUIDs, transaction.UUID)
+	}
+	return transactionUUIDs
+}
+
+// assertDryRunUnchanged checks that the existing data wasn't changed by a dry run.
+func assertDryRunUnchanged(t *testing.T) {
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a1": 100, "a2": -50}, getTestBalances(accounts))
+	for _, account := range accounts {
+		if account.UUID == "a1" {
+			assert.Equal(t, "Orange Bank", account.Name)
+		}
+	}
+
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), count)
+}
+
+var testDryRunAddedAccounts = []*Account{
+	{UUID: "a3", Name: "Purple Bank", Currency: "EUR", Balance: 30},
+	{UUID: "a4", Name: "Blue Bank", Currency: "PLN", Balance: 15},
+}
+
+// The exchange t3 between PLN and EUR accounts doesn't have to balance.
+var testDryRunProblems = []string{
+	"transaction t4 has an invalid date 2015-11-32",
+	"transfer t6 doesn't balance in PLN, total is -0.05",
+	"transaction t5 references missing account a9",
+}
+
+func TestRestoreDryRunReplace(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testDryRunExistingData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	result, err := dbService.Restore(&testUser, strings.NewReader(testDryRunData), RestoreOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, result.Inserted)
+	assert.Equal(t, 0, result.Updated)
+	assert.Equal(t, 0, result.Skipped)
+
+	diff := result.Diff
+	assert.NotNil(t, diff)
+	assert.Equal(t, testDryRunAddedAccounts, diff.AddedAccounts)
+	assert.Equal(t, []*Account{{UUID: "a2", Name: "Green Bank", Currency: "PLN", Balance: -50}}, diff.RemovedAccounts)
+	assert.Equal(t, []*Account{{UUID: "a1", Name: "Renamed Bank", Currency: "PLN", Balance: 50}}, diff.ChangedAccounts)
+	assert.Equal(t, []string{"t6", "t3"}, getTestTransactionUUIDs(diff.AddedTransactions))
+	assert.Len(t, diff.RemovedTransactions, 1)
+	assert.Equal(t, "t2", diff.RemovedTransactions[0].UUID)
+	assert.Empty(t, diff.ChangedTransactions)
+	assert.Equal(t, map[string]int64{"a1": 50, "a3": 30, "a4": 15}, getTestBalances(diff.Accounts))
+	assert.Equal(t, testDryRunProblems, diff.Problems)
+
+	assertDryRunUnchanged(t)
+}
+
+func TestRestoreDryRunMerge(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testDryRunExistingData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	result, err := dbService.Restore(&testUser, strings.NewReader(testDryRunData), RestoreOptions{Mode: RestoreMerge, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Inserted)
+	assert.Equal(t, 0, result.Updated)
+	assert.Equal(t, 2, result.Skipped)
+
+	diff := result.Diff
+	assert.NotNil(t, diff)
+	assert.Equal(t, testDryRunAddedAccounts, diff.AddedAccounts)
+	assert.Empty(t, diff.RemovedAccounts)
+	assert.Empty(t, diff.ChangedAccounts)
+	assert.Equal(t, []string{"t6", "t3"}, getTestTransactionUUIDs(diff.AddedTransactions))
+	assert.Empty(t, diff.RemovedTransactions)
+	assert.Empty(t, diff.ChangedTransactions)
+	assert.Equal(t, map[string]int64{"a1": 50, "a2": -50, "a3": 30, "a4": 15}, getTestBalances(diff.Accounts))
+	assert.Equal(t, testDryRunProblems, diff.Problems)
+
+	assertDryRunUnchanged(t)
+}
+
+func TestRestoreDryRunInvalid(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	_, err = dbService.Restore(&testUser, strings.NewReader(testDryRunExistingData), RestoreOptions{})
+	assert.NoError(t, err)
+
+	result, err := dbService.Restore(&testUser, strings.NewReader(`{"Accounts": [`), RestoreOptions{DryRun: true})
+	assert.Error(t, err)
+	assert.Nil(t, result)
+
+	assertDryRunUnchanged(t)
+}
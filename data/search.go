@@ -0,0 +1,164 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SearchTransactionOptions specifies a search query, paging and filtering options for searching transactions.
+type SearchTransactionOptions struct {
+	Query  string
+	Offset uint64
+	Limit  uint64
+	TransactionFilterOptions
+}
+
+// SearchResult is a page of transactions found by a search.
+type SearchResult struct {
+	Transactions []*Transaction
+	// Total is the number of transactions matching the search, on all pages.
+	Total uint64
+}
+
+// searchWords splits text into deduplicated words, folded to lowercase and without diacritics.
+func searchWords(text string) []string {
+	words := make([]string, 0)
+	added := make(map[string]bool)
+	var word strings.Builder
+	addWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		if !added[word.String()] {
+			added[word.String()] = true
+			words = append(words, word.String())
+		}
+		word.Reset()
+	}
+	// Decompose letters with diacritics into base letters followed by combining marks.
+	for _, r := range norm.NFD.String(text) {
+		if unicode.Is(unicode.Mn, r) {
+			// Skip combining diacritical marks.
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			addWord()
+			continue
+		}
+		word.WriteRune(unicode.ToLower(r))
+	}
+	addWord()
+	return words
+}
+
+// searchWordMatches returns the ranks of transactions matching term.
+// A word equal to term ranks higher than a word starting with term.
+// References outside the date range of options are skipped.
+func (s *DBService) searchWordMatches(user *User, words [][]byte, term string, options *TransactionFilterOptions) (map[transactionReference]int, error) {
+	matches := make(map[transactionReference]int)
+	first := sort.Search(len(words), func(i int) bool { return bytes.Compare(words[i], []byte(term)) >= 0 })
+	for i := first; i < len(words) && bytes.HasPrefix(words[i], []byte(term)); i++ {
+		rank := 1
+		if string(words[i]) == term {
+			rank = 2
+		}
+		references, err := s.getTransactionReferencesUnion([][]byte{user.createSearchIndexKey(string(words[i]))}, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, reference := range references {
+			if matches[reference] < rank {
+				matches[reference] = rank
+			}
+		}
+	}
+	return matches, nil
+}
+
+// searchTransactionReferences returns references to transactions with descriptions containing all words from query,
+// sorted by rank.
+// Transactions where more words match exactly (instead of only by prefix) rank higher;
+// transactions with the same rank are sorted in the same order as iterateTransactions.
+func (s *DBService) searchTransactionReferences(user *User, query string, options *TransactionFilterOptions) ([]transactionReference, error) {
+	terms := searchWords(query)
+	if len(terms) == 0 {
+		return []transactionReference{}, nil
+	}
+
+	words, err := s.getReferencedKeys([]byte(user.createSearchIndexKeyPrefix()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indexed words: %w", err)
+	}
+
+	var ranks map[transactionReference]int
+	for _, term := range terms {
+		matches, err := s.searchWordMatches(user, words, term, options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for %v: %w", term, err)
+		}
+		if ranks == nil {
+			ranks = matches
+			continue
+		}
+		for reference, rank := range ranks {
+			if matches[reference] == 0 {
+				delete(ranks, reference)
+			} else {
+				ranks[reference] = rank + matches[reference]
+			}
+		}
+	}
+
+	references := make([]transactionReference, 0, len(ranks))
+	for reference := range ranks {
+		references = append(references, reference)
+	}
+	if err := s.sortTransactionReferences(user, references); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(references, func(i, j int) bool {
+		return ranks[references[i]] > ranks[references[j]]
+	})
+	return references, nil
+}
+
+// SearchTransactions returns a page of transactions with descriptions containing all words from the search query,
+// sorted by rank.
+// Words are matched by prefix and ignore case and diacritics; only transactions matching the filter options are returned.
+func (s *DBService) SearchTransactions(user *User, options SearchTransactionOptions) (*SearchResult, error) {
+	result := &SearchResult{Transactions: make([]*Transaction, 0)}
+	err := s.view(user, func() error {
+		references, err := s.searchTransactionReferences(user, options.Query, &options.TransactionFilterOptions)
+		if err != nil {
+			return err
+		}
+		for _, reference := range references {
+			transaction, err := s.getTransaction(user, reference.UUID)
+			if err != nil {
+				return err
+			}
+			if transaction == nil {
+				s.scheduleCleanup(user)
+				continue
+			}
+			if !options.TransactionFilterOptions.Matches(transaction) {
+				continue
+			}
+
+			if result.Total >= options.Offset && uint64(len(result.Transactions)) < options.Limit {
+				result.Transactions = append(result.Transactions, transaction)
+			}
+			result.Total++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transactions: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchWords(t *testing.T) {
+	assert.Equal(t, []string{"cafe", "muller", "s", "strasse", "straße", "елка", "2019"}, searchWords("Café Müller's STRASSE, straße: Ёлка 2019"))
+	assert.Equal(t, []string{"angstrom", "creme", "pho"}, searchWords("Ångström Cre\u0300me PHỞ"))
+	assert.Equal(t, []string{"cafe"}, searchWords("Café CAFÉ"))
+	assert.Empty(t, searchWords(" - "))
+}
+
+func TestSearchTransactions(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "Coffee at Café Central", Date: "2019-03-01", Type: TransactionTypeExpenseIncome}
+	transaction2 := &Transaction{Description: "Coffee beans", Date: "2019-03-02", Type: TransactionTypeExpenseIncome}
+	transaction3 := &Transaction{Description: "Coffeehouse", Date: "2019-03-03", Type: TransactionTypeExpenseIncome}
+	transaction4 := &Transaction{Description: "Tea", Date: "2019-03-04", Type: TransactionTypeTransfer}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3, transaction4} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	// Exact matches rank higher than prefix matches.
+	result, err := dbService.SearchTransactions(&testUser, SearchTransactionOptions{Query: "COFFEE", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, &SearchResult{Transactions: []*Transaction{transaction2, transaction1, transaction3}, Total: 3}, result)
+
+	result, err = dbService.SearchTransactions(&testUser, SearchTransactionOptions{Query: "coffee", Offset: 1, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, &SearchResult{Transactions: []*Transaction{transaction1}, Total: 3}, result)
+
+	// All words have to match.
+	result, err = dbService.SearchTransactions(&testUser, SearchTransactionOptions{Query: "caf coff", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, &SearchResult{Transactions: []*Transaction{transaction1}, Total: 1}, result)
+
+	// Filter options are applied to search results.
+	result, err = dbService.SearchTransactions(&testUser, SearchTransactionOptions{
+		Query:                    "coffee",
+		Limit:                    10,
+		TransactionFilterOptions: TransactionFilterOptions{FilterFromDate: "2019-03-02", FilterDescription: "bean"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &SearchResult{Transactions: []*Transaction{transaction2}, Total: 1}, result)
+
+	result, err = dbService.SearchTransactions(&testUser, SearchTransactionOptions{
+		Query:                    "tea",
+		Limit:                    10,
+		TransactionFilterOptions: TransactionFilterOptions{ExcludeTransfer: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &SearchResult{Transactions: []*Transaction{}, Total: 0}, result)
+
+	result, err = dbService.SearchTransactions(&testUser, SearchTransactionOptions{Query: " ", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, &SearchResult{Transactions: []*Transaction{}, Total: 0}, result)
+}
+
+func TestSearchUpdatedTransactions(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "Groceries", Date: "2019-03-01"}
+	transaction2 := &Transaction{Description: "Groceries", Date: "2019-03-02"}
+	for _, transaction := range []*Transaction{transaction1, transaction2} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	transaction1.Description = "Rent"
+	err = dbService.UpdateTransaction(&testUser, transaction1, false)
+	assert.NoError(t, err)
+	err = dbService.DeleteTransaction(&testUser, transaction2.UUID, false)
+	assert.NoError(t, err)
+
+	result, err := dbService.SearchTransactions(&testUser, SearchTransactionOptions{Query: "groceries", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, &SearchResult{Transactions: []*Transaction{}, Total: 0}, result)
+
+	result, err = dbService.SearchTransactions(&testUser, SearchTransactionOptions{Query: "rent", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, &SearchResult{Transactions: []*Transaction{transaction1}, Total: 1}, result)
+
+	words, err := dbService.getReferencedKeys([]byte(testUser.createSearchIndexKeyPrefix()))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("rent")}, words)
+}
@@ -0,0 +1,667 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/akrylysov/pogreb"
+	log "github.com/sirupsen/logrus"
+)
+
+// currentIndexVersion is the version of indexes; existing items are reindexed if the stored version is older.
+const currentIndexVersion = 5
+
+// transactionReference is an entry of a secondary index, referencing a Transaction.
+type transactionReference struct {
+	Date string
+	UUID string
+}
+
+// createTransactionReference creates a reference to transaction.
+func createTransactionReference(transaction *Transaction) (transactionReference, error) {
+	date, err := time.Parse(inputDateFormat, transaction.Date)
+	if err != nil {
+		return transactionReference{}, fmt.Errorf("cannot parse date %v: %w", transaction.Date, err)
+	}
+	return transactionReference{Date: date.Format(dateFormat), UUID: transaction.UUID}, nil
+}
+
+// transactionAccountUUIDs returns the deduplicated UUIDs of accounts used by transaction's components.
+func transactionAccountUUIDs(transaction *Transaction) []string {
+	accountUUIDs := make([]string, 0, len(transaction.Components))
+	for _, component := range transaction.Components {
+		duplicate := false
+		for _, accountUUID := range accountUUIDs {
+			if accountUUID == component.AccountUUID {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			accountUUIDs = append(accountUUIDs, component.AccountUUID)
+		}
+	}
+	return accountUUIDs
+}
+
+// transactionReferenceBucket returns the bucket of a secondary index which contains reference.
+// References are split into buckets by month, so that updating an index only rewrites the references from one month.
+func transactionReferenceBucket(reference transactionReference) string {
+	return reference.Date[:len("2006-01")]
+}
+
+// getBucketTransactionReferences returns the references from a bucket of a secondary index, sorted by date.
+func (s *DBService) getBucketTransactionReferences(bucketKey []byte) ([]transactionReference, error) {
+	value, err := s.db.Get(bucketKey)
+	if err != nil {
+		return nil, err
+	}
+	references := make([]transactionReference, 0)
+	if len(value) == 0 {
+		return references, nil
+	}
+	if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&references); err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+// saveBucketTransactionReferences saves references into a bucket of a secondary index.
+// If references is empty, the bucket is deleted.
+func (s *DBService) saveBucketTransactionReferences(bucketKey []byte, references []transactionReference) error {
+	if len(references) == 0 {
+		return s.db.Delete(bucketKey)
+	}
+	var value bytes.Buffer
+	if err := gob.NewEncoder(&value).Encode(references); err != nil {
+		return err
+	}
+	return s.db.Put(bucketKey, value.Bytes())
+}
+
+// getTransactionReferences returns the references from all buckets of a secondary index key, sorted by date.
+// Buckets which are entirely before fromDate or after toDate are skipped; empty dates are not checked.
+func (s *DBService) getTransactionReferences(key []byte, fromDate, toDate string) ([]transactionReference, error) {
+	buckets, err := s.getReferencedKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	references := make([]transactionReference, 0)
+	for _, bucket := range buckets {
+		if fromDate != "" && string(bucket) < transactionReferenceBucket(transactionReference{Date: fromDate}) {
+			continue
+		}
+		if toDate != "" && string(bucket) > transactionReferenceBucket(transactionReference{Date: toDate}) {
+			continue
+		}
+		bucketReferences, err := s.getBucketTransactionReferences(createSecondaryIndexBucketKey(key, string(bucket)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bucket %v: %w", string(bucket), err)
+		}
+		references = append(references, bucketReferences...)
+	}
+	return references, nil
+}
+
+// addTransactionReference adds reference to a secondary index key, keeping references sorted by date.
+func (s *DBService) addTransactionReference(key []byte, reference transactionReference) error {
+	bucket := transactionReferenceBucket(reference)
+	bucketKey := createSecondaryIndexBucketKey(key, bucket)
+	references, err := s.getBucketTransactionReferences(bucketKey)
+	if err != nil {
+		return err
+	}
+	for i := range references {
+		if references[i].UUID == reference.UUID {
+			return nil
+		}
+	}
+
+	i := sort.Search(len(references), func(i int) bool { return references[i].Date > reference.Date })
+	references = append(references, transactionReference{})
+	copy(references[i+1:], references[i:])
+	references[i] = reference
+	if err := s.saveBucketTransactionReferences(bucketKey, references); err != nil {
+		return err
+	}
+	return s.addReferencedKey(key, []byte(bucket), true)
+}
+
+// deleteTransactionReference removes reference from a secondary index key.
+// Returns true if the index has no more references.
+func (s *DBService) deleteTransactionReference(key []byte, reference transactionReference) (bool, error) {
+	bucket := transactionReferenceBucket(reference)
+	bucketKey := createSecondaryIndexBucketKey(key, bucket)
+	references, err := s.getBucketTransactionReferences(bucketKey)
+	if err != nil {
+		return false, err
+	}
+	updatedReferences := make([]transactionReference, 0, len(references))
+	for _, bucketReference := range references {
+		if bucketReference.UUID != reference.UUID {
+			updatedReferences = append(updatedReferences, bucketReference)
+		}
+	}
+	if err := s.saveBucketTransactionReferences(bucketKey, updatedReferences); err != nil {
+		return false, err
+	}
+	if len(updatedReferences) > 0 {
+		return false, nil
+	}
+
+	if err := s.deleteReferencedKey(key, []byte(bucket)); err != nil {
+		return false, err
+	}
+	buckets, err := s.getReferencedKeys(key)
+	if err != nil {
+		return false, err
+	}
+	if len(buckets) > 0 {
+		return false, nil
+	}
+	return true, s.db.Delete(key)
+}
+
+// createTransactionSecondaryIndexes adds transaction to the tag, account and full-text indexes.
+func (s *DBService) createTransactionSecondaryIndexes(user *User, transaction *Transaction) error {
+	reference, err := createTransactionReference(transaction)
+	if err != nil {
+		return err
+	}
+	for _, tag := range normalizeTags(transaction.Tags) {
+		if err := s.addTransactionReference(user.createTagIndexKey(tag), reference); err != nil {
+			return fmt.Errorf("cannot add transaction %v to index of tag %v: %w", transaction.UUID, tag, err)
+		}
+		if err := s.addReferencedKey([]byte(user.createTagIndexKeyPrefix()), []byte(tag), true); err != nil {
+			return fmt.Errorf("cannot add tag %v to index: %w", tag, err)
+		}
+	}
+	for _, accountUUID := range transactionAccountUUIDs(transaction) {
+		if err := s.addTransactionReference(user.createAccountTransactionsIndexKey(accountUUID), reference); err != nil {
+			return fmt.Errorf("cannot add transaction %v to index of account %v: %w", transaction.UUID, accountUUID, err)
+		}
+		if err := s.addReferencedKey([]byte(user.createAccountTransactionsIndexKeyPrefix()), []byte(accountUUID), true); err != nil {
+			return fmt.Errorf("cannot add account %v to index: %w", accountUUID, err)
+		}
+	}
+	for _, word := range searchWords(transaction.Description) {
+		if err := s.addTransactionReference(user.createSearchIndexKey(word), reference); err != nil {
+			return fmt.Errorf("cannot add transaction %v to index of word %v: %w", transaction.UUID, word, err)
+		}
+		if err := s.addReferencedKey([]byte(user.createSearchIndexKeyPrefix()), []byte(word), true); err != nil {
+			return fmt.Errorf("cannot add word %v to index: %w", word, err)
+		}
+	}
+	return nil
+}
+
+// deleteTransactionSecondaryIndexes removes transaction from the tag, account and full-text indexes.
+// Tags, accounts and words which are no longer used by any transactions are removed from the list of tags, accounts or words.
+func (s *DBService) deleteTransactionSecondaryIndexes(user *User, transaction *Transaction) error {
+	reference, err := createTransactionReference(transaction)
+	if err != nil {
+		// Transactions with an invalid date are not indexed.
+		return nil
+	}
+	for _, tag := range normalizeTags(transaction.Tags) {
+		empty, err := s.deleteTransactionReference(user.createTagIndexKey(tag), reference)
+		if err != nil {
+			return fmt.Errorf("cannot delete transaction %v from index of tag %v: %w", transaction.UUID, tag, err)
+		}
+		if !empty {
+			continue
+		}
+		if err := s.deleteReferencedKey([]byte(user.createTagIndexKeyPrefix()), []byte(tag)); err != nil {
+			return fmt.Errorf("cannot delete tag %v from index: %w", tag, err)
+		}
+	}
+	for _, accountUUID := range transactionAccountUUIDs(transaction) {
+		empty, err := s.deleteTransactionReference(user.createAccountTransactionsIndexKey(accountUUID), reference)
+		if err != nil {
+			return fmt.Errorf("cannot delete transaction %v from index of account %v: %w", transaction.UUID, accountUUID, err)
+		}
+		if !empty {
+			continue
+		}
+		if err := s.deleteReferencedKey([]byte(user.createAccountTransactionsIndexKeyPrefix()), []byte(accountUUID)); err != nil {
+			return fmt.Errorf("cannot delete account %v from index: %w", accountUUID, err)
+		}
+	}
+	for _, word := range searchWords(transaction.Description) {
+		empty, err := s.deleteTransactionReference(user.createSearchIndexKey(word), reference)
+		if err != nil {
+			return fmt.Errorf("cannot delete transaction %v from index of word %v: %w", transaction.UUID, word, err)
+		}
+		if !empty {
+			continue
+		}
+		if err := s.deleteReferencedKey([]byte(user.createSearchIndexKeyPrefix()), []byte(word)); err != nil {
+			return fmt.Errorf("cannot delete word %v from index: %w", word, err)
+		}
+	}
+	return nil
+}
+
+// getTransactionReferencesUnion returns references from all index keys, without duplicates
+// and without references outside the date range of options.
+func (s *DBService) getTransactionReferencesUnion(keys [][]byte, options *TransactionFilterOptions) ([]transactionReference, error) {
+	union := make([]transactionReference, 0)
+	added := make(map[string]bool)
+	for _, key := range keys {
+		references, err := s.getTransactionReferences(key, options.FilterFromDate, options.FilterToDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get index %v: %w", string(key), err)
+		}
+		for _, reference := range references {
+			if options.FilterFromDate != "" && reference.Date < options.FilterFromDate {
+				continue
+			}
+			if options.FilterToDate != "" && reference.Date > options.FilterToDate {
+				continue
+			}
+			if added[reference.UUID] {
+				continue
+			}
+			added[reference.UUID] = true
+			union = append(union, reference)
+		}
+	}
+	return union, nil
+}
+
+// sortTransactionReferences sorts references in the same order as iterateTransactions:
+// newest first, and transactions from the same day in reverse order of the day index.
+func (s *DBService) sortTransactionReferences(user *User, references []transactionReference) error {
+	dateCount := make(map[string]int)
+	for _, reference := range references {
+		dateCount[reference.Date]++
+	}
+	positions := make(map[string]int)
+	for date, count := range dateCount {
+		if count < 2 {
+			continue
+		}
+		parsedDate, err := time.Parse(dateFormat, date)
+		if err != nil {
+			return fmt.Errorf("cannot parse date %v: %w", date, err)
+		}
+		_, _, _, indexKey := user.createTransactionIndexPath(uint16(parsedDate.Year()), uint8(parsedDate.Month()), uint8(parsedDate.Day()))
+		transactionUUIDs, err := s.getReferencedKeys(indexKey)
+		if err != nil {
+			return fmt.Errorf("failed get transactions index: %w", err)
+		}
+		for i, transactionUUID := range transactionUUIDs {
+			positions[string(transactionUUID)] = i
+		}
+	}
+	sort.SliceStable(references, func(i, j int) bool {
+		if references[i].Date != references[j].Date {
+			return references[i].Date > references[j].Date
+		}
+		return positions[references[i].UUID] > positions[references[j].UUID]
+	})
+	return nil
+}
+
+// getIndexedTransactionUUIDs uses the most selective secondary index to return the UUIDs of transactions
+// which might match options, in the same order as iterateTransactions.
+// If start is not nil, only transactions after start are returned.
+// Returns nil if options don't filter by tags or accounts.
+func (s *DBService) getIndexedTransactionUUIDs(user *User, options *TransactionFilterOptions, start *transactionCursor) ([]string, error) {
+	var references []transactionReference
+	if len(options.FilterTags) > 0 {
+		keys := make([][]byte, len(options.FilterTags))
+		for i, tag := range options.FilterTags {
+			keys[i] = user.createTagIndexKey(tag)
+		}
+		tagReferences, err := s.getTransactionReferencesUnion(keys, options)
+		if err != nil {
+			return nil, err
+		}
+		references = tagReferences
+	}
+	if len(options.FilterAccounts) > 0 {
+		keys := make([][]byte, len(options.FilterAccounts))
+		for i, accountUUID := range options.FilterAccounts {
+			keys[i] = user.createAccountTransactionsIndexKey(accountUUID)
+		}
+		accountReferences, err := s.getTransactionReferencesUnion(keys, options)
+		if err != nil {
+			return nil, err
+		}
+		if references == nil || len(accountReferences) < len(references) {
+			references = accountReferences
+		}
+	}
+	if references == nil {
+		return nil, nil
+	}
+
+	if start != nil {
+		afterStart, err := s.transactionReferenceFilter(user, start)
+		if err != nil {
+			return nil, err
+		}
+		filteredReferences := make([]transactionReference, 0, len(references))
+		for _, reference := range references {
+			if afterStart(reference) {
+				filteredReferences = append(filteredReferences, reference)
+			}
+		}
+		references = filteredReferences
+	}
+
+	if err := s.sortTransactionReferences(user, references); err != nil {
+		return nil, err
+	}
+	transactionUUIDs := make([]string, len(references))
+	for i := range references {
+		transactionUUIDs[i] = references[i].UUID
+	}
+	return transactionUUIDs, nil
+}
+
+// iterateMatchingTransactions works like iterateTransactions, but if possible uses a secondary index
+// to skip transactions which don't match options.
+// handleFn is still responsible for checking that transactions match options.
+func (s *DBService) iterateMatchingTransactions(user *User, options *TransactionFilterOptions, start *transactionCursor,
+	handleFn func(transactionUUID string) error,
+	doneFn func() bool) error {
+	transactionUUIDs, err := s.getIndexedTransactionUUIDs(user, options, start)
+	if err != nil {
+		return fmt.Errorf("failed to get transactions from index: %w", err)
+	}
+	if transactionUUIDs == nil {
+		return s.iterateTransactions(user, start, handleFn, doneFn)
+	}
+	for _, transactionUUID := range transactionUUIDs {
+		if err := handleFn(transactionUUID); err != nil {
+			return err
+		}
+		if doneFn() {
+			return nil
+		}
+	}
+	return nil
+}
+
+// getSecondaryIndexKeys returns all tag, account and full-text index keys of user, including their buckets
+// and the lists of tags, accounts and words.
+func (s *DBService) getSecondaryIndexKeys(user *User) ([][]byte, error) {
+	lists := []struct {
+		key      string
+		indexKey func(string) []byte
+	}{
+		{key: user.createTagIndexKeyPrefix(), indexKey: user.createTagIndexKey},
+		{key: user.createAccountTransactionsIndexKeyPrefix(), indexKey: user.createAccountTransactionsIndexKey},
+		{key: user.createSearchIndexKeyPrefix(), indexKey: user.createSearchIndexKey},
+	}
+	keys := make([][]byte, 0)
+	for _, list := range lists {
+		keys = append(keys, []byte(list.key))
+		values, err := s.getReferencedKeys([]byte(list.key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get list %v: %w", list.key, err)
+		}
+		for _, value := range values {
+			indexKey := list.indexKey(string(value))
+			keys = append(keys, indexKey)
+			buckets, err := s.getReferencedKeys(indexKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get buckets of index %v: %w", string(indexKey), err)
+			}
+			for _, bucket := range buckets {
+				keys = append(keys, createSecondaryIndexBucketKey(indexKey, string(bucket)))
+			}
+		}
+	}
+	return keys, nil
+}
+
+// getLegacySecondaryIndexKeys returns the tag, account and full-text index keys of all users, grouped by user UUID.
+// Indexes created by older versions might not be listed, so all items are checked.
+func (s *DBService) getLegacySecondaryIndexKeys() (map[string][][]byte, error) {
+	prefixes := []string{tagIndexKeyPrefix, accountTransactionsIndexKeyPrefix, searchIndexKeyPrefix}
+	keys := make(map[string][][]byte)
+	it := s.db.Items()
+	for {
+		key, _, err := it.Next()
+		if err == pogreb.ErrIterationDone {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for _, prefix := range prefixes {
+			if !bytes.HasPrefix(key, []byte(prefix)) {
+				continue
+			}
+			userUUID, _, _ := bytes.Cut(key[len(prefix):], []byte(separator))
+			keys[string(userUUID)] = append(keys[string(userUUID)], key)
+			break
+		}
+	}
+	return keys, nil
+}
+
+// describeSecondaryIndexKey returns the tag, account or word indexed by a secondary index key or its bucket.
+func (user *User) describeSecondaryIndexKey(key []byte) string {
+	tagPrefix := user.createTagIndexKeyPrefix() + separator
+	if bytes.HasPrefix(key, []byte(tagPrefix)) {
+		encodedTag, _, _ := bytes.Cut(key[len(tagPrefix):], []byte(separator))
+		tag, err := decodePart(string(encodedTag))
+		if err == nil {
+			return "tag " + tag
+		}
+	}
+	accountPrefix := user.createAccountTransactionsIndexKeyPrefix() + separator
+	if bytes.HasPrefix(key, []byte(accountPrefix)) {
+		accountUUID, _, _ := bytes.Cut(key[len(accountPrefix):], []byte(separator))
+		return "account " + string(accountUUID)
+	}
+	wordPrefix := user.createSearchIndexKeyPrefix() + separator
+	if bytes.HasPrefix(key, []byte(wordPrefix)) {
+		encodedWord, _, _ := bytes.Cut(key[len(wordPrefix):], []byte(separator))
+		word, err := decodePart(string(encodedWord))
+		if err == nil {
+			return "word " + word
+		}
+	}
+	return string(key)
+}
+
+// indexTransactions adds transactions to the tag, account and full-text indexes.
+// Transactions with an invalid date are not indexed.
+func (s *DBService) indexTransactions(user *User, transactions []*Transaction) error {
+	for _, transaction := range transactions {
+		if _, err := time.Parse(inputDateFormat, transaction.Date); err != nil {
+			continue
+		}
+		if err := s.createTransactionSecondaryIndexes(user, transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteKeys deletes all keys.
+func (s *DBService) deleteKeys(keys [][]byte) error {
+	for _, key := range keys {
+		if err := s.db.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete index %v: %w", string(key), err)
+		}
+	}
+	return nil
+}
+
+// rebuildSecondaryIndexes replaces the tag, account and full-text indexes of user with indexes of transactions.
+// Transactions with an invalid date are not indexed.
+func (s *DBService) rebuildSecondaryIndexes(user *User, transactions []*Transaction) error {
+	keys, err := s.getSecondaryIndexKeys(user)
+	if err != nil {
+		return fmt.Errorf("failed to get secondary indexes: %w", err)
+	}
+	if err := s.deleteKeys(keys); err != nil {
+		return err
+	}
+	return s.indexTransactions(user, transactions)
+}
+
+// checkSecondaryIndexes checks that the tag, account and full-text indexes of user reference exactly transactions.
+// If repair is true, inconsistent indexes are rebuilt.
+func (s *DBService) checkSecondaryIndexes(user *User, transactions []*Transaction, repair bool, report *FsckReport) error {
+	type secondaryIndex struct {
+		name       string
+		references map[transactionReference]bool
+	}
+	expected := make(map[string]*secondaryIndex)
+	expectedTags := make(map[string]bool)
+	expectedAccounts := make(map[string]bool)
+	expectedWords := make(map[string]bool)
+	addReference := func(key []byte, name string, reference transactionReference) {
+		index, ok := expected[string(key)]
+		if !ok {
+			index = &secondaryIndex{name: name, references: make(map[transactionReference]bool)}
+			expected[string(key)] = index
+		}
+		index.references[reference] = true
+	}
+	for _, transaction := range transactions {
+		reference, err := createTransactionReference(transaction)
+		if err != nil {
+			continue
+		}
+		for _, tag := range normalizeTags(transaction.Tags) {
+			addReference(user.createTagIndexKey(tag), "tag "+tag, reference)
+			expectedTags[tag] = true
+		}
+		for _, accountUUID := range transactionAccountUUIDs(transaction) {
+			addReference(user.createAccountTransactionsIndexKey(accountUUID), "account "+accountUUID, reference)
+			expectedAccounts[accountUUID] = true
+		}
+		for _, word := range searchWords(transaction.Description) {
+			addReference(user.createSearchIndexKey(word), "word "+word, reference)
+			expectedWords[word] = true
+		}
+	}
+
+	problems := make([]string, 0)
+
+	lists := []struct {
+		key      string
+		name     string
+		expected map[string]bool
+	}{
+		{key: user.createTagIndexKeyPrefix(), name: "tags", expected: expectedTags},
+		{key: user.createAccountTransactionsIndexKeyPrefix(), name: "accounts", expected: expectedAccounts},
+		{key: user.createSearchIndexKeyPrefix(), name: "words", expected: expectedWords},
+	}
+	for _, list := range lists {
+		values, err := s.getReferencedKeys([]byte(list.key))
+		if err != nil {
+			return fmt.Errorf("failed to get list of %v: %w", list.name, err)
+		}
+		consistent := len(values) == len(list.expected)
+		for _, value := range values {
+			consistent = consistent && list.expected[string(value)]
+		}
+		if !consistent {
+			problems = append(problems, fmt.Sprintf("list of %v is inconsistent", list.name))
+		}
+	}
+
+	expectedKeys := make(map[string]bool)
+	for key, index := range expected {
+		expectedKeys[key] = true
+		for reference := range index.references {
+			expectedKeys[string(createSecondaryIndexBucketKey([]byte(key), transactionReferenceBucket(reference)))] = true
+		}
+	}
+	inconsistentIndexes := make(map[string]bool)
+	keys, err := s.getSecondaryIndexKeys(user)
+	if err != nil {
+		return fmt.Errorf("failed to get secondary indexes: %w", err)
+	}
+	for _, key := range keys {
+		if string(key) == user.createTagIndexKeyPrefix() || string(key) == user.createAccountTransactionsIndexKeyPrefix() ||
+			string(key) == user.createSearchIndexKeyPrefix() {
+			continue
+		}
+		if !expectedKeys[string(key)] {
+			inconsistentIndexes[user.describeSecondaryIndexKey(key)] = true
+		}
+	}
+	for key, index := range expected {
+		references, err := s.getTransactionReferences([]byte(key), "", "")
+		if err != nil {
+			return fmt.Errorf("failed to get index of %v: %w", index.name, err)
+		}
+		consistent := len(references) == len(index.references)
+		for _, reference := range references {
+			consistent = consistent && index.references[reference]
+		}
+		if !consistent {
+			inconsistentIndexes[index.name] = true
+		}
+	}
+	for name := range inconsistentIndexes {
+		problems = append(problems, fmt.Sprintf("index of %v is inconsistent", name))
+	}
+
+	sort.Strings(problems)
+	for _, problem := range problems {
+		report.problem(user, "%v", problem)
+	}
+	if repair && len(problems) > 0 {
+		return s.rebuildSecondaryIndexes(user, transactions)
+	}
+	return nil
+}
+
+// buildSecondaryIndexes indexes existing transactions of all users, if indexes were created by an older version.
+// Indexes created by the older version are deleted first.
+func (s *DBService) buildSecondaryIndexes() error {
+	version, err := s.db.Get([]byte(indexVersionKey))
+	if err != nil {
+		return fmt.Errorf("cannot get index version: %w", err)
+	}
+	if len(version) > 0 && version[0] >= currentIndexVersion {
+		return nil
+	}
+
+	var users []*User
+	err = s.view(nil, func() error {
+		var err error
+		users, err = s.getUsers()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get users: %w", err)
+	}
+	// The database is scanned only once, before it's used by anything else.
+	legacyKeys, err := s.getLegacySecondaryIndexKeys()
+	if err != nil {
+		return fmt.Errorf("failed to get secondary indexes: %w", err)
+	}
+	for _, user := range users {
+		log.WithField("user", user.UUID).Info("Indexing transactions")
+		err := s.update(user, func(s *DBService) error {
+			if err := s.deleteKeys(legacyKeys[user.UUID]); err != nil {
+				return err
+			}
+			transactions, err := s.getAllTransactions(user)
+			if err != nil {
+				return err
+			}
+			return s.indexTransactions(user, transactions)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to index transactions of user %v: %w", user.UUID, err)
+		}
+	}
+	return s.update(nil, func(s *DBService) error {
+		return s.db.Put([]byte(indexVersionKey), []byte{currentIndexVersion})
+	})
+}
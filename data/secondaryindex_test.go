@@ -0,0 +1,214 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecondaryIndexSameDayOrder(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20", Tags: []string{"x"}}
+	transaction2 := &Transaction{Description: "t2", Date: "2019-03-20", Tags: []string{}}
+	transaction3 := &Transaction{Description: "t3", Date: "2019-03-20", Tags: []string{"x"}}
+	transaction4 := &Transaction{Description: "t4", Date: "2019-03-21", Tags: []string{"x"}}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3, transaction4} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	// Tagging transaction2 later shouldn't change its position among transactions from the same day.
+	transaction2.Tags = []string{"x"}
+	err = dbService.UpdateTransaction(&testUser, transaction2, false)
+	assert.NoError(t, err)
+
+	transactions, err := dbService.GetTransactions(&testUser, GetTransactionOptions{
+		Limit:                    GetAllTransactionsOptions.Limit,
+		TransactionFilterOptions: TransactionFilterOptions{FilterTags: []string{"x"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction4, transaction3, transaction2, transaction1}, transactions)
+
+	transactions, err = dbService.GetTransactions(&testUser, GetTransactionOptions{
+		Offset:                   1,
+		Limit:                    2,
+		TransactionFilterOptions: TransactionFilterOptions{FilterTags: []string{"x"}, FilterToDate: "2019-03-20"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction2, transaction1}, transactions)
+
+	count, err := dbService.CountTransactions(&testUser, TransactionFilterOptions{FilterTags: []string{"x"}, FilterFromDate: "2019-03-21"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+}
+
+func TestSecondaryIndexTagsAndAccounts(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20", Tags: []string{"a", "b"},
+		Components: []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 100}}}
+	transaction2 := &Transaction{Description: "t2", Date: "2019-03-21", Tags: []string{"b"},
+		Components: []TransactionComponent{{AccountUUID: testAccount1.UUID, Amount: 100}, {AccountUUID: testAccount2.UUID, Amount: 100}}}
+	transaction3 := &Transaction{Description: "t3", Date: "2019-03-22", Tags: []string{"c"},
+		Components: []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: 100}}}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	transactions, err := dbService.GetTransactions(&testUser, GetTransactionOptions{
+		Limit:                    GetAllTransactionsOptions.Limit,
+		TransactionFilterOptions: TransactionFilterOptions{FilterTags: []string{"b", "c"}, FilterAccounts: []string{testAccount1.UUID}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction2, transaction1}, transactions)
+
+	// Moving a transaction to another account and removing its tags updates the indexes.
+	transaction1.Tags = nil
+	transaction1.Components = []TransactionComponent{{AccountUUID: testAccount2.UUID, Amount: 100}}
+	err = dbService.UpdateTransaction(&testUser, transaction1, false)
+	assert.NoError(t, err)
+
+	transactions, err = dbService.GetTransactions(&testUser, GetTransactionOptions{
+		Limit:                    GetAllTransactionsOptions.Limit,
+		TransactionFilterOptions: TransactionFilterOptions{FilterAccounts: []string{testAccount2.UUID}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction3, transaction2, transaction1}, transactions)
+
+	tags, err := dbService.GetTags(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, tags)
+
+	// Deleting the last transaction with a tag removes the tag.
+	err = dbService.DeleteTransaction(&testUser, transaction3.UUID, false)
+	assert.NoError(t, err)
+
+	tags, err = dbService.GetTags(&testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, tags)
+
+	transactions, err = dbService.GetTransactions(&testUser, GetTransactionOptions{
+		Limit:                    GetAllTransactionsOptions.Limit,
+		TransactionFilterOptions: TransactionFilterOptions{FilterTags: []string{"c"}},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, transactions)
+}
+
+func TestBuildSecondaryIndexes(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	user := NewUser("user01")
+	err = dbService.SaveUser(user)
+	assert.NoError(t, err)
+
+	account1 := &Account{Name: "a1", Currency: "USD"}
+	err = dbService.CreateAccount(user, account1)
+	assert.NoError(t, err)
+	account2 := &Account{Name: "a2", Currency: "USD"}
+	err = dbService.CreateAccount(user, account2)
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20", Tags: []string{"a"},
+		Components: []TransactionComponent{{AccountUUID: account1.UUID, Amount: 100}}}
+	transaction2 := &Transaction{Description: "t2", Date: "2019-03-21", Tags: []string{"a", "b"},
+		Components: []TransactionComponent{{AccountUUID: account2.UUID, Amount: 100}}}
+	for _, transaction := range []*Transaction{transaction1, transaction2} {
+		err = dbService.CreateTransaction(user, transaction)
+		assert.NoError(t, err)
+	}
+
+	// Simulate a database created by an older version.
+	keys, err := dbService.getSecondaryIndexKeys(user)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 15)
+	for _, key := range append(keys, []byte(indexVersionKey)) {
+		err = dbService.db.Delete(key)
+		assert.NoError(t, err)
+	}
+	// Older versions didn't list indexed accounts.
+	legacyKey := user.createAccountTransactionsIndexKey("deleted")
+	err = dbService.db.Put(legacyKey, []byte("legacy"))
+	assert.NoError(t, err)
+
+	err = dbService.buildSecondaryIndexes()
+	assert.NoError(t, err)
+
+	exists, err := dbService.db.Has(legacyKey)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	tags, err := dbService.GetTags(user)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, tags)
+
+	transactions, err := dbService.GetTransactions(user, GetTransactionOptions{
+		Limit:                    GetAllTransactionsOptions.Limit,
+		TransactionFilterOptions: TransactionFilterOptions{FilterTags: []string{"a"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction2, transaction1}, transactions)
+
+	transactions, err = dbService.GetTransactions(user, GetTransactionOptions{
+		Limit:                    GetAllTransactionsOptions.Limit,
+		TransactionFilterOptions: TransactionFilterOptions{FilterAccounts: []string{account1.UUID}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction1}, transactions)
+
+	version, err := dbService.db.Get([]byte(indexVersionKey))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{currentIndexVersion}, version)
+}
+
+func TestSecondaryIndexBuckets(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	transaction1 := &Transaction{Description: "t1", Date: "2019-03-20", Tags: []string{"x"}}
+	transaction2 := &Transaction{Description: "t2", Date: "2019-04-01", Tags: []string{"x"}}
+	transaction3 := &Transaction{Description: "t3", Date: "2019-04-30", Tags: []string{"x"}}
+	transaction4 := &Transaction{Description: "t4", Date: "2020-01-01", Tags: []string{"x"}}
+	for _, transaction := range []*Transaction{transaction1, transaction2, transaction3, transaction4} {
+		err = dbService.CreateTransaction(&testUser, transaction)
+		assert.NoError(t, err)
+	}
+
+	// Every month is saved into a separate bucket.
+	indexKey := testUser.createTagIndexKey("x")
+	buckets, err := dbService.getReferencedKeys(indexKey)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("2019-03"), []byte("2019-04"), []byte("2020-01")}, buckets)
+	references, err := dbService.getBucketTransactionReferences(createSecondaryIndexBucketKey(indexKey, "2019-04"))
+	assert.NoError(t, err)
+	assert.Equal(t, []transactionReference{{Date: "2019-04-01", UUID: transaction2.UUID}, {Date: "2019-04-30", UUID: transaction3.UUID}}, references)
+
+	transactions, err := dbService.GetTransactions(&testUser, GetTransactionOptions{
+		Limit:                    GetAllTransactionsOptions.Limit,
+		TransactionFilterOptions: TransactionFilterOptions{FilterTags: []string{"x"}, FilterFromDate: "2019-03-21", FilterToDate: "2019-12-31"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{transaction3, transaction2}, transactions)
+
+	// Deleting the last transaction of a month deletes its bucket.
+	err = dbService.DeleteTransaction(&testUser, transaction1.UUID, false)
+	assert.NoError(t, err)
+	buckets, err = dbService.getReferencedKeys(indexKey)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("2019-04"), []byte("2020-01")}, buckets)
+	exists, err := dbService.db.Has(createSecondaryIndexBucketKey(indexKey, "2019-03"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	report, err := dbService.Fsck(false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Problems)
+}
@@ -4,29 +4,22 @@ import (
 	"fmt"
 )
 
-// GetTags returns an unsorted (but deduplicated) list of tags for user.
+// GetTags returns a sorted and deduplicated list of tags for user.
 func (s *DBService) GetTags(user *User) ([]string, error) {
-	var transactions []*Transaction
+	var tags [][]byte
 
-	err := s.view(func() error {
+	err := s.view(user, func() error {
 		var err error
-		transactions, err = s.getTransactions(user, GetAllTransactionsOptions)
+		tags, err = s.getReferencedKeys([]byte(user.createTagIndexKeyPrefix()))
 		return err
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get transactions for tags: %w", err)
+		return nil, fmt.Errorf("failed to get tags: %w", err)
 	}
 
-	tags := make(map[string]bool)
-	for _, transaction := range transactions {
-		for _, tag := range transaction.Tags {
-			tags[tag] = true
-		}
-	}
-
-	tagsList := make([]string, 0, len(tags))
-	for tag := range tags {
-		tagsList = append(tagsList, tag)
+	tagsList := make([]string, len(tags))
+	for i := range tags {
+		tagsList[i] = string(tags[i])
 	}
 	return tagsList, nil
 }
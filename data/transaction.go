@@ -20,6 +20,15 @@ const (
 	TransactionTypeTransfer
 )
 
+const (
+	// ComponentStatusUncleared is a TransactionComponent which has not yet appeared on a bank statement.
+	ComponentStatusUncleared = iota
+	// ComponentStatusCleared is a TransactionComponent which was matched with a bank statement entry.
+	ComponentStatusCleared
+	// ComponentStatusReconciled is a cleared TransactionComponent which is locked by a finished reconciliation.
+	ComponentStatusReconciled
+)
+
 // Transaction saves details for one expense item.
 type Transaction struct {
 	UUID        string
@@ -34,6 +43,8 @@ type Transaction struct {
 type TransactionComponent struct {
 	Amount      int64
 	AccountUUID string
+	// Status is the reconciliation status, one of the ComponentStatus constants.
+	Status int `json:",omitempty"`
 }
 
 // TransactionFilterOptions specifies filter parameters for Transactions.
@@ -49,11 +60,21 @@ type TransactionFilterOptions struct {
 
 // GetTransactionOptions specifies paging and filtering options for retrieving transactions.
 type GetTransactionOptions struct {
+	// Cursor is the NextCursor from the previous TransactionsPage.
+	// If not empty, Offset is counted from the cursor.
+	Cursor string
 	Offset uint64
 	Limit  uint64
 	TransactionFilterOptions
 }
 
+// TransactionsPage is a page of transactions.
+type TransactionsPage struct {
+	Transactions []*Transaction
+	// NextCursor is the cursor for the next page, or an empty string if this is the last page.
+	NextCursor string
+}
+
 // GetAllTransactionsOptions is a GetTransactionOptions which returns all transactions in one page.
 var GetAllTransactionsOptions = GetTransactionOptions{Offset: 0, Limit: ^uint64(0)}
 
@@ -85,28 +106,33 @@ func (transaction *Transaction) normalize() error {
 	}
 
 	transaction.Date = date.Format(dateFormat)
+	transaction.Tags = normalizeTags(transaction.Tags)
+	return nil
+}
 
-	if len(transaction.Tags) > 0 {
-		filteredTags := make([]string, 0, len(transaction.Tags))
-		for _, tag := range transaction.Tags {
-			duplicate := false
-			if tag == "" {
-				continue
-			}
-			for _, filteredTag := range filteredTags {
-				if filteredTag == tag {
-					duplicate = true
-					break
-				}
-			}
-			if !duplicate {
-				filteredTags = append(filteredTags, tag)
+// normalizeTags returns sorted tags without duplicates and empty tags.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return tags
+	}
+	filteredTags := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		duplicate := false
+		if tag == "" {
+			continue
+		}
+		for _, filteredTag := range filteredTags {
+			if filteredTag == tag {
+				duplicate = true
+				break
 			}
 		}
-		sort.Strings(filteredTags)
-		transaction.Tags = filteredTags
+		if !duplicate {
+			filteredTags = append(filteredTags, tag)
+		}
 	}
-	return nil
+	sort.Strings(filteredTags)
+	return filteredTags
 }
 
 // createTransactionIndexKey creates an index key for transaction.
@@ -155,41 +181,14 @@ func (s *DBService) deleteTransactionIndexKey(user *User, transaction *Transacti
 		return fmt.Errorf("cannot parse date %v: %w", transaction.Date, err)
 	}
 
-	// Build the transaction index key.
-	yearIndexKey := []byte(user.createTransactionKeyPrefix())
-	yearKey := make([]byte, 2)
-	binary.BigEndian.PutUint16(yearKey, uint16(date.Year()))
-
-	monthIndexKey := append(yearIndexKey, yearKey...)
-	monthKey := []byte{uint8(date.Month())}
-
-	dayIndexKey := append(monthIndexKey, monthKey...)
-	dayKey := []byte{uint8(date.Day())}
-	indexKey := append(dayIndexKey, dayKey...)
-
+	year, month, day := uint16(date.Year()), uint8(date.Month()), uint8(date.Day())
+	_, _, _, indexKey := user.createTransactionIndexPath(year, month, day)
 	if err := s.deleteReferencedKey(indexKey, []byte(transaction.UUID)); err != nil {
 		return err
 	}
 
 	// Cleanup empty parent keys.
-	parentIndexKeys := [][]byte{indexKey, dayIndexKey, monthIndexKey, yearIndexKey}
-	for i := range parentIndexKeys {
-		parentIndexKey := parentIndexKeys[i]
-
-		indexKeys, err := s.getReferencedKeys(parentIndexKey)
-		if err != nil {
-			return err
-		}
-		if len(indexKeys) > 0 {
-			// Items still remaining in index.
-			break
-		}
-		// No transactions remaining for this index - delete it.
-		if err := s.db.Delete(parentIndexKey); err != nil {
-			return err
-		}
-	}
-	return nil
+	return s.deleteEmptyTransactionIndexKeys(user, year, month, day)
 }
 
 // createTransaction creates transaction for user.
@@ -205,6 +204,9 @@ func (s *DBService) createTransaction(user *User, transaction *Transaction) erro
 	if err := s.createTransactionIndexKey(user, transaction); err != nil {
 		return fmt.Errorf("cannot create index for transaction: %w", err)
 	}
+	if err := s.createTransactionSecondaryIndexes(user, transaction); err != nil {
+		return fmt.Errorf("cannot create secondary indexes for transaction: %w", err)
+	}
 
 	if err := s.updateAccountsBalance(user, nil, &transaction.Components); err != nil {
 		return fmt.Errorf("cannot update account balance: %w", err)
@@ -214,17 +216,20 @@ func (s *DBService) createTransaction(user *User, transaction *Transaction) erro
 }
 
 // CreateTransaction saves a new Transaction into the database.
+// Reconciled components are saved as cleared.
 func (s *DBService) CreateTransaction(user *User, transaction *Transaction) error {
 	transaction.UUID = uuid.NewString()
+	clearReconciledComponents(transaction)
 
-	return s.update(func() error {
+	return s.update(user, func(s *DBService) error {
 		return s.createTransaction(user, transaction)
 	})
 }
 
 // UpdateTransaction updates an existing Transaction in the database.
-func (s *DBService) UpdateTransaction(user *User, transaction *Transaction) error {
-	return s.update(func() error {
+// Changes to reconciled components are rejected with ErrReconciledComponent, unless overrideReconciled is true.
+func (s *DBService) UpdateTransaction(user *User, transaction *Transaction, overrideReconciled bool) error {
+	return s.update(user, func(s *DBService) error {
 		key := user.createTransactionKey(transaction)
 
 		previousTransaction := &Transaction{}
@@ -242,28 +247,46 @@ func (s *DBService) UpdateTransaction(user *User, transaction *Transaction) erro
 			log.WithField("key", string(key)).Debug("Transaction is unchanged")
 			return nil
 		}
-
-		if err := s.updateAccountsBalance(user, &previousTransaction.Components, &transaction.Components); err != nil {
-			return fmt.Errorf("cannot update account balance: %w", err)
-		}
-
-		if transaction.Date != previousTransaction.Date {
-			if err := s.createTransactionIndexKey(user, transaction); err != nil {
-				return fmt.Errorf("cannot create index for transaction %v: %w", string(key), err)
-			}
-			if err := s.deleteTransactionIndexKey(user, previousTransaction); err != nil {
-				return fmt.Errorf("cannot delete previous index for transaction %v: %w", string(key), err)
+		if !overrideReconciled {
+			if err := checkReconciledComponents(previousTransaction, transaction); err != nil {
+				return fmt.Errorf("cannot update transaction %v: %w", string(key), err)
 			}
 		}
 
-		value, err = transaction.encode()
-		if err != nil {
-			return fmt.Errorf("cannot encode transaction: %w", err)
-		}
-		return s.db.Put(key, value)
+		return s.updateTransaction(user, previousTransaction, transaction)
 	})
 }
 
+// updateTransaction replaces previousTransaction with transaction, updating account balances and indexes.
+func (s *DBService) updateTransaction(user *User, previousTransaction, transaction *Transaction) error {
+	key := user.createTransactionKey(transaction)
+
+	if err := s.updateAccountsBalance(user, &previousTransaction.Components, &transaction.Components); err != nil {
+		return fmt.Errorf("cannot update account balance: %w", err)
+	}
+
+	if transaction.Date != previousTransaction.Date {
+		if err := s.createTransactionIndexKey(user, transaction); err != nil {
+			return fmt.Errorf("cannot create index for transaction %v: %w", string(key), err)
+		}
+		if err := s.deleteTransactionIndexKey(user, previousTransaction); err != nil {
+			return fmt.Errorf("cannot delete previous index for transaction %v: %w", string(key), err)
+		}
+	}
+	if err := s.deleteTransactionSecondaryIndexes(user, previousTransaction); err != nil {
+		return fmt.Errorf("cannot delete previous secondary indexes for transaction %v: %w", string(key), err)
+	}
+	if err := s.createTransactionSecondaryIndexes(user, transaction); err != nil {
+		return fmt.Errorf("cannot create secondary indexes for transaction %v: %w", string(key), err)
+	}
+
+	value, err := transaction.encode()
+	if err != nil {
+		return fmt.Errorf("cannot encode transaction: %w", err)
+	}
+	return s.db.Put(key, value)
+}
+
 // updateAccountsBalance updates account balance for a transaction.
 func (s *DBService) updateAccountsBalance(user *User, previousComponents *[]TransactionComponent, newComponents *[]TransactionComponent) error {
 	accountDeltas := make(map[string]int64)
@@ -285,6 +308,21 @@ func (s *DBService) updateAccountsBalance(user *User, previousComponents *[]Tran
 	return nil
 }
 
+// normalizeDates reformats the date range to the format used by Transaction dates.
+func (options *TransactionFilterOptions) normalizeDates() error {
+	for _, value := range []*string{&options.FilterFromDate, &options.FilterToDate} {
+		if *value == "" {
+			continue
+		}
+		date, err := time.Parse(inputDateFormat, *value)
+		if err != nil {
+			return fmt.Errorf("cannot parse date %v: %w", *value, err)
+		}
+		*value = date.Format(dateFormat)
+	}
+	return nil
+}
+
 // IsEmpty returns if options do not apply any filtering (all transactions match this filter).
 func (options *TransactionFilterOptions) IsEmpty() bool {
 	return options.FilterDescription == "" &&
@@ -352,11 +390,19 @@ func (s *DBService) getTransaction(user *User, transactionUUID string) (*Transac
 }
 
 // iterateTransactions will iterate transactions, following their sort order.
+// If start is not nil, iteration will start after the transaction referenced by start.
 // For each transaction, it will  call handleFn.
 // If doneFn returns true (or handleFn returns an error), iteration will stop.
-func (s *DBService) iterateTransactions(user *User,
+func (s *DBService) iterateTransactions(user *User, start *transactionCursor,
 	handleFn func(transactionUUID string) error,
 	doneFn func() bool) error {
+	var startYear, startMonth, startDay []byte
+	if start != nil {
+		startYear = make([]byte, 2)
+		binary.BigEndian.PutUint16(startYear, start.year)
+		startMonth, startDay = []byte{start.month}, []byte{start.day}
+	}
+
 	indexKey := []byte(user.createTransactionKeyPrefix())
 	years, err := s.getReferencedKeys(indexKey)
 	if err != nil {
@@ -364,6 +410,10 @@ func (s *DBService) iterateTransactions(user *User,
 	}
 	for i := len(years) - 1; i >= 0; i-- {
 		year := years[i]
+		if start != nil && bytes.Compare(year, startYear) > 0 {
+			continue
+		}
+		startInYear := start != nil && bytes.Equal(year, startYear)
 		monthsIndexKey := append(indexKey, year...)
 		months, err := s.getReferencedKeys(monthsIndexKey)
 		if err != nil {
@@ -372,6 +422,10 @@ func (s *DBService) iterateTransactions(user *User,
 
 		for j := len(months) - 1; j >= 0; j-- {
 			month := months[j]
+			if startInYear && bytes.Compare(month, startMonth) > 0 {
+				continue
+			}
+			startInMonth := startInYear && bytes.Equal(month, startMonth)
 			daysIndexKey := append(monthsIndexKey, month...)
 			days, err := s.getReferencedKeys(daysIndexKey)
 			if err != nil {
@@ -379,14 +433,25 @@ func (s *DBService) iterateTransactions(user *User,
 			}
 			for k := len(days) - 1; k >= 0; k-- {
 				day := days[k]
+				if startInMonth && bytes.Compare(day, startDay) > 0 {
+					continue
+				}
 				transactionsIndexKey := append(daysIndexKey, day...)
 				transactionKeys, err := s.getReferencedKeys(transactionsIndexKey)
 				if err != nil {
 					return fmt.Errorf("failed get transactions index: %w", err)
 				}
-				for l := len(transactionKeys) - 1; l >= 0; l-- {
+				if len(transactionKeys) == 0 {
+					// Empty index keys should have been deleted.
+					s.scheduleCleanup(user)
+				}
+				last := len(transactionKeys) - 1
+				if startInMonth && bytes.Equal(day, startDay) {
+					// Transactions are added to the end of the day, so earlier positions are not affected by inserts.
+					last = start.resolvePosition(transactionKeys) - 1
+				}
+				for l := last; l >= 0; l-- {
 					transactionUUID := transactionKeys[l]
-					// TODO: if transaction date matches the index date, schedule a cleanup for this user.
 					if err := handleFn(string(transactionUUID)); err != nil {
 						return err
 					}
@@ -404,6 +469,15 @@ func (s *DBService) iterateTransactions(user *User,
 func (s *DBService) getTransactions(user *User, options GetTransactionOptions) ([]*Transaction, error) {
 	transactions := make([]*Transaction, 0)
 
+	var start *transactionCursor
+	if options.Cursor != "" {
+		var err error
+		start, err = decodeTransactionCursor(options.Cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var currentItem uint64
 	skipItem := func() bool {
 		currentItem++
@@ -417,7 +491,7 @@ func (s *DBService) getTransactions(user *User, options GetTransactionOptions) (
 			return err
 		}
 		if transaction == nil {
-			// TODO: schedule a cleanup for this user.
+			s.scheduleCleanup(user)
 			return nil
 		}
 
@@ -434,18 +508,98 @@ func (s *DBService) getTransactions(user *User, options GetTransactionOptions) (
 	}
 	doneFn := func() bool { return uint64(len(transactions)) >= options.Limit }
 
-	if err := s.iterateTransactions(user, handleFn, doneFn); err != nil {
+	if err := s.iterateMatchingTransactions(user, &options.TransactionFilterOptions, start, handleFn, doneFn); err != nil {
 		return nil, err
 	}
 	return transactions, nil
 }
 
+// transactionPageSize is the number of transactions read at once by iterateTransactionPages.
+var transactionPageSize = 100
+
+// getMatchingTransactionUUIDs returns the UUIDs of transactions which might match options, in the same order as iterateTransactions.
+// The transactions still have to be checked with options.Matches.
+func (s *DBService) getMatchingTransactionUUIDs(user *User, options *TransactionFilterOptions) ([]string, error) {
+	transactionUUIDs := make([]string, 0)
+	handleFn := func(transactionUUID string) error {
+		transactionUUIDs = append(transactionUUIDs, transactionUUID)
+		return nil
+	}
+	doneFn := func() bool { return false }
+	if err := s.iterateMatchingTransactions(user, options, nil, handleFn, doneFn); err != nil {
+		return nil, err
+	}
+	return transactionUUIDs, nil
+}
+
+// iterateTransactionPages reads transactions in pages of transactionPageSize, each page in a separate view,
+// and calls handleFn for every page after its view is finished, so that slow writers don't block updates.
+// Transactions which were deleted after transactionUUIDs were read are skipped.
+// If checkFn is not nil, it's called in the view of every page before reading the page.
+func (s *DBService) iterateTransactionPages(user *User, transactionUUIDs []string, checkFn func() error, handleFn func([]*Transaction) error) error {
+	for start := 0; start < len(transactionUUIDs); start += transactionPageSize {
+		end := start + transactionPageSize
+		if end > len(transactionUUIDs) {
+			end = len(transactionUUIDs)
+		}
+		transactions := make([]*Transaction, 0, end-start)
+		err := s.view(user, func() error {
+			if checkFn != nil {
+				if err := checkFn(); err != nil {
+					return err
+				}
+			}
+			for _, transactionUUID := range transactionUUIDs[start:end] {
+				transaction, err := s.getTransaction(user, transactionUUID)
+				if err != nil {
+					return err
+				}
+				if transaction != nil {
+					transactions = append(transactions, transaction)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := handleFn(transactions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getTransactionsPage gets a page of transactions with the specified options, and the cursor for the next page.
+func (s *DBService) getTransactionsPage(user *User, options GetTransactionOptions) (*TransactionsPage, error) {
+	limit := options.Limit
+	if options.Limit < GetAllTransactionsOptions.Limit {
+		// Get one more transaction to check if there's a next page.
+		options.Limit++
+	}
+	transactions, err := s.getTransactions(user, options)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TransactionsPage{Transactions: transactions}
+	if limit > 0 && uint64(len(transactions)) > limit {
+		page.Transactions = transactions[:limit]
+		cursor, err := s.createTransactionCursor(user, transactions[limit-1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cursor: %w", err)
+		}
+		page.NextCursor = cursor.encode()
+	}
+	return page, nil
+}
+
 // GetTransaction returns a Transaction by its UUID.
 // If the Transaction doesn't exist, it returns nil.
 func (s *DBService) GetTransaction(user *User, transactionUUID string) (*Transaction, error) {
 	var transaction *Transaction
 
-	err := s.view(func() error {
+	err := s.view(user, func() error {
 		var err error
 		transaction, err = s.getTransaction(user, transactionUUID)
 		return err
@@ -461,7 +615,7 @@ func (s *DBService) GetTransaction(user *User, transactionUUID string) (*Transac
 func (s *DBService) GetTransactions(user *User, options GetTransactionOptions) ([]*Transaction, error) {
 	var transactions []*Transaction
 
-	err := s.view(func() error {
+	err := s.view(user, func() error {
 		var err error
 		transactions, err = s.getTransactions(user, options)
 		return err
@@ -472,12 +626,30 @@ func (s *DBService) GetTransactions(user *User, options GetTransactionOptions) (
 	return transactions, nil
 }
 
+// GetTransactionsPage returns a page of transactions for user matching the filter and paging options,
+// and the cursor to get the next page.
+// If options.Cursor is set, returns transactions after the cursor; otherwise, returns the first page.
+// A cursor with an invalid format is rejected with ErrInvalidCursor.
+func (s *DBService) GetTransactionsPage(user *User, options GetTransactionOptions) (*TransactionsPage, error) {
+	var page *TransactionsPage
+
+	err := s.view(user, func() error {
+		var err error
+		page, err = s.getTransactionsPage(user, options)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions page: %w", err)
+	}
+	return page, nil
+}
+
 // CountTransactions returns the number of transactions matching the filter options.
 func (s *DBService) CountTransactions(user *User, options TransactionFilterOptions) (uint64, error) {
 	var count uint64
 
 	emptyFilter := options.IsEmpty()
-	err := s.view(func() error {
+	err := s.view(user, func() error {
 		handleFn := func(transactionUUID string) error {
 			if emptyFilter {
 				transactionKey := user.createTransactionKeyFromUUID(transactionUUID)
@@ -486,7 +658,7 @@ func (s *DBService) CountTransactions(user *User, options TransactionFilterOptio
 					return err
 				}
 				if !exists {
-					// TODO: schedule a cleanup for this user.
+					s.scheduleCleanup(user)
 					return nil
 				}
 			} else {
@@ -495,7 +667,7 @@ func (s *DBService) CountTransactions(user *User, options TransactionFilterOptio
 					return err
 				}
 				if transaction == nil {
-					// TODO: schedule a cleanup for this user.
+					s.scheduleCleanup(user)
 					return nil
 				}
 				if !options.Matches(transaction) {
@@ -507,7 +679,7 @@ func (s *DBService) CountTransactions(user *User, options TransactionFilterOptio
 			return nil
 		}
 		doneFn := func() bool { return false }
-		return s.iterateTransactions(user, handleFn, doneFn)
+		return s.iterateMatchingTransactions(user, &options, nil, handleFn, doneFn)
 	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to count transactions: %w", err)
@@ -531,16 +703,20 @@ func (s *DBService) deleteTransactions(user *User) error {
 		if err := s.deleteTransactionIndexKey(user, transaction); err != nil {
 			return err
 		}
+		if err := s.deleteTransactionSecondaryIndexes(user, transaction); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// DeleteTransaction deletes a Transaction and its sort index key by its UUID.
+// DeleteTransaction deletes a Transaction and its index keys by its UUID.
 // Deleting a transaction also updates the affected Account balance.
 // If transaction doesn't exist, returns an error.
-func (s *DBService) DeleteTransaction(user *User, transactionUUID string) error {
+// Deleting a transaction with reconciled components is rejected with ErrReconciledComponent, unless overrideReconciled is true.
+func (s *DBService) DeleteTransaction(user *User, transactionUUID string, overrideReconciled bool) error {
 	key := user.createTransactionKeyFromUUID(transactionUUID)
-	return s.update(func() error {
+	return s.update(user, func(s *DBService) error {
 		value, err := s.db.Get(key)
 		if err != nil {
 			return fmt.Errorf("cannot get transaction to delete %v: %w", transactionUUID, err)
@@ -552,6 +728,13 @@ func (s *DBService) DeleteTransaction(user *User, transactionUUID string) error
 		if err := deleteTransaction.decode(value); err != nil {
 			return fmt.Errorf("cannot decode transaction %v to delete: %w", transactionUUID, err)
 		}
+		if !overrideReconciled {
+			for _, component := range deleteTransaction.Components {
+				if component.Status == ComponentStatusReconciled {
+					return fmt.Errorf("cannot delete transaction %v: %w", transactionUUID, ErrReconciledComponent)
+				}
+			}
+		}
 
 		if err := s.updateAccountsBalance(user, &deleteTransaction.Components, nil); err != nil {
 			return fmt.Errorf("cannot update accounts balance: %w", err)
@@ -560,6 +743,9 @@ func (s *DBService) DeleteTransaction(user *User, transactionUUID string) error
 		if err := s.deleteTransactionIndexKey(user, deleteTransaction); err != nil {
 			return fmt.Errorf("failed to delete transaction index: %w", err)
 		}
+		if err := s.deleteTransactionSecondaryIndexes(user, deleteTransaction); err != nil {
+			return fmt.Errorf("failed to delete transaction secondary indexes: %w", err)
+		}
 
 		return s.db.Delete(key)
 	})
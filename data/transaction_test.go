@@ -273,7 +273,7 @@ func TestUpdateTransactionNoComponents(t *testing.T) {
 	transaction2.Tags = []string{"t1", "t3", "t4"}
 	transaction2.Type = TransactionTypeTransfer
 	saveTransaction = transaction2
-	err = dbService.UpdateTransaction(&testUser, &saveTransaction)
+	err = dbService.UpdateTransaction(&testUser, &saveTransaction, false)
 	assert.NoError(t, err)
 
 	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
@@ -310,14 +310,14 @@ func TestDeleteTransactionNoComponents(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, saveTransaction.UUID)
 
-	err = dbService.DeleteTransaction(&testUser, transaction2.UUID)
+	err = dbService.DeleteTransaction(&testUser, transaction2.UUID, false)
 	assert.NoError(t, err)
 
 	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
 	assert.NoError(t, err)
 	assert.Equal(t, []*Transaction{&transaction1}, transactions)
 
-	err = dbService.DeleteTransaction(&testUser, transaction1.UUID)
+	err = dbService.DeleteTransaction(&testUser, transaction1.UUID, false)
 	assert.NoError(t, err)
 
 	transactions, err = dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
@@ -342,7 +342,7 @@ func TestDeleteNonExistingTransaction(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, saveTransaction.UUID)
 
-	err = dbService.DeleteTransaction(&testUser, "non-existing")
+	err = dbService.DeleteTransaction(&testUser, "non-existing", false)
 	assert.Error(t, err)
 
 	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
@@ -464,11 +464,11 @@ func TestUpdateTransactionWithComponents(t *testing.T) {
 	}
 
 	saveTransaction = transaction1
-	err = dbService.UpdateTransaction(&testUser, &saveTransaction)
+	err = dbService.UpdateTransaction(&testUser, &saveTransaction, false)
 	assert.NoError(t, err)
 
 	saveTransaction = transaction2
-	err = dbService.UpdateTransaction(&testUser, &saveTransaction)
+	err = dbService.UpdateTransaction(&testUser, &saveTransaction, false)
 	assert.NoError(t, err)
 
 	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
@@ -525,7 +525,7 @@ func TestDeleteTransactionWithComponents(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, saveTransaction.UUID)
 
-	err = dbService.DeleteTransaction(&testUser, transaction2.UUID)
+	err = dbService.DeleteTransaction(&testUser, transaction2.UUID, false)
 	assert.NoError(t, err)
 
 	transactions, err := dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
@@ -540,7 +540,7 @@ func TestDeleteTransactionWithComponents(t *testing.T) {
 	expectedAccount2.Balance = 2
 	assert.Equal(t, []*Account{&expectedAccount1, &expectedAccount2}, accounts)
 
-	err = dbService.DeleteTransaction(&testUser, transaction1.UUID)
+	err = dbService.DeleteTransaction(&testUser, transaction1.UUID, false)
 	assert.NoError(t, err)
 
 	transactions, err = dbService.GetTransactions(&testUser, GetAllTransactionsOptions)
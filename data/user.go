@@ -35,7 +35,7 @@ func (user *User) decode(val []byte) error {
 // If user doesn't exist, returns nil.
 func (s *DBService) GetUser(username string) (*User, error) {
 	user := &User{username: username}
-	err := s.view(func() error {
+	err := s.view(nil, func() error {
 		value, err := s.db.Get(user.createKey())
 		if err != nil {
 			return err
@@ -54,6 +54,7 @@ func (s *DBService) GetUser(username string) (*User, error) {
 }
 
 // SaveUser saves updates an existing user in the database.
+// User records are updated while holding the system lock, so that concurrent renames cannot take the same username.
 func (s *DBService) SaveUser(user *User) error {
 	if user.newUsername == "" {
 		user.newUsername = user.username
@@ -65,7 +66,7 @@ func (s *DBService) SaveUser(user *User) error {
 		user.UUID = uuid.NewString()
 	}
 
-	err := s.update(func() error {
+	err := s.update(nil, func(s *DBService) error {
 		// Check for username/id conflicts.
 		existingUserValue, err := s.db.Get(key)
 		if err != nil {
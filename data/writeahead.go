@@ -0,0 +1,330 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+
+	"github.com/akrylysov/pogreb"
+	log "github.com/sirupsen/logrus"
+)
+
+// itemIterator iterates over all stored items.
+// Next returns pogreb.ErrIterationDone when there are no more items.
+type itemIterator interface {
+	Next() (key []byte, value []byte, err error)
+}
+
+// store provides access to stored items.
+type store interface {
+	Get(key []byte) ([]byte, error)
+	Has(key []byte) (bool, error)
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	Items() itemIterator
+}
+
+// committedStore provides access to items committed to db.
+type committedStore struct {
+	*pogreb.DB
+}
+
+// Items returns an iterator over committed items.
+func (s committedStore) Items() itemIterator {
+	return s.DB.Items()
+}
+
+// walEntry is a write or delete recorded in the write-ahead journal.
+type walEntry struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// walHeader is the header of the write-ahead journal.
+// It's saved after all chunks of the journal were saved, so that a journal without a header can be discarded.
+type walHeader struct {
+	Chunks int
+}
+
+// walChunkSize is the approximate maximum size of keys and values in a journal chunk.
+var walChunkSize = 16 * 1024 * 1024
+
+// batch collects all writes done during an update, so that they can be applied atomically.
+// Reads, including Items, return the pending values.
+type batch struct {
+	db      *pogreb.DB
+	writes  map[string][]byte
+	deletes map[string]bool
+	// size is the total size of pending keys and values.
+	size int
+}
+
+// newBatch creates a batch on top of db.
+func newBatch(db *pogreb.DB) *batch {
+	return &batch{db: db, writes: make(map[string][]byte), deletes: make(map[string]bool)}
+}
+
+// reset discards all pending writes and deletes, for example after they were committed.
+func (b *batch) reset() {
+	b.writes = make(map[string][]byte)
+	b.deletes = make(map[string]bool)
+	b.size = 0
+}
+
+// Get returns the pending or committed value for key.
+func (b *batch) Get(key []byte) ([]byte, error) {
+	if b.deletes[string(key)] {
+		return nil, nil
+	}
+	if value, ok := b.writes[string(key)]; ok {
+		return append([]byte{}, value...), nil
+	}
+	return b.db.Get(key)
+}
+
+// Has returns true if key has a pending or committed value.
+func (b *batch) Has(key []byte) (bool, error) {
+	if b.deletes[string(key)] {
+		return false, nil
+	}
+	if _, ok := b.writes[string(key)]; ok {
+		return true, nil
+	}
+	return b.db.Has(key)
+}
+
+// removePending removes a pending write or delete of key.
+func (b *batch) removePending(key []byte) {
+	if value, ok := b.writes[string(key)]; ok {
+		b.size -= len(key) + len(value)
+		delete(b.writes, string(key))
+	} else if b.deletes[string(key)] {
+		b.size -= len(key)
+		delete(b.deletes, string(key))
+	}
+}
+
+// Put adds a pending write of value for key.
+func (b *batch) Put(key []byte, value []byte) error {
+	b.removePending(key)
+	b.writes[string(key)] = append([]byte{}, value...)
+	b.size += len(key) + len(value)
+	return nil
+}
+
+// Delete adds a pending delete of key.
+func (b *batch) Delete(key []byte) error {
+	b.removePending(key)
+	b.deletes[string(key)] = true
+	b.size += len(key)
+	return nil
+}
+
+// Items returns an iterator over committed items merged with pending writes and deletes.
+// Committed items are returned first, followed by new items sorted by key.
+func (b *batch) Items() itemIterator {
+	return &batchIterator{b: b, committed: b.db.Items(), committedKeys: make(map[string]bool)}
+}
+
+// batchIterator iterates over committed items merged with the pending writes and deletes of a batch.
+type batchIterator struct {
+	b             *batch
+	committed     *pogreb.ItemIterator
+	committedKeys map[string]bool
+	newKeys       []string
+}
+
+// Next returns the next item.
+func (it *batchIterator) Next() ([]byte, []byte, error) {
+	for it.committed != nil {
+		key, value, err := it.committed.Next()
+		if err == pogreb.ErrIterationDone {
+			it.committed = nil
+			it.newKeys = make([]string, 0)
+			for key := range it.b.writes {
+				if !it.committedKeys[key] {
+					it.newKeys = append(it.newKeys, key)
+				}
+			}
+			sort.Strings(it.newKeys)
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if it.b.deletes[string(key)] {
+			continue
+		}
+		if pendingValue, ok := it.b.writes[string(key)]; ok {
+			it.committedKeys[string(key)] = true
+			return key, append([]byte{}, pendingValue...), nil
+		}
+		return key, value, nil
+	}
+
+	if len(it.newKeys) == 0 {
+		return nil, nil, pogreb.ErrIterationDone
+	}
+	key := it.newKeys[0]
+	it.newKeys = it.newKeys[1:]
+	return []byte(key), append([]byte{}, it.b.writes[key]...), nil
+}
+
+// entries returns all pending writes and deletes, sorted by key.
+func (b *batch) entries() []walEntry {
+	entries := make([]walEntry, 0, len(b.writes)+len(b.deletes))
+	for key, value := range b.writes {
+		entries = append(entries, walEntry{Key: []byte(key), Value: value})
+	}
+	for key := range b.deletes {
+		entries = append(entries, walEntry{Key: []byte(key), Delete: true})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Key, entries[j].Key) < 0
+	})
+	return entries
+}
+
+// applyWALEntries writes entries into db.
+func applyWALEntries(db *pogreb.DB, entries []walEntry) error {
+	for _, entry := range entries {
+		if entry.Delete {
+			if err := db.Delete(entry.Key); err != nil {
+				return fmt.Errorf("failed to delete %v: %w", string(entry.Key), err)
+			}
+		} else if err := db.Put(entry.Key, entry.Value); err != nil {
+			return fmt.Errorf("failed to write %v: %w", string(entry.Key), err)
+		}
+	}
+	return nil
+}
+
+// writeJournal saves entries to the journal, split into chunks of at most walChunkSize bytes.
+// The header is saved last, after all chunks were synced.
+func writeJournal(db *pogreb.DB, entries []walEntry) (int, error) {
+	chunks := 0
+	for start := 0; start < len(entries); chunks++ {
+		end, size := start, 0
+		for end < len(entries) && (end == start || size+len(entries[end].Key)+len(entries[end].Value) <= walChunkSize) {
+			size += len(entries[end].Key) + len(entries[end].Value)
+			end++
+		}
+
+		var value bytes.Buffer
+		if err := gob.NewEncoder(&value).Encode(entries[start:end]); err != nil {
+			return chunks, fmt.Errorf("cannot encode journal chunk: %w", err)
+		}
+		if err := db.Put(createJournalChunkKey(chunks), value.Bytes()); err != nil {
+			return chunks, fmt.Errorf("cannot write journal chunk: %w", err)
+		}
+		start = end
+	}
+	if err := db.Sync(); err != nil {
+		return chunks, fmt.Errorf("cannot sync journal: %w", err)
+	}
+
+	var value bytes.Buffer
+	if err := gob.NewEncoder(&value).Encode(walHeader{Chunks: chunks}); err != nil {
+		return chunks, fmt.Errorf("cannot encode journal header: %w", err)
+	}
+	if err := db.Put([]byte(journalKey), value.Bytes()); err != nil {
+		return chunks, fmt.Errorf("cannot write journal header: %w", err)
+	}
+	if err := db.Sync(); err != nil {
+		return chunks, fmt.Errorf("cannot sync journal: %w", err)
+	}
+	return chunks, nil
+}
+
+// readJournalChunk returns the entries saved in a journal chunk.
+func readJournalChunk(db *pogreb.DB, chunk int) ([]walEntry, error) {
+	value, err := db.Get(createJournalChunkKey(chunk))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read journal chunk %v: %w", chunk, err)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("journal chunk %v is missing", chunk)
+	}
+	entries := make([]walEntry, 0)
+	if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("cannot decode journal chunk %v: %w", chunk, err)
+	}
+	return entries, nil
+}
+
+// deleteJournal deletes the journal header and then all chunks of the journal.
+// If chunks is negative, all chunks are deleted until a missing chunk is found.
+func deleteJournal(db *pogreb.DB, chunks int) error {
+	if err := db.Delete([]byte(journalKey)); err != nil {
+		return fmt.Errorf("cannot delete journal: %w", err)
+	}
+	for chunk := 0; chunks < 0 || chunk < chunks; chunk++ {
+		key := createJournalChunkKey(chunk)
+		if chunks < 0 {
+			exists, err := db.Has(key)
+			if err != nil {
+				return fmt.Errorf("cannot check if journal chunk %v exists: %w", chunk, err)
+			}
+			if !exists {
+				break
+			}
+		}
+		if err := db.Delete(key); err != nil {
+			return fmt.Errorf("cannot delete journal chunk %v: %w", chunk, err)
+		}
+	}
+	return nil
+}
+
+// commit applies all writes from b to db.
+// The writes are first saved to the journal, so that they can be replayed if applying them is interrupted.
+func commit(db *pogreb.DB, b *batch) error {
+	entries := b.entries()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	chunks, err := writeJournal(db, entries)
+	if err != nil {
+		if deleteErr := deleteJournal(db, chunks); deleteErr != nil {
+			log.WithError(deleteErr).Error("Failed to delete incomplete journal")
+		}
+		return err
+	}
+
+	if err := applyWALEntries(db, entries); err != nil {
+		return fmt.Errorf("failed to apply journal: %w", err)
+	}
+	return deleteJournal(db, chunks)
+}
+
+// recoverJournal completes a commit that was interrupted, for example by a crash.
+// A journal without a readable header was not completely written, and is discarded
+// as none of its writes were applied yet.
+func recoverJournal(db *pogreb.DB) error {
+	value, err := db.Get([]byte(journalKey))
+	if err != nil {
+		return fmt.Errorf("cannot read journal: %w", err)
+	}
+	header := walHeader{}
+	if value == nil {
+		// Delete chunks of an incomplete journal, if there are any.
+		return deleteJournal(db, -1)
+	} else if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&header); err != nil {
+		log.WithError(err).Warn("Rolling back incomplete journal")
+		return deleteJournal(db, -1)
+	}
+
+	log.WithField("chunks", header.Chunks).Warn("Replaying journal")
+	for chunk := 0; chunk < header.Chunks; chunk++ {
+		entries, err := readJournalChunk(db, chunk)
+		if err != nil {
+			return fmt.Errorf("failed to replay journal: %w", err)
+		}
+		if err := applyWALEntries(db, entries); err != nil {
+			return fmt.Errorf("failed to replay journal: %w", err)
+		}
+	}
+	return deleteJournal(db, header.Chunks)
+}
@@ -0,0 +1,287 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+
+	"github.com/akrylysov/pogreb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateCommit(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = dbService.storage.Put([]byte("k2"), []byte("v2"))
+	assert.NoError(t, err)
+
+	err = dbService.update(&testUser, func(s *DBService) error {
+		if err := s.db.Put([]byte("k1"), []byte("v1")); err != nil {
+			return err
+		}
+		if err := s.db.Delete([]byte("k2")); err != nil {
+			return err
+		}
+
+		// Pending writes are visible inside the update, but not outside.
+		value, err := s.db.Get([]byte("k1"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("v1"), value)
+		exists, err := s.db.Has([]byte("k2"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		exists, err = dbService.storage.Has([]byte("k1"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	value, err := dbService.db.Get([]byte("k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), value)
+	exists, err := dbService.db.Has([]byte("k2"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = dbService.db.Has([]byte(journalKey))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestUpdateRollback(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = createTestAccounts(dbService)
+	assert.NoError(t, err)
+
+	err = dbService.update(&testUser, func(s *DBService) error {
+		if err := s.deleteAccounts(&testUser); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed")
+	})
+	assert.EqualError(t, err, "failed")
+
+	accounts, err := dbService.GetAccounts(&testUser)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+}
+
+func TestUpdateCommitFailed(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+	defer func() { dbService.journalPending = false }()
+
+	// Keys which are too long are saved in the journal, but cannot be applied.
+	longKey := bytes.Repeat([]byte("k"), pogreb.MaxKeyLength+1)
+	err = dbService.update(&testUser, func(s *DBService) error {
+		if err := s.db.Put([]byte("k1"), []byte("v1")); err != nil {
+			return err
+		}
+		return s.db.Put(longKey, []byte("v2"))
+	})
+	assert.Error(t, err)
+
+	// The journal cannot be replayed, so later commits are refused instead of overwriting it.
+	err = dbService.update(&testUser, func(s *DBService) error {
+		return s.db.Put([]byte("k3"), []byte("v3"))
+	})
+	assert.ErrorContains(t, err, "cannot complete previous commit")
+	exists, err := dbService.db.Has([]byte(journalKey))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = dbService.db.Has([]byte("k3"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// Once the journal can be replayed, it's completed before the next commit.
+	writeTestJournal(t, []walEntry{{Key: []byte("k1"), Value: []byte("v1")}, {Key: []byte("k2"), Value: []byte("v2")}})
+	err = dbService.update(&testUser, func(s *DBService) error {
+		return s.db.Put([]byte("k3"), []byte("v3"))
+	})
+	assert.NoError(t, err)
+	for _, key := range []string{"k1", "k2", "k3"} {
+		exists, err := dbService.db.Has([]byte(key))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	}
+	assertJournalDeleted(t)
+	assert.False(t, dbService.journalPending)
+}
+
+func TestJournalChunks(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	previousChunkSize := walChunkSize
+	walChunkSize = 10
+	defer func() { walChunkSize = previousChunkSize }()
+
+	entries := make([]walEntry, 0, 5)
+	for i := 0; i < 5; i++ {
+		entries = append(entries, walEntry{Key: []byte(fmt.Sprintf("k%v", i)), Value: []byte("value")})
+	}
+	chunks, err := writeJournal(dbService.storage, entries)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, chunks)
+	for i := 0; i < chunks; i++ {
+		chunkEntries, err := readJournalChunk(dbService.storage, i)
+		assert.NoError(t, err)
+		assert.Equal(t, entries[i:i+1], chunkEntries)
+	}
+
+	err = recoverJournal(dbService.storage)
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		value, err := dbService.db.Get([]byte(fmt.Sprintf("k%v", i)))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), value)
+	}
+	assertJournalDeleted(t)
+
+	// Updates larger than a chunk are committed.
+	err = dbService.update(&testUser, func(s *DBService) error {
+		for i := 0; i < 5; i++ {
+			if err := s.db.Put([]byte(fmt.Sprintf("k%v", i)), []byte("updated value")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		value, err := dbService.db.Get([]byte(fmt.Sprintf("k%v", i)))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("updated value"), value)
+	}
+	assertJournalDeleted(t)
+}
+
+func TestBatchItems(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	for _, key := range []string{"k1", "k2", "k3"} {
+		err = dbService.storage.Put([]byte(key), []byte("committed"))
+		assert.NoError(t, err)
+	}
+
+	err = dbService.update(&testUser, func(s *DBService) error {
+		assert.NoError(t, s.db.Put([]byte("k2"), []byte("pending")))
+		assert.NoError(t, s.db.Delete([]byte("k3")))
+		assert.NoError(t, s.db.Put([]byte("k5"), []byte("new")))
+		assert.NoError(t, s.db.Put([]byte("k4"), []byte("new")))
+
+		items := make(map[string]string)
+		newKeys := make([]string, 0)
+		it := s.db.Items()
+		for {
+			key, value, err := it.Next()
+			if err == pogreb.ErrIterationDone {
+				break
+			}
+			assert.NoError(t, err)
+			items[string(key)] = string(value)
+			if string(value) == "new" {
+				newKeys = append(newKeys, string(key))
+			}
+		}
+		assert.Equal(t, map[string]string{"k1": "committed", "k2": "pending", "k4": "new", "k5": "new"}, items)
+		assert.Equal(t, []string{"k4", "k5"}, newKeys)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+// writeTestJournal saves a journal header and chunks, as if a commit was interrupted.
+func writeTestJournal(t *testing.T, chunks ...[]walEntry) {
+	for i, entries := range chunks {
+		var chunk bytes.Buffer
+		err := gob.NewEncoder(&chunk).Encode(entries)
+		assert.NoError(t, err)
+		err = dbService.storage.Put(createJournalChunkKey(i), chunk.Bytes())
+		assert.NoError(t, err)
+	}
+	var header bytes.Buffer
+	err := gob.NewEncoder(&header).Encode(walHeader{Chunks: len(chunks)})
+	assert.NoError(t, err)
+	err = dbService.storage.Put([]byte(journalKey), header.Bytes())
+	assert.NoError(t, err)
+}
+
+// assertJournalDeleted checks that the journal header and chunks were deleted.
+func assertJournalDeleted(t *testing.T) {
+	for _, key := range [][]byte{[]byte(journalKey), createJournalChunkKey(0), createJournalChunkKey(1)} {
+		exists, err := dbService.db.Has(key)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	}
+}
+
+func TestRecoverJournal(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = dbService.storage.Put([]byte("k2"), []byte("v2"))
+	assert.NoError(t, err)
+
+	// Simulate a crash after the journal was saved, but before it was applied.
+	writeTestJournal(t,
+		[]walEntry{{Key: []byte("k1"), Value: []byte("v1")}},
+		[]walEntry{{Key: []byte("k2"), Delete: true}},
+	)
+
+	err = recoverJournal(dbService.storage)
+	assert.NoError(t, err)
+
+	value, err := dbService.db.Get([]byte("k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), value)
+	exists, err := dbService.db.Has([]byte("k2"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assertJournalDeleted(t)
+}
+
+func TestRecoverIncompleteJournal(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	err = dbService.storage.Put([]byte("k2"), []byte("v2"))
+	assert.NoError(t, err)
+	err = dbService.storage.Put([]byte(journalKey), []byte("incomplete"))
+	assert.NoError(t, err)
+
+	err = recoverJournal(dbService.storage)
+	assert.NoError(t, err)
+
+	value, err := dbService.db.Get([]byte("k2"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v2"), value)
+	assertJournalDeleted(t)
+}
+
+func TestRecoverJournalWithoutHeader(t *testing.T) {
+	err := resetDb()
+	assert.NoError(t, err)
+
+	// Simulate a crash after some chunks were saved, but before the header was saved.
+	var chunk bytes.Buffer
+	err = gob.NewEncoder(&chunk).Encode([]walEntry{{Key: []byte("k1"), Value: []byte("v1")}})
+	assert.NoError(t, err)
+	err = dbService.storage.Put(createJournalChunkKey(0), chunk.Bytes())
+	assert.NoError(t, err)
+	err = dbService.storage.Put(createJournalChunkKey(1), []byte("incomplete"))
+	assert.NoError(t, err)
+
+	err = recoverJournal(dbService.storage)
+	assert.NoError(t, err)
+
+	exists, err := dbService.db.Has([]byte("k1"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assertJournalDeleted(t)
+}
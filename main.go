@@ -1,10 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	log "github.com/sirupsen/logrus"
@@ -26,13 +28,17 @@ func serve(db *data.DBService) {
 		return
 	}
 
+	done := make(chan struct{})
+	defer close(done)
+	server.StartScheduler(services, done)
+
 	errs := make(chan error, 2)
 	go func() {
 		errs <- http.ListenAndServe(":8080", router)
 	}()
 
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		errs <- fmt.Errorf("%s", <-c)
 	}()
@@ -40,6 +46,277 @@ func serve(db *data.DBService) {
 	<-errs
 }
 
+// getUser returns the user with username.
+func getUser(db *data.DBService, username string) (*data.User, error) {
+	user, err := db.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %v doesn't exist", username)
+	}
+	return user, nil
+}
+
+// logImportResult prints the outcome of an import.
+func logImportResult(result *data.ImportResult) {
+	for _, skipped := range result.Skipped {
+		log.WithField("reason", skipped).Warn("Skipped entry")
+	}
+	for _, warning := range result.Warnings {
+		log.WithField("warning", warning).Warn("Import warning")
+	}
+	log.WithField("imported", result.Imported).
+		WithField("alreadyImported", result.AlreadyImported).
+		WithField("skipped", len(result.Skipped)).
+		Info("Import completed")
+}
+
+// importOFX imports an OFX or QFX statement file.
+func importOFX(db *data.DBService, args []string) error {
+	flags := flag.NewFlagSet("import-ofx", flag.ExitOnError)
+	username := flags.String("username", "", "username of the user who owns the account")
+	accountUUID := flags.String("account", "", "UUID of the account to import into")
+	duplicatesAction := flags.String("duplicates", "ignore", "action for likely duplicates of existing transactions: ignore, flag or skip")
+	duplicateDateWindow := flags.Int("duplicate-window", data.DefaultDuplicateDateWindow, "maximum number of days between duplicate transactions")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: import-ofx -username <username> -account <uuid> [-duplicates <action>] [-duplicate-window <days>] <file>")
+	}
+	action, err := data.ParseDuplicateAction(*duplicatesAction)
+	if err != nil {
+		return err
+	}
+	duplicates := data.DuplicateOptions{Action: action, DateWindow: *duplicateDateWindow}
+
+	user, err := getUser(db, *username)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, err := db.ImportOFX(user, *accountUUID, duplicates, file)
+	if err != nil {
+		return err
+	}
+	logImportResult(result)
+	return nil
+}
+
+// importRates imports an exchange rates file, such as the ECB eurofxref-hist.xml.
+// Rates are only read from the file; nothing is downloaded.
+func importRates(db *data.DBService, args []string) error {
+	flags := flag.NewFlagSet("import-rates", flag.ExitOnError)
+	username := flags.String("username", "", "username of the user who owns the exchange rates")
+	format := flags.String("format", "ecb", "file format: ecb or csv")
+	base := flags.String("base", "", "base currency for Date,Currency,Rate CSV files")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: import-rates -username <username> [-format <format>] [-base <currency>] <file>")
+	}
+
+	user, err := getUser(db, *username)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var result *data.ImportResult
+	switch *format {
+	case "ecb":
+		result, err = db.ImportExchangeRatesECB(user, file)
+	case "csv":
+		result, err = db.ImportExchangeRatesCSV(user, *base, file)
+	default:
+		return fmt.Errorf("unsupported exchange rates format %v", *format)
+	}
+	if err != nil {
+		return err
+	}
+	logImportResult(result)
+	return nil
+}
+
+// exportJournal writes a ledger, hledger or beancount journal.
+func exportJournal(db *data.DBService, args []string) error {
+	flags := flag.NewFlagSet("export-journal", flag.ExitOnError)
+	username := flags.String("username", "", "username of the user whose data is exported")
+	format := flags.String("format", data.JournalFormatLedger, "journal format: ledger, hledger or beancount")
+	fromDate := flags.String("from", "", "export transactions starting from this date (YYYY-MM-DD)")
+	toDate := flags.String("to", "", "export transactions up to this date (YYYY-MM-DD)")
+	output := flags.String("output", "", "output file (default is stdout)")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: export-journal -username <username> [-format <format>] [-from <date>] [-to <date>] [-output <file>]")
+	}
+
+	user, err := getUser(db, *username)
+	if err != nil {
+		return err
+	}
+
+	writer := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	options := data.TransactionFilterOptions{FilterFromDate: *fromDate, FilterToDate: *toDate}
+	return db.ExportJournal(user, *format, options, writer)
+}
+
+// readPassphrase returns the passphrase from the first line of filename.
+// An empty filename means no passphrase.
+func readPassphrase(filename string) (string, error) {
+	if filename == "" {
+		return "", nil
+	}
+	value, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	passphrase, _, _ := strings.Cut(string(value), "\n")
+	passphrase = strings.TrimSuffix(passphrase, "\r")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %v is empty", filename)
+	}
+	return passphrase, nil
+}
+
+// backup writes a backup of all data for a user.
+func backup(db *data.DBService, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	username := flags.String("username", "", "username of the user whose data is backed up")
+	compress := flags.Bool("compress", false, "compress the backup with gzip")
+	passphraseFile := flags.String("passphrase-file", "", "encrypt the backup with the passphrase from this file")
+	output := flags.String("output", "", "output file (default is stdout)")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: backup -username <username> [-compress] [-passphrase-file <file>] [-output <file>]")
+	}
+
+	passphrase, err := readPassphrase(*passphraseFile)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(db, *username)
+	if err != nil {
+		return err
+	}
+
+	writer := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	return db.Backup(user, writer, data.BackupOptions{Compress: *compress, Passphrase: passphrase})
+}
+
+// restore restores a backup into the data of a user.
+func restore(db *data.DBService, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	username := flags.String("username", "", "username of the user whose data is restored")
+	mode := flags.String("mode", "replace", "restore mode: replace or merge")
+	conflicts := flags.String("conflicts", "existing", "version to keep when merging changed records: existing or backup")
+	dryRun := flags.Bool("dry-run", false, "check the backup without saving any changes")
+	passphraseFile := flags.String("passphrase-file", "", "decrypt the backup with the passphrase from this file")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: restore -username <username> [-mode <mode>] [-conflicts <version>] [-dry-run] [-passphrase-file <file>] <file>")
+	}
+
+	options := data.RestoreOptions{DryRun: *dryRun}
+	var err error
+	if options.Mode, err = data.ParseRestoreMode(*mode); err != nil {
+		return err
+	}
+	if options.Conflicts, err = data.ParseRestoreConflict(*conflicts); err != nil {
+		return err
+	}
+	if options.Passphrase, err = readPassphrase(*passphraseFile); err != nil {
+		return err
+	}
+
+	user, err := getUser(db, *username)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, err := db.Restore(user, file, options)
+	if err != nil {
+		return err
+	}
+	if result.Diff != nil {
+		for _, problem := range result.Diff.Problems {
+			log.WithField("problem", problem).Warn("Found problem")
+		}
+		log.WithField("addedAccounts", len(result.Diff.AddedAccounts)).
+			WithField("removedAccounts", len(result.Diff.RemovedAccounts)).
+			WithField("changedAccounts", len(result.Diff.ChangedAccounts)).
+			WithField("addedTransactions", len(result.Diff.AddedTransactions)).
+			WithField("removedTransactions", len(result.Diff.RemovedTransactions)).
+			WithField("changedTransactions", len(result.Diff.ChangedTransactions)).
+			Info("Restore changes")
+	}
+	log.WithField("inserted", result.Inserted).
+		WithField("updated", result.Updated).
+		WithField("skipped", result.Skipped).
+		WithField("dryRun", options.DryRun).
+		Info("Restore completed")
+	return nil
+}
+
+// fsck checks and optionally repairs account balances and transaction indexes.
+func fsck(db *data.DBService, args []string) error {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "repair detected problems")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: fsck [-repair]")
+	}
+
+	report, err := db.Fsck(*repair)
+	if err != nil {
+		return err
+	}
+	for _, problem := range report.Problems {
+		log.WithField("problem", problem).Warn("Found problem")
+	}
+	log.WithField("users", report.Users).
+		WithField("accounts", report.Accounts).
+		WithField("transactions", report.Transactions).
+		WithField("problems", len(report.Problems)).
+		WithField("repaired", report.Repaired).
+		Info("Consistency check completed")
+	return nil
+}
+
 func main() {
 	// Init data layer
 	db, err := data.Open(data.DefaultOptions())
@@ -52,9 +329,26 @@ func main() {
 	if len(os.Args) < 2 || os.Args[1] == "serve" {
 		serve(db)
 	} else {
+		var err error
 		switch directive := os.Args[1]; directive {
+		case "import-ofx":
+			err = importOFX(db, os.Args[2:])
+		case "import-rates":
+			err = importRates(db, os.Args[2:])
+		case "fsck":
+			err = fsck(db, os.Args[2:])
+		case "export-journal":
+			err = exportJournal(db, os.Args[2:])
+		case "backup":
+			err = backup(db, os.Args[2:])
+		case "restore":
+			err = restore(db, os.Args[2:])
 		default:
 			log.Fatalf("Unrecognized directive %v", directive)
 		}
+		if err != nil {
+			db.Close()
+			log.Fatalf("Failed to run directive: %v", err)
+		}
 	}
 }
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zlogic/vogon-go/data"
+	"github.com/zlogic/vogon-go/server/auth"
+)
+
+// budgetPeriodProgress is the budget and the actual spending in a budget period.
+type budgetPeriodProgress struct {
+	From string
+	To   string
+	// Budget is the budget amount, including the amount rolled over from the previous period.
+	Budget    int64
+	Actual    int64
+	Remaining int64
+}
+
+// budgetProgress is the budget and the actual spending in every period of a Budget.
+type budgetProgress struct {
+	Budget  *data.Budget
+	Periods []budgetPeriodProgress
+}
+
+// budgetDateFormat is the format of dates in budget periods.
+const budgetDateFormat = "2006-01-02"
+
+// budgetInputDateFormat is the format of the from and to form values, which can omit leading zeros.
+const budgetInputDateFormat = "2006-1-2"
+
+// normalizeBudgetDate parses a date form value and formats it with budgetDateFormat,
+// so that it can be compared with transaction dates.
+func normalizeBudgetDate(value string) (string, error) {
+	date, err := time.Parse(budgetInputDateFormat, value)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse date %v: %w", value, err)
+	}
+	return date.Format(budgetDateFormat), nil
+}
+
+// budgetActual returns the amount spent on budget by expense/income transactions.
+// Income (such as refunds) is subtracted from expenses with the same tags;
+// tags with more income than expenses are not included.
+func budgetActual(budget *data.Budget, transactions []*data.Transaction, accounts []*data.Account) int64 {
+	matchingTransactions := make([]*data.Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		if budget.Matches(transaction.Tags) {
+			matchingTransactions = append(matchingTransactions, transaction)
+		}
+	}
+
+	var actual int64
+	amounts := createTagsChart(matchingTransactions, accounts)[budget.Currency]
+	for tags, amount := range amounts.Negative {
+		if amount > amounts.Positive[tags] {
+			actual += amount - amounts.Positive[tags]
+		}
+	}
+	return actual
+}
+
+// budgetPeriods returns all periods of budget which include dates between from and to.
+// If budget has a rollover, periods start from the budget StartDate, so that amounts can be rolled over.
+func budgetPeriods(budget *data.Budget, from, to string) ([]data.BudgetPeriod, error) {
+	firstDate := from
+	if budget.Rollover && budget.StartDate != "" && budget.StartDate < from {
+		firstDate = budget.StartDate
+	}
+	return budget.Periods(firstDate, to)
+}
+
+// createBudgetProgress returns the budget and the actual spending for periods returned by budgetPeriods.
+// Periods ending before from are only used to calculate the rollover.
+func createBudgetProgress(budget *data.Budget, periods []data.BudgetPeriod, transactions []*data.Transaction, accounts []*data.Account, from string) budgetProgress {
+	progress := budgetProgress{Budget: budget, Periods: make([]budgetPeriodProgress, 0)}
+
+	var rollover int64
+	for _, period := range periods {
+		periodTransactions := make([]*data.Transaction, 0)
+		for _, transaction := range transactions {
+			if period.From <= transaction.Date && transaction.Date <= period.To {
+				periodTransactions = append(periodTransactions, transaction)
+			}
+		}
+		periodProgress := budgetPeriodProgress{
+			From:   period.From,
+			To:     period.To,
+			Budget: budget.Amount + rollover,
+			Actual: budgetActual(budget, periodTransactions, accounts),
+		}
+		periodProgress.Remaining = periodProgress.Budget - periodProgress.Actual
+
+		rollover = 0
+		if budget.Rollover && periodProgress.Remaining > 0 {
+			rollover = periodProgress.Remaining
+		}
+		if period.To < from {
+			continue
+		}
+		progress.Periods = append(progress.Periods, periodProgress)
+	}
+	return progress
+}
+
+// BudgetsHandler returns all Budgets for an authenticated user.
+func BudgetsHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		budgets, err := s.db.GetBudgets(user)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(budgets); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// BudgetHandler gets, updates or deletes a Budget.
+func BudgetHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		requestUUID := chi.URLParam(r, "uuid")
+
+		if r.Method == http.MethodPost {
+			budget := &data.Budget{}
+
+			err := json.NewDecoder(r.Body).Decode(&budget)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			if requestUUID == "new" {
+				err = s.db.CreateBudget(user, budget)
+			} else {
+				err = s.db.UpdateBudget(user, budget)
+			}
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			w.Header().Add("Content-Type", "text/plain")
+			if _, err := io.WriteString(w, "OK"); err != nil {
+				log.WithError(err).Error("Failed to write response")
+			}
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if err := s.db.DeleteBudget(user, requestUUID); err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			w.Header().Add("Content-Type", "text/plain")
+			if _, err := io.WriteString(w, "OK"); err != nil {
+				log.WithError(err).Error("Failed to write response")
+			}
+			return
+		}
+
+		budget, err := s.db.GetBudget(user, requestUUID)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		if budget == nil {
+			handleNotFound(w, r, requestUUID)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(budget); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// BudgetProgressHandler returns the budget and the actual spending of all Budgets for an authenticated user.
+// The from and to form values specify the date range; by default, only the current period is returned.
+func BudgetProgressHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		to := time.Now().Format(budgetDateFormat)
+		if value := r.Form.Get("to"); value != "" {
+			var err error
+			to, err = normalizeBudgetDate(value)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+		}
+		from := to
+		if value := r.Form.Get("from"); value != "" {
+			var err error
+			from, err = normalizeBudgetDate(value)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+		}
+
+		budgets, err := s.db.GetBudgets(user)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		// Only load transactions from the first to the last period of all budgets.
+		options := data.GetAllTransactionsOptions
+		periods := make([][]data.BudgetPeriod, len(budgets))
+		for i, budget := range budgets {
+			periods[i], err = budgetPeriods(budget, from, to)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+			if len(periods[i]) == 0 {
+				continue
+			}
+			if first := periods[i][0].From; options.FilterFromDate == "" || first < options.FilterFromDate {
+				options.FilterFromDate = first
+			}
+			if last := periods[i][len(periods[i])-1].To; last > options.FilterToDate {
+				options.FilterToDate = last
+			}
+		}
+
+		transactions := make([]*data.Transaction, 0)
+		if options.FilterFromDate != "" {
+			transactions, err = s.db.GetTransactions(user, options)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+		}
+
+		accounts, err := s.db.GetAccounts(user)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		progress := make([]budgetProgress, len(budgets))
+		for i, budget := range budgets {
+			progress[i] = createBudgetProgress(budget, periods[i], transactions, accounts, from)
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(progress); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zlogic/vogon-go/data"
+)
+
+func createTestBudget() *data.Budget {
+	return &data.Budget{
+		UUID:      "uuid1",
+		Name:      "Widgets",
+		Tags:      []string{"Widgets"},
+		Currency:  "USD",
+		Amount:    10000,
+		Period:    data.BudgetPeriodMonthly,
+		Rollover:  true,
+		StartDate: "2015-10-01",
+	}
+}
+
+const testBudgetJSON = `{"UUID":"uuid1","Name":"Widgets","Tags":["Widgets"],"Currency":"USD","Amount":10000,"Period":1,"Rollover":true,"StartDate":"2015-10-01"}`
+
+func TestGetBudgetsAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/budgets", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetBudgets", &user).Return([]*data.Budget{createTestBudget()}, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "["+testBudgetJSON+"]\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetBudgetAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/budgets/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetBudget", &user, "uuid1").Return(createTestBudget(), nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, testBudgetJSON+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetBudgetDoesNotExistAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/budgets/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetBudget", &user, "uuid1").Return(nil, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, "Not found\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestCreateBudgetAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/budgets/new", strings.NewReader(testBudgetJSON))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("CreateBudget", &user, createTestBudget()).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestUpdateBudgetAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/budgets/uuid1", strings.NewReader(testBudgetJSON))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("UpdateBudget", &user, createTestBudget()).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestDeleteBudgetAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("DELETE", "/api/budgets/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("DeleteBudget", &user, "uuid1").Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestBudgetProgressAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/budgets/progress", strings.NewReader("from=2015-11-01&to=2015-11-30"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	budgets := []*data.Budget{
+		createTestBudget(),
+		{UUID: "uuid2", Name: "Everything", Currency: "EUR", Amount: 5000, Period: data.BudgetPeriodMonthly},
+	}
+	dbMock.On("GetBudgets", &user).Return(budgets, nil).Once()
+
+	transactions := createReportTransactions()
+	options := data.GetAllTransactionsOptions
+	options.FilterFromDate = "2015-10-01"
+	options.FilterToDate = "2015-11-30"
+	dbMock.On("GetTransactions", &user, options).Return(transactions, nil).Once()
+
+	accounts := []*data.Account{
+		{UUID: "uuid1", Name: "a1", Currency: "USD"},
+		{UUID: "uuid2", Name: "a2", Currency: "USD"},
+		{UUID: "uuid3", Name: "a3", Currency: "EUR"},
+		{UUID: "uuid4", Name: "a4", Currency: "EUR"},
+	}
+	dbMock.On("GetAccounts", &user).Return(accounts, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `[{"Budget":`+testBudgetJSON+`,"Periods":[{"From":"2015-11-01","To":"2015-11-30","Budget":20000,"Actual":12000,"Remaining":8000}]},`+
+		`{"Budget":{"UUID":"uuid2","Name":"Everything","Tags":null,"Currency":"EUR","Amount":5000,"Period":1,"Rollover":false,"StartDate":""},`+
+		`"Periods":[{"From":"2015-11-01","To":"2015-11-30","Budget":5000,"Actual":7000,"Remaining":-2000}]}]`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestBudgetProgressUnpaddedDates(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/budgets/progress", strings.NewReader("from=2015-11-5&to=2015-11-30"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	budgets := []*data.Budget{{UUID: "uuid1", Name: "Widgets", Tags: []string{"Widgets"}, Currency: "USD", Amount: 10000, Period: data.BudgetPeriodMonthly}}
+	dbMock.On("GetBudgets", &user).Return(budgets, nil).Once()
+
+	// A tag containing a comma doesn't match the budget.
+	// Refunds are subtracted from the amount spent, transfers and income with other tags are ignored.
+	transactions := []*data.Transaction{
+		{UUID: "uuid1", Type: data.TransactionTypeExpenseIncome, Tags: []string{"Gadgets,Widgets"}, Date: "2015-11-10",
+			Components: []data.TransactionComponent{{AccountUUID: "uuid1", Amount: -1000}}},
+		{UUID: "uuid2", Type: data.TransactionTypeExpenseIncome, Tags: []string{"Widgets"}, Date: "2015-11-20",
+			Components: []data.TransactionComponent{{AccountUUID: "uuid1", Amount: -500}}},
+		{UUID: "uuid3", Type: data.TransactionTypeExpenseIncome, Tags: []string{"Widgets"}, Date: "2015-11-21",
+			Components: []data.TransactionComponent{{AccountUUID: "uuid1", Amount: 200}}},
+		{UUID: "uuid5", Type: data.TransactionTypeExpenseIncome, Tags: []string{"Widgets", "Sales"}, Date: "2015-11-21",
+			Components: []data.TransactionComponent{{AccountUUID: "uuid1", Amount: 5000}}},
+		{UUID: "uuid4", Type: data.TransactionTypeTransfer, Tags: []string{"Widgets"}, Date: "2015-11-22",
+			Components: []data.TransactionComponent{{AccountUUID: "uuid1", Amount: -700}, {AccountUUID: "uuid2", Amount: 700}}},
+	}
+	options := data.GetAllTransactionsOptions
+	options.FilterFromDate = "2015-11-01"
+	options.FilterToDate = "2015-11-30"
+	dbMock.On("GetTransactions", &user, options).Return(transactions, nil).Once()
+
+	accounts := []*data.Account{{UUID: "uuid1", Name: "a1", Currency: "USD"}, {UUID: "uuid2", Name: "a2", Currency: "USD"}}
+	dbMock.On("GetAccounts", &user).Return(accounts, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `[{"Budget":{"UUID":"uuid1","Name":"Widgets","Tags":["Widgets"],"Currency":"USD","Amount":10000,"Period":1,"Rollover":false,"StartDate":""},`+
+		`"Periods":[{"From":"2015-11-01","To":"2015-11-30","Budget":10000,"Actual":300,"Remaining":9700}]}]`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestBudgetsUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/budgets", nil)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
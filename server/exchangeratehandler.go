@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zlogic/vogon-go/data"
+	"github.com/zlogic/vogon-go/server/auth"
+)
+
+// ExchangeRatesHandler returns or saves exchange rates for an authenticated user.
+func ExchangeRatesHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			rates := make([]*data.ExchangeRate, 0)
+			if err := json.NewDecoder(r.Body).Decode(&rates); err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			if err := s.db.SaveExchangeRates(user, rates); err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			w.Header().Add("Content-Type", "text/plain")
+			if _, err := io.WriteString(w, "OK"); err != nil {
+				log.WithError(err).Error("Failed to write response")
+			}
+			return
+		}
+
+		rates, err := s.db.GetExchangeRates(user)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rates); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// ExchangeRateHandler deletes an exchange rate.
+func ExchangeRateHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		from, to, date := chi.URLParam(r, "from"), chi.URLParam(r, "to"), chi.URLParam(r, "date")
+		if err := s.db.DeleteExchangeRate(user, from, to, date); err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/plain")
+		if _, err := io.WriteString(w, "OK"); err != nil {
+			log.WithError(err).Error("Failed to write response")
+		}
+	}
+}
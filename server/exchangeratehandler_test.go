@@ -0,0 +1,137 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zlogic/vogon-go/data"
+)
+
+const testExchangeRatesJSON = `[{"Date":"2015-01-01","From":"EUR","To":"USD","Rate":1.1},{"Date":"2015-01-02","From":"EUR","To":"USD","Rate":1.25}]`
+
+func createTestExchangeRates() []*data.ExchangeRate {
+	return []*data.ExchangeRate{
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: 1.1},
+		{Date: "2015-01-02", From: "EUR", To: "USD", Rate: 1.25},
+	}
+}
+
+func TestGetExchangeRatesAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/exchangerates", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetExchangeRates", &user).Return(createTestExchangeRates(), nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, testExchangeRatesJSON+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestSaveExchangeRatesAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/exchangerates", strings.NewReader(testExchangeRatesJSON))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("SaveExchangeRates", &user, createTestExchangeRates()).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestSaveExchangeRatesError(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/exchangerates", strings.NewReader(testExchangeRatesJSON))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("SaveExchangeRates", &user, createTestExchangeRates()).Return(fmt.Errorf("error")).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Equal(t, "Internal server error\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestDeleteExchangeRateAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("DELETE", "/api/exchangerates/EUR/USD/2015-01-01", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("DeleteExchangeRate", &user, "EUR", "USD", "2015-01-01").Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestExchangeRatesUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/exchangerates", nil)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
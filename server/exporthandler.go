@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zlogic/vogon-go/data"
+	"github.com/zlogic/vogon-go/server/auth"
+)
+
+// journalExtensions contains the file extensions for supported journal formats.
+var journalExtensions = map[string]string{
+	data.JournalFormatLedger:    ".ledger",
+	data.JournalFormatHledger:   ".journal",
+	data.JournalFormatBeancount: ".beancount",
+}
+
+// ExportJournalHandler returns a plain-text accounting journal with filtered transactions for an authenticated user.
+func ExportJournalHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		format := chi.URLParam(r, "format")
+		extension, ok := journalExtensions[format]
+		if !ok {
+			handleNotFound(w, r, format)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		options, err := parseFilterForm(r)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		filename := "vogon-" + time.Now().Format(time.RFC3339) + extension
+
+		w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		sw := &streamWriter{ResponseWriter: w}
+		if err := s.db.ExportJournal(user, format, options, sw); err != nil {
+			handleStreamError(sw, r, err)
+		}
+	}
+}
+
+// ExportCSVHandler returns a CSV file with filtered transactions for an authenticated user.
+func ExportCSVHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		options, err := parseFilterForm(r)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		filename := "vogon-" + time.Now().Format(time.RFC3339) + ".csv"
+
+		w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		sw := &streamWriter{ResponseWriter: w}
+		if err := s.db.ExportCSV(user, options, sw); err != nil {
+			handleStreamError(sw, r, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zlogic/vogon-go/data"
+)
+
+func TestExportJournalAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	form := url.Values{"filterFrom": {"2019-01-01"}, "filterTo": {"2019-12-31"}}
+	req, _ := http.NewRequest("POST", "/api/export/beancount", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	options := data.TransactionFilterOptions{FilterFromDate: "2019-01-01", FilterToDate: "2019-12-31"}
+	dbMock.On("ExportJournal", &user, "beancount", options).Return("beancount journal", nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "beancount journal", res.Body.String())
+	assert.Regexp(t, `^attachment; filename=vogon-.+\.beancount$`, res.Header().Get("Content-Disposition"))
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestExportJournalUnsupportedFormat(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/export/gnucash", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, "Not found\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestExportJournalUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/export/ledger", nil)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestExportCSVAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	form := url.Values{
+		"filterDescription":          {"groceries"},
+		"filterTags":                 {"Food,Household"},
+		"filterAccounts":             {"uuid1"},
+		"filterIncludeExpenseIncome": {"true"},
+		"filterIncludeTransfer":      {"false"},
+	}
+	req, _ := http.NewRequest("POST", "/api/transactions/export", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	options := data.TransactionFilterOptions{
+		FilterDescription: "groceries",
+		FilterTags:        []string{"Food", "Household"},
+		FilterAccounts:    []string{"uuid1"},
+		ExcludeTransfer:   true,
+	}
+	dbMock.On("ExportCSV", &user, options).Return("Date,Description\n", nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "Date,Description\n", res.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename=vogon-.+\.csv$`, res.Header().Get("Content-Disposition"))
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestExportCSVError(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transactions/export", strings.NewReader(""))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	options := data.TransactionFilterOptions{}
+	dbMock.On("ExportCSV", &user, options).Return("", fmt.Errorf("error")).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Equal(t, "Internal server error\n", res.Body.String())
+	assert.Empty(t, res.Header().Get("Content-Disposition"))
+
+	// Errors after the export has started abort the connection.
+	req, _ = http.NewRequest("POST", "/api/transactions/export", strings.NewReader(""))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res = httptest.NewRecorder()
+
+	dbMock.On("ExportCSV", &user, options).Return("Date,Description\n", fmt.Errorf("error")).Once()
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { router.ServeHTTP(res, req) })
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "Date,Description\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestExportCSVUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transactions/export", nil)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zlogic/vogon-go/data"
+	"github.com/zlogic/vogon-go/server/auth"
+)
+
+// ImportProfilesHandler returns all ImportProfiles for an authenticated user.
+func ImportProfilesHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		profiles, err := s.db.GetImportProfiles(user)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(profiles); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// ImportProfileHandler gets, updates or deletes an ImportProfile.
+func ImportProfileHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		requestUUID := chi.URLParam(r, "uuid")
+
+		if r.Method == http.MethodPost {
+			profile := &data.ImportProfile{}
+
+			err := json.NewDecoder(r.Body).Decode(&profile)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			if requestUUID == "new" {
+				err = s.db.CreateImportProfile(user, profile)
+			} else {
+				err = s.db.UpdateImportProfile(user, profile)
+			}
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			w.Header().Add("Content-Type", "text/plain")
+			if _, err := io.WriteString(w, "OK"); err != nil {
+				log.WithError(err).Error("Failed to write response")
+			}
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if err := s.db.DeleteImportProfile(user, requestUUID); err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			w.Header().Add("Content-Type", "text/plain")
+			if _, err := io.WriteString(w, "OK"); err != nil {
+				log.WithError(err).Error("Failed to write response")
+			}
+			return
+		}
+
+		profile, err := s.db.GetImportProfile(user, requestUUID)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		if profile == nil {
+			handleNotFound(w, r, requestUUID)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(profile); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// importFunc imports an uploaded statement file for user.
+type importFunc func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error)
+
+// parseDuplicateWindow returns the date window for finding duplicates from the duplicateDateWindow form value.
+func parseDuplicateWindow(r *http.Request) (int, error) {
+	value := r.FormValue("duplicateDateWindow")
+	if value == "" {
+		return data.DefaultDuplicateDateWindow, nil
+	}
+	dateWindow, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if dateWindow < 0 {
+		return 0, fmt.Errorf("invalid duplicate date window %v", dateWindow)
+	}
+	return dateWindow, nil
+}
+
+// parseDuplicateOptions returns the options for handling likely duplicates during import.
+func parseDuplicateOptions(r *http.Request) (data.DuplicateOptions, error) {
+	action, err := data.ParseDuplicateAction(r.FormValue("duplicates"))
+	if err != nil {
+		return data.DuplicateOptions{}, err
+	}
+	dateWindow, err := parseDuplicateWindow(r)
+	if err != nil {
+		return data.DuplicateOptions{}, err
+	}
+	return data.DuplicateOptions{Action: action, DateWindow: dateWindow}, nil
+}
+
+// statementImportHandler returns a handler which imports an uploaded statement file with importFn.
+func statementImportHandler(maxUploadSize int64, importFn importFunc) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+			handleError(w, r, err)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		duplicates, err := parseDuplicateOptions(r)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		importFile, ok := r.MultipartForm.File["file"]
+		if !ok {
+			handleError(w, r, fmt.Errorf("cannot extract file part"))
+			return
+		}
+		file, err := importFile[0].Open()
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		defer file.Close()
+
+		result, err := importFn(user, r, duplicates, file)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// ImportCSVHandler imports an uploaded CSV statement using a saved ImportProfile.
+func ImportCSVHandler(s *Services, maxUploadSize int64) func(w http.ResponseWriter, r *http.Request) {
+	return statementImportHandler(maxUploadSize, func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error) {
+		return s.db.ImportCSV(user, r.FormValue("profile"), duplicates, file)
+	})
+}
+
+// ImportOFXHandler imports an uploaded OFX or QFX statement into an account.
+func ImportOFXHandler(s *Services, maxUploadSize int64) func(w http.ResponseWriter, r *http.Request) {
+	return statementImportHandler(maxUploadSize, func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error) {
+		return s.db.ImportOFX(user, r.FormValue("account"), duplicates, file)
+	})
+}
+
+// ImportCamtHandler imports an uploaded camt.053 or camt.052 statement into an account.
+func ImportCamtHandler(s *Services, maxUploadSize int64) func(w http.ResponseWriter, r *http.Request) {
+	return statementImportHandler(maxUploadSize, func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error) {
+		return s.db.ImportCamt(user, r.FormValue("account"), duplicates, file)
+	})
+}
+
+// ImportMT940Handler imports an uploaded MT940 statement into an account.
+func ImportMT940Handler(s *Services, maxUploadSize int64) func(w http.ResponseWriter, r *http.Request) {
+	return statementImportHandler(maxUploadSize, func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error) {
+		return s.db.ImportMT940(user, r.FormValue("account"), duplicates, file)
+	})
+}
+
+// ImportQIFHandler imports an uploaded QIF file, creating missing accounts.
+func ImportQIFHandler(s *Services, maxUploadSize int64) func(w http.ResponseWriter, r *http.Request) {
+	return statementImportHandler(maxUploadSize, func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error) {
+		return s.db.ImportQIF(user, r.FormValue("account"), r.FormValue("currency"), duplicates, file)
+	})
+}
+
+// ImportJournalHandler imports an uploaded beancount or ledger journal, creating missing accounts.
+func ImportJournalHandler(s *Services, maxUploadSize int64, format string) func(w http.ResponseWriter, r *http.Request) {
+	return statementImportHandler(maxUploadSize, func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error) {
+		return s.db.ImportJournal(user, format, duplicates, file)
+	})
+}
+
+// ImportExchangeRatesCSVHandler imports an uploaded CSV file with exchange rates.
+// The base form value sets the base currency for Date,Currency,Rate files.
+func ImportExchangeRatesCSVHandler(s *Services, maxUploadSize int64) func(w http.ResponseWriter, r *http.Request) {
+	return statementImportHandler(maxUploadSize, func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error) {
+		return s.db.ImportExchangeRatesCSV(user, r.FormValue("base"), file)
+	})
+}
+
+// ImportExchangeRatesECBHandler imports an uploaded ECB euro reference rates XML file.
+func ImportExchangeRatesECBHandler(s *Services, maxUploadSize int64) func(w http.ResponseWriter, r *http.Request) {
+	return statementImportHandler(maxUploadSize, func(user *data.User, r *http.Request, duplicates data.DuplicateOptions, file io.Reader) (*data.ImportResult, error) {
+		return s.db.ImportExchangeRatesECB(user, file)
+	})
+}
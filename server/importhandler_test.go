@@ -0,0 +1,656 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zlogic/vogon-go/data"
+)
+
+var defaultDuplicateOptions = data.DuplicateOptions{DateWindow: data.DefaultDuplicateDateWindow}
+
+func createTestImportProfile() *data.ImportProfile {
+	return &data.ImportProfile{
+		UUID:              "uuid1",
+		Name:              "Bank",
+		AccountUUID:       "uuid2",
+		Delimiter:         ";",
+		DateColumn:        "Date",
+		DateFormat:        "02.01.2006",
+		AmountColumn:      "Amount",
+		DecimalSeparator:  ",",
+		DescriptionColumn: "Description",
+	}
+}
+
+const testImportProfileJSON = `{"UUID":"uuid1","Name":"Bank","AccountUUID":"uuid2","Delimiter":";","DateColumn":"Date","DateFormat":"02.01.2006","AmountColumn":"Amount","DebitColumn":"","CreditColumn":"","DecimalSeparator":",","DescriptionColumn":"Description"}`
+
+func createImportRequest(url string, fields map[string]string, file string) (*http.Request, error) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	fileWriter, err := writer.CreateFormFile("file", "statement")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fileWriter.Write([]byte(file)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+	return req, nil
+}
+
+func TestGetImportProfilesAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/importprofiles", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetImportProfiles", &user).Return([]*data.ImportProfile{createTestImportProfile()}, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "["+testImportProfileJSON+"]\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetImportProfileAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/importprofile/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetImportProfile", &user, "uuid1").Return(createTestImportProfile(), nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, testImportProfileJSON+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetImportProfileDoesNotExistAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/importprofile/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetImportProfile", &user, "uuid1").Return(nil, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, "Not found\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestCreateImportProfileAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/importprofile/new", strings.NewReader(testImportProfileJSON))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("CreateImportProfile", &user, createTestImportProfile()).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestUpdateImportProfileAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/importprofile/uuid1", strings.NewReader(testImportProfileJSON))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("UpdateImportProfile", &user, createTestImportProfile()).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestDeleteImportProfileAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("DELETE", "/api/importprofile/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("DeleteImportProfile", &user, "uuid1").Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportProfilesUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/importprofiles", nil)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportCSVAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/csv", map[string]string{"profile": "uuid1"}, "csv data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 2, Skipped: []string{"line 3: bad amount"}}
+	dbMock.On("ImportCSV", &user, "uuid1", defaultDuplicateOptions, "csv data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":2,"AlreadyImported":0,"Skipped":["line 3: bad amount"],"CreatedAccounts":null,"Warnings":null}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportCSVFailedAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/csv", map[string]string{"profile": "uuid1"}, "csv data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("ImportCSV", &user, "uuid1", defaultDuplicateOptions, "csv data").Return(nil, fmt.Errorf("no date column")).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Equal(t, "Internal server error\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportCSVUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/csv", map[string]string{"profile": "uuid1"}, "csv data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportOFXAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/ofx", map[string]string{"account": "uuid2"}, "ofx data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 2, AlreadyImported: 1}
+	dbMock.On("ImportOFX", &user, "uuid2", defaultDuplicateOptions, "ofx data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":2,"AlreadyImported":1,"Skipped":null,"CreatedAccounts":null,"Warnings":null}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportOFXUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/ofx", map[string]string{"account": "uuid2"}, "ofx data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportCamtAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/camt", map[string]string{"account": "uuid2"}, "camt data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 1, AlreadyImported: 2}
+	dbMock.On("ImportCamt", &user, "uuid2", defaultDuplicateOptions, "camt data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":1,"AlreadyImported":2,"Skipped":null,"CreatedAccounts":null,"Warnings":null}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportCamtUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/camt", map[string]string{"account": "uuid2"}, "camt data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportMT940Authorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/mt940", map[string]string{"account": "uuid2"}, "mt940 data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 3, Warnings: []string{"statement STMT1 closing balance 1.00 doesn't match account balance 2.00 on 2019-03-03"}}
+	dbMock.On("ImportMT940", &user, "uuid2", defaultDuplicateOptions, "mt940 data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":3,"AlreadyImported":0,"Skipped":null,"CreatedAccounts":null,"Warnings":["statement STMT1 closing balance 1.00 doesn't match account balance 2.00 on 2019-03-03"]}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportMT940Unauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/mt940", map[string]string{"account": "uuid2"}, "mt940 data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportQIFAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/qif", map[string]string{"account": "", "currency": "USD"}, "qif data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 3, Skipped: []string{"line 5: unsupported field Q"}, CreatedAccounts: []string{"Checking"}}
+	dbMock.On("ImportQIF", &user, "", "USD", defaultDuplicateOptions, "qif data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":3,"AlreadyImported":0,"Skipped":["line 5: unsupported field Q"],"CreatedAccounts":["Checking"],"Warnings":null}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportQIFUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/qif", map[string]string{"currency": "USD"}, "qif data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportJournalAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/beancount", map[string]string{}, "beancount data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 2, Skipped: []string{"line 3: unsupported directive balance"}, CreatedAccounts: []string{"Assets:Checking"}}
+	dbMock.On("ImportJournal", &user, data.JournalFormatBeancount, defaultDuplicateOptions, "beancount data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":2,"AlreadyImported":0,"Skipped":["line 3: unsupported directive balance"],"CreatedAccounts":["Assets:Checking"],"Warnings":null}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportExchangeRatesCSVAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/exchangerates", map[string]string{"base": "EUR"}, "rates data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 2}
+	dbMock.On("ImportExchangeRatesCSV", &user, "EUR", "rates data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":2,"AlreadyImported":0,"Skipped":null,"CreatedAccounts":null,"Warnings":null}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportExchangeRatesECBAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/ecb", map[string]string{}, "ecb data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 2, Skipped: []string{"2015-01-02 XXX: invalid rate"}}
+	dbMock.On("ImportExchangeRatesECB", &user, "ecb data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":2,"AlreadyImported":0,"Skipped":["2015-01-02 XXX: invalid rate"],"CreatedAccounts":null,"Warnings":null}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportJournalError(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/ledger", map[string]string{}, "ledger data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("ImportJournal", &user, data.JournalFormatLedger, defaultDuplicateOptions, "ledger data").Return(nil, fmt.Errorf("error")).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Equal(t, "Internal server error\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportJournalUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/hledger", map[string]string{}, "hledger data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportDuplicateOptions(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, err := createImportRequest("/api/import/ofx", map[string]string{"account": "uuid2", "duplicates": "skip", "duplicateDateWindow": "5"}, "ofx data")
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	result := &data.ImportResult{Imported: 1, Skipped: []string{"transaction 2019-03-01 Groceries is a possible duplicate of 2019-03-02 Groceries"}}
+	duplicates := data.DuplicateOptions{Action: data.DuplicatesSkip, DateWindow: 5}
+	dbMock.On("ImportOFX", &user, "uuid2", duplicates, "ofx data").Return(result, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Imported":1,"AlreadyImported":0,"Skipped":["transaction 2019-03-01 Groceries is a possible duplicate of 2019-03-02 Groceries"],"CreatedAccounts":null,"Warnings":null}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestImportInvalidDuplicateOptions(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	for _, fields := range []map[string]string{
+		{"account": "uuid2", "duplicates": "merge"},
+		{"account": "uuid2", "duplicates": "flag", "duplicateDateWindow": "-1"},
+	} {
+		req, err := createImportRequest("/api/import/ofx", fields, "ofx data")
+		assert.NoError(t, err)
+		res := httptest.NewRecorder()
+
+		router.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+		assert.Equal(t, "Internal server error\n", res.Body.String())
+	}
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zlogic/vogon-go/data"
+	"github.com/zlogic/vogon-go/server/auth"
+)
+
+// ReconciliationHandler compares the cleared balance of an Account with a statement's closing balance.
+// If finish is true, cleared components are locked as reconciled when the balances match.
+func ReconciliationHandler(s *Services, finish bool) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		accountUUID := chi.URLParam(r, "uuid")
+		statementDate := r.Form.Get("statementDate")
+		closingBalance, err := strconv.ParseInt(r.Form.Get("closingBalance"), 10, 64)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		var reconciliation *data.Reconciliation
+		if finish {
+			reconciliation, err = s.db.FinishReconciliation(user, accountUUID, statementDate, closingBalance)
+		} else {
+			reconciliation, err = s.db.Reconcile(user, accountUUID, statementDate, closingBalance)
+		}
+		if errors.Is(err, data.ErrReconciliationDifference) {
+			http.Error(w, "Cleared balance doesn't match closing balance", http.StatusConflict)
+			return
+		} else if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reconciliation); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
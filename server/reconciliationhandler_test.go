@@ -0,0 +1,148 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zlogic/vogon-go/data"
+)
+
+func createTestReconciliation() *data.Reconciliation {
+	transaction := createTestTransaction()
+	return &data.Reconciliation{
+		AccountUUID:    "uuid2",
+		StatementDate:  "2015-11-30",
+		ClosingBalance: -5000,
+		ClearedBalance: 5000,
+		Difference:     -10000,
+		Uncleared:      []*data.Transaction{transaction},
+	}
+}
+
+func createReconciliationRequest(url string) *http.Request {
+	req, _ := http.NewRequest("POST", url, strings.NewReader("statementDate=2015-11-30&closingBalance=-5000"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestReconcileAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req := createReconciliationRequest("/api/account/uuid2/reconcile")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("Reconcile", &user, "uuid2", "2015-11-30", int64(-5000)).Return(createTestReconciliation(), nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"AccountUUID":"uuid2","StatementDate":"2015-11-30","ClosingBalance":-5000,"ClearedBalance":5000,"Difference":-10000,`+
+		`"Uncleared":[{"UUID":"uuid42","Description":"Widgets","Type":0,"Tags":["Widgets"],"Date":"2015-11-02","Components":[{"Amount":-10000,"AccountUUID":"uuid2"}]}]}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestFinishReconciliationAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req := createReconciliationRequest("/api/account/uuid2/reconcile/finish")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	reconciliation := &data.Reconciliation{AccountUUID: "uuid2", StatementDate: "2015-11-30", ClosingBalance: -5000, ClearedBalance: -5000, Uncleared: []*data.Transaction{}}
+	dbMock.On("FinishReconciliation", &user, "uuid2", "2015-11-30", int64(-5000)).Return(reconciliation, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"AccountUUID":"uuid2","StatementDate":"2015-11-30","ClosingBalance":-5000,"ClearedBalance":-5000,"Difference":0,"Uncleared":[]}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestFinishReconciliationDifference(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req := createReconciliationRequest("/api/account/uuid2/reconcile/finish")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("FinishReconciliation", &user, "uuid2", "2015-11-30", int64(-5000)).Return(nil, fmt.Errorf("failed: %w", data.ErrReconciliationDifference)).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Equal(t, "Cleared balance doesn't match closing balance\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestReconcileError(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req := createReconciliationRequest("/api/account/uuid2/reconcile")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("Reconcile", &user, "uuid2", "2015-11-30", int64(-5000)).Return(nil, fmt.Errorf("error")).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Equal(t, "Internal server error\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestReconcileUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req := createReconciliationRequest("/api/account/uuid2/reconcile")
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zlogic/vogon-go/data"
+	"github.com/zlogic/vogon-go/server/auth"
+)
+
+// RecurringTransactionsHandler returns all RecurringTransactions for an authenticated user.
+func RecurringTransactionsHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		recurringTransactions, err := s.db.GetRecurringTransactions(user)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(recurringTransactions); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// RecurringTransactionHandler gets, updates or deletes a RecurringTransaction.
+func RecurringTransactionHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		requestUUID := chi.URLParam(r, "uuid")
+
+		if r.Method == http.MethodPost {
+			recurring := &data.RecurringTransaction{}
+
+			err := json.NewDecoder(r.Body).Decode(&recurring)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			if requestUUID == "new" {
+				err = s.db.CreateRecurringTransaction(user, recurring)
+			} else {
+				err = s.db.UpdateRecurringTransaction(user, recurring)
+			}
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			w.Header().Add("Content-Type", "text/plain")
+			if _, err := io.WriteString(w, "OK"); err != nil {
+				log.WithError(err).Error("Failed to write response")
+			}
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if err := s.db.DeleteRecurringTransaction(user, requestUUID); err != nil {
+				handleError(w, r, err)
+				return
+			}
+
+			w.Header().Add("Content-Type", "text/plain")
+			if _, err := io.WriteString(w, "OK"); err != nil {
+				log.WithError(err).Error("Failed to write response")
+			}
+			return
+		}
+
+		recurring, err := s.db.GetRecurringTransaction(user, requestUUID)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		if recurring == nil {
+			handleNotFound(w, r, requestUUID)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(recurring); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// RecurringTransactionActionHandler skips the next occurrence, pauses or resumes a RecurringTransaction.
+// Returns the updated RecurringTransaction.
+func RecurringTransactionActionHandler(s *Services, action string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		requestUUID := chi.URLParam(r, "uuid")
+
+		var recurring *data.RecurringTransaction
+		var err error
+		switch action {
+		case "skip":
+			recurring, err = s.db.SkipRecurringTransaction(user, requestUUID)
+		case "pause":
+			recurring, err = s.db.PauseRecurringTransaction(user, requestUUID, true)
+		case "resume":
+			recurring, err = s.db.PauseRecurringTransaction(user, requestUUID, false)
+		default:
+			handleNotFound(w, r, action)
+			return
+		}
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(recurring); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zlogic/vogon-go/data"
+)
+
+func createTestRecurringTransaction() *data.RecurringTransaction {
+	return &data.RecurringTransaction{
+		UUID: "uuid1",
+		Template: data.Transaction{
+			Description: "Rent",
+			Type:        data.TransactionTypeExpenseIncome,
+			Tags:        []string{"Home"},
+			Components:  []data.TransactionComponent{{Amount: -100000, AccountUUID: "uuid2"}},
+		},
+		Frequency:  data.RecurrenceMonthly,
+		StartDate:  "2015-01-31",
+		DayOfMonth: -1,
+		NextDate:   "2015-01-31",
+	}
+}
+
+const testRecurringTransactionJSON = `{"UUID":"uuid1","Template":{"UUID":"","Description":"Rent","Type":0,"Tags":["Home"],"Date":"","Components":[{"Amount":-100000,"AccountUUID":"uuid2"}]},` +
+	`"Frequency":2,"Interval":0,"StartDate":"2015-01-31","DayOfMonth":-1,"EndDate":"","Count":0,"Paused":false,"Blocked":false,"LastDate":"","Occurrences":0,"NextDate":"2015-01-31"}`
+
+func TestGetRecurringTransactionsAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/recurringtransactions", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetRecurringTransactions", &user).Return([]*data.RecurringTransaction{createTestRecurringTransaction()}, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "["+testRecurringTransactionJSON+"]\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetRecurringTransactionAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/recurringtransaction/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetRecurringTransaction", &user, "uuid1").Return(createTestRecurringTransaction(), nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, testRecurringTransactionJSON+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetRecurringTransactionDoesNotExistAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/recurringtransaction/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("GetRecurringTransaction", &user, "uuid1").Return(nil, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, "Not found\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestCreateRecurringTransactionAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/recurringtransaction/new", strings.NewReader(testRecurringTransactionJSON))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("CreateRecurringTransaction", &user, createTestRecurringTransaction()).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestUpdateRecurringTransactionAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/recurringtransaction/uuid1", strings.NewReader(testRecurringTransactionJSON))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("UpdateRecurringTransaction", &user, createTestRecurringTransaction()).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestDeleteRecurringTransactionAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("DELETE", "/api/recurringtransaction/uuid1", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("DeleteRecurringTransaction", &user, "uuid1").Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestSkipRecurringTransactionAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/recurringtransaction/uuid1/skip", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("SkipRecurringTransaction", &user, "uuid1").Return(createTestRecurringTransaction(), nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, testRecurringTransactionJSON+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestPauseResumeRecurringTransactionAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("PauseRecurringTransaction", &user, "uuid1", true).Return(createTestRecurringTransaction(), nil).Once()
+	dbMock.On("PauseRecurringTransaction", &user, "uuid1", false).Return(createTestRecurringTransaction(), nil).Once()
+
+	req, _ := http.NewRequest("POST", "/api/recurringtransaction/uuid1/pause", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, testRecurringTransactionJSON+"\n", res.Body.String())
+
+	req, _ = http.NewRequest("POST", "/api/recurringtransaction/uuid1/resume", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, testRecurringTransactionJSON+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestRecurringTransactionsUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/recurringtransactions", nil)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
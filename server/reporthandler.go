@@ -154,7 +154,46 @@ func createTagsChart(transactions []*data.Transaction, accounts []*data.Account)
 	return chart
 }
 
+// convertTransactions converts amounts of all transaction components into reportCurrency,
+// using the exchange rate valid on the transaction date.
+// Components which cannot be converted are removed.
+// Returns accounts using reportCurrency and the currencies which have no exchange rate to reportCurrency.
+func convertTransactions(transactions []*data.Transaction, accounts []*data.Account, converter *data.ExchangeRateConverter, reportCurrency string) ([]*data.Account, []string) {
+	missingCurrencies := make(map[string]bool)
+	for _, transaction := range transactions {
+		components := transaction.Components[:0]
+		for _, component := range transaction.Components {
+			currency := getCurrency(component, accounts)
+			if currency != "" {
+				amount, ok := converter.Convert(component.Amount, currency, reportCurrency, transaction.Date)
+				if !ok {
+					missingCurrencies[currency] = true
+					continue
+				}
+				component.Amount = amount
+			}
+			components = append(components, component)
+		}
+		transaction.Components = components
+	}
+
+	convertedAccounts := make([]*data.Account, len(accounts))
+	for i, account := range accounts {
+		convertedAccount := *account
+		convertedAccount.Currency = reportCurrency
+		convertedAccounts[i] = &convertedAccount
+	}
+
+	missing := make([]string, 0, len(missingCurrencies))
+	for currency := range missingCurrencies {
+		missing = append(missing, currency)
+	}
+	sort.Strings(missing)
+	return convertedAccounts, missing
+}
+
 // ReportHandler generates data for a report.
+// If the reportCurrency form value is set, all amounts are converted into that currency.
 func ReportHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := auth.GetUser(r.Context())
@@ -187,6 +226,17 @@ func ReportHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
 		}
 		accounts = filterAccounts(accounts, filterOptions)
 
+		var missingExchangeRates []string
+		if reportCurrency := r.Form.Get("reportCurrency"); reportCurrency != "" {
+			rates, err := s.db.GetExchangeRates(user)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+			converter := data.NewExchangeRateConverter(rates)
+			accounts, missingExchangeRates = convertTransactions(transactions, accounts, converter, reportCurrency)
+		}
+
 		chart := createBalanceChart(transactions, accounts, filterOptions)
 
 		filterTransactions(&transactions, filterOptions)
@@ -194,10 +244,12 @@ func ReportHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
 		type report struct {
 			BalanceChart currencyDateBalance
 			TagsChart    currencyTagAmount
+			// MissingExchangeRates lists currencies which could not be converted into the report currency.
+			MissingExchangeRates []string `json:",omitempty"`
 		}
 
 		w.Header().Add("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(report{BalanceChart: chart, TagsChart: tags}); err != nil {
+		if err := json.NewEncoder(w).Encode(report{BalanceChart: chart, TagsChart: tags, MissingExchangeRates: missingExchangeRates}); err != nil {
 			handleError(w, r, err)
 		}
 	}
@@ -458,6 +458,51 @@ func TestReportFilterExcludeExpenseIncome(t *testing.T) {
 	authHandler.AssertExpectations(t)
 }
 
+func TestReportCurrency(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/report", strings.NewReader("reportCurrency=USD"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	transactions := createReportTransactions()
+	options := data.GetAllTransactionsOptions
+	dbMock.On("GetTransactions", &user, options).Return(transactions, nil).Once()
+
+	accounts := []*data.Account{
+		{UUID: "uuid1", Name: "a1", Currency: "USD"},
+		{UUID: "uuid2", Name: "a2", Currency: "USD"},
+		{UUID: "uuid3", Name: "a3", Currency: "EUR"},
+		{UUID: "uuid4", Name: "a4", Currency: "GBP"},
+	}
+	dbMock.On("GetAccounts", &user).Return(accounts, nil).Once()
+
+	rates := []*data.ExchangeRate{
+		{Date: "2015-01-01", From: "EUR", To: "USD", Rate: 1.1},
+		{Date: "2015-11-05", From: "EUR", To: "USD", Rate: 1.2},
+	}
+	dbMock.On("GetExchangeRates", &user).Return(rates, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"BalanceChart":{`+
+		`"USD":{"2015-11-01":310000,"2015-11-02":310000,"2015-11-03":303800,"2015-11-04":297800,"2015-11-05":292800,"2015-11-06":285920}`+
+		`},"TagsChart":{`+
+		`"USD":{"Positive":{"Gadgets":0,"Gadgets,Widgets":0,"Salary":310000,"Widgets":0},"Negative":{"Gadgets":3000,"Gadgets,Widgets":11200,"Salary":0,"Widgets":3000},"Transfer":{"Transfer":8000}}`+
+		`},"MissingExchangeRates":["GBP"]}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
 func TestReportUnauthorized(t *testing.T) {
 	dbMock := new(DBMock)
 	authHandler := AuthHandlerMock{}
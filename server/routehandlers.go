@@ -18,6 +18,35 @@ func handleError(w http.ResponseWriter, r *http.Request, err error) {
 	http.Error(w, "Internal server error", http.StatusInternalServerError)
 }
 
+// streamWriter tracks if writing a streamed response has started.
+type streamWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+// Write writes p into the response.
+// Empty writes are skipped, as they would send the response headers.
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	sw.started = true
+	return sw.ResponseWriter.Write(p)
+}
+
+// handleStreamError handles an error returned while streaming a response into sw.
+// Once the response has started, its status can no longer be changed;
+// the connection is aborted instead, so that the client knows the response is incomplete.
+func handleStreamError(sw *streamWriter, r *http.Request, err error) {
+	if !sw.started {
+		sw.Header().Del("Content-Disposition")
+		handleError(sw.ResponseWriter, r, err)
+		return
+	}
+	log.WithError(err).Error("Error while streaming response")
+	panic(http.ErrAbortHandler)
+}
+
 func handleNotFound(w http.ResponseWriter, r *http.Request, key string) {
 	log.Errorf("Item %v not found", key)
 	http.Error(w, "Not found", http.StatusNotFound)
@@ -424,3 +424,42 @@ func TestHtmlSettingsHandlerNotLoggedIn(t *testing.T) {
 
 	authHandler.AssertExpectations(t)
 }
+
+func TestHtmlImportHandlerLoggedIn(t *testing.T) {
+	templates := prepareTemplate("import", `{{ define "content" }}importpage{{ end }}`)
+
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{cookieHandler: &authHandler, templates: templates}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/import", nil)
+	res := httptest.NewRecorder()
+
+	authHandler.AllowUser(&data.User{UUID: "uuid1"})
+
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "User {  uuid1 }\nName import\nContent importpage", res.Body.String())
+
+	authHandler.AssertExpectations(t)
+}
+
+func TestHtmlImportHandlerNotLoggedIn(t *testing.T) {
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/import", nil)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusSeeOther, res.Code)
+	assert.Equal(t, "/login", res.Header().Get("Location"))
+
+	authHandler.AssertExpectations(t)
+}
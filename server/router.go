@@ -8,6 +8,8 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/zlogic/vogon-go/data"
 )
 
 // NoCacheHeaderMiddlewareFunc creates a handler to disable caching.
@@ -87,6 +89,7 @@ func CreateRouter(s *Services) (*chi.Mux, error) {
 		authorized.Get("/accounts", HTMLUserPageHandler(s, "accounts"))
 		authorized.Get("/accounteditor", HTMLUserPageHandler(s, "accounteditor"))
 		authorized.Get("/settings", HTMLUserPageHandler(s, "settings"))
+		authorized.Get("/import", HTMLUserPageHandler(s, "import"))
 	})
 	r.HandleFunc("/favicon.ico", FaviconHandler)
 	fs := http.FileServer(staticResourceFileSystem{http.FS(staticContent)})
@@ -105,8 +108,12 @@ func CreateRouter(s *Services) (*chi.Mux, error) {
 			authorized.Get("/settings", SettingsHandler(s, maxUploadSize))
 			authorized.Post("/settings", SettingsHandler(s, maxUploadSize))
 			authorized.Post("/backup", BackupHandler(s))
+			authorized.Post("/export/{format}", ExportJournalHandler(s))
 			authorized.Post("/transactions/getcount", TransactionsCountHandler(s))
 			authorized.Post("/transactions/getpage", TransactionsHandler(s))
+			authorized.Post("/transactions/search", SearchTransactionsHandler(s))
+			authorized.Post("/transactions/export", ExportCSVHandler(s))
+			authorized.Post("/transactions/duplicates", DuplicatesHandler(s))
 			authorized.Get("/transaction/{uuid}", TransactionHandler(s))
 			authorized.Post("/transaction/{uuid}", TransactionHandler(s))
 			authorized.Delete("/transaction/{uuid}", TransactionHandler(s))
@@ -115,7 +122,38 @@ func CreateRouter(s *Services) (*chi.Mux, error) {
 			authorized.Get("/account/{uuid}", AccountHandler(s))
 			authorized.Post("/account/{uuid}", AccountHandler(s))
 			authorized.Delete("/account/{uuid}", AccountHandler(s))
+			authorized.Post("/account/{uuid}/reconcile", ReconciliationHandler(s, false))
+			authorized.Post("/account/{uuid}/reconcile/finish", ReconciliationHandler(s, true))
 			authorized.Get("/tags", TagsHandler(s))
+			authorized.Get("/budgets", BudgetsHandler(s))
+			authorized.Post("/budgets/progress", BudgetProgressHandler(s))
+			authorized.Get("/budgets/{uuid}", BudgetHandler(s))
+			authorized.Post("/budgets/{uuid}", BudgetHandler(s))
+			authorized.Delete("/budgets/{uuid}", BudgetHandler(s))
+			authorized.Get("/exchangerates", ExchangeRatesHandler(s))
+			authorized.Post("/exchangerates", ExchangeRatesHandler(s))
+			authorized.Delete("/exchangerates/{from}/{to}/{date}", ExchangeRateHandler(s))
+			authorized.Get("/importprofiles", ImportProfilesHandler(s))
+			authorized.Get("/importprofile/{uuid}", ImportProfileHandler(s))
+			authorized.Post("/importprofile/{uuid}", ImportProfileHandler(s))
+			authorized.Delete("/importprofile/{uuid}", ImportProfileHandler(s))
+			authorized.Get("/recurringtransactions", RecurringTransactionsHandler(s))
+			authorized.Get("/recurringtransaction/{uuid}", RecurringTransactionHandler(s))
+			authorized.Post("/recurringtransaction/{uuid}", RecurringTransactionHandler(s))
+			authorized.Delete("/recurringtransaction/{uuid}", RecurringTransactionHandler(s))
+			authorized.Post("/recurringtransaction/{uuid}/skip", RecurringTransactionActionHandler(s, "skip"))
+			authorized.Post("/recurringtransaction/{uuid}/pause", RecurringTransactionActionHandler(s, "pause"))
+			authorized.Post("/recurringtransaction/{uuid}/resume", RecurringTransactionActionHandler(s, "resume"))
+			authorized.Post("/import/csv", ImportCSVHandler(s, maxUploadSize))
+			authorized.Post("/import/ofx", ImportOFXHandler(s, maxUploadSize))
+			authorized.Post("/import/camt", ImportCamtHandler(s, maxUploadSize))
+			authorized.Post("/import/mt940", ImportMT940Handler(s, maxUploadSize))
+			authorized.Post("/import/qif", ImportQIFHandler(s, maxUploadSize))
+			authorized.Post("/import/beancount", ImportJournalHandler(s, maxUploadSize, data.JournalFormatBeancount))
+			authorized.Post("/import/ledger", ImportJournalHandler(s, maxUploadSize, data.JournalFormatLedger))
+			authorized.Post("/import/hledger", ImportJournalHandler(s, maxUploadSize, data.JournalFormatHledger))
+			authorized.Post("/import/exchangerates", ImportExchangeRatesCSVHandler(s, maxUploadSize))
+			authorized.Post("/import/ecb", ImportExchangeRatesECBHandler(s, maxUploadSize))
 		})
 	})
 	return r, nil
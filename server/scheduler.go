@@ -0,0 +1,46 @@
+package server
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// recurringInterval returns the interval between checks for due recurring transactions.
+func recurringInterval() time.Duration {
+	return time.Duration(parseInt64Env("RECURRING_INTERVAL_MINUTES", 60)) * time.Minute
+}
+
+// materializeRecurringTransactions creates all due recurring transactions and logs the result.
+func materializeRecurringTransactions(s *Services, now time.Time) {
+	created, err := s.db.MaterializeRecurringTransactions(now)
+	if err != nil {
+		log.WithError(err).Error("Failed to create recurring transactions")
+	}
+	if created > 0 {
+		log.WithField("count", created).Info("Created recurring transactions")
+	}
+}
+
+// runScheduler creates due recurring transactions immediately and then on every tick, until done is closed.
+func runScheduler(s *Services, ticks <-chan time.Time, done <-chan struct{}) {
+	materializeRecurringTransactions(s, time.Now())
+	for {
+		select {
+		case now := <-ticks:
+			materializeRecurringTransactions(s, now)
+		case <-done:
+			return
+		}
+	}
+}
+
+// StartScheduler starts a background goroutine which creates due recurring transactions.
+// The goroutine stops when done is closed.
+func StartScheduler(s *Services, done <-chan struct{}) {
+	ticker := time.NewTicker(recurringInterval())
+	go func() {
+		defer ticker.Stop()
+		runScheduler(s, ticker.C, done)
+	}()
+}
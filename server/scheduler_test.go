@@ -0,0 +1,32 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunScheduler(t *testing.T) {
+	dbMock := new(DBMock)
+	services := &Services{db: dbMock}
+
+	tick := time.Date(2015, time.February, 1, 0, 0, 0, 0, time.UTC)
+	dbMock.On("MaterializeRecurringTransactions", mock.AnythingOfType("time.Time")).Return(0, nil).Once()
+	dbMock.On("MaterializeRecurringTransactions", tick).Return(1, fmt.Errorf("failed")).Once()
+
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		runScheduler(services, ticks, done)
+		close(finished)
+	}()
+
+	ticks <- tick
+	close(done)
+	<-finished
+
+	dbMock.AssertExpectations(t)
+}
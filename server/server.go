@@ -1,8 +1,10 @@
 package server
 
 import (
+	"io"
 	"io/fs"
 	"net/http"
+	"time"
 
 	"github.com/zlogic/vogon-go/data"
 	"github.com/zlogic/vogon-go/server/auth"
@@ -18,20 +20,61 @@ type DB interface {
 
 	GetAccounts(*data.User) ([]*data.Account, error)
 	GetTransactions(*data.User, data.GetTransactionOptions) ([]*data.Transaction, error)
+	GetTransactionsPage(*data.User, data.GetTransactionOptions) (*data.TransactionsPage, error)
 	CountTransactions(*data.User, data.TransactionFilterOptions) (uint64, error)
+	SearchTransactions(*data.User, data.SearchTransactionOptions) (*data.SearchResult, error)
 	CreateAccount(*data.User, *data.Account) error
 	UpdateAccount(*data.User, *data.Account) error
 	GetAccount(user *data.User, accountUUID string) (*data.Account, error)
 	DeleteAccount(user *data.User, accountUUID string) error
 	CreateTransaction(*data.User, *data.Transaction) error
-	UpdateTransaction(*data.User, *data.Transaction) error
+	UpdateTransaction(user *data.User, transaction *data.Transaction, overrideReconciled bool) error
+	FindDuplicates(user *data.User, transaction *data.Transaction, dateWindow int) ([]*data.Transaction, error)
 	GetTransaction(user *data.User, transactionUUID string) (*data.Transaction, error)
-	DeleteTransaction(user *data.User, transactionUUID string) error
+	DeleteTransaction(user *data.User, transactionUUID string, overrideReconciled bool) error
+	Reconcile(user *data.User, accountUUID string, statementDate string, closingBalance int64) (*data.Reconciliation, error)
+	FinishReconciliation(user *data.User, accountUUID string, statementDate string, closingBalance int64) (*data.Reconciliation, error)
 
 	GetTags(user *data.User) ([]string, error)
 
-	Backup(user *data.User) (string, error)
-	Restore(user *data.User, value string) error
+	GetBudgets(*data.User) ([]*data.Budget, error)
+	GetBudget(user *data.User, budgetUUID string) (*data.Budget, error)
+	CreateBudget(*data.User, *data.Budget) error
+	UpdateBudget(*data.User, *data.Budget) error
+	DeleteBudget(user *data.User, budgetUUID string) error
+
+	GetExchangeRates(*data.User) ([]*data.ExchangeRate, error)
+	SaveExchangeRates(*data.User, []*data.ExchangeRate) error
+	DeleteExchangeRate(user *data.User, from, to, date string) error
+	ImportExchangeRatesCSV(user *data.User, base string, reader io.Reader) (*data.ImportResult, error)
+	ImportExchangeRatesECB(user *data.User, reader io.Reader) (*data.ImportResult, error)
+
+	GetImportProfiles(*data.User) ([]*data.ImportProfile, error)
+	GetImportProfile(user *data.User, profileUUID string) (*data.ImportProfile, error)
+	CreateImportProfile(*data.User, *data.ImportProfile) error
+	UpdateImportProfile(*data.User, *data.ImportProfile) error
+	DeleteImportProfile(user *data.User, profileUUID string) error
+	ImportCSV(user *data.User, profileUUID string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error)
+	ImportOFX(user *data.User, accountUUID string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error)
+	ImportCamt(user *data.User, accountUUID string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error)
+	ImportMT940(user *data.User, accountUUID string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error)
+	ImportQIF(user *data.User, accountUUID string, currency string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error)
+	ImportJournal(user *data.User, format string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error)
+
+	ExportJournal(user *data.User, format string, options data.TransactionFilterOptions, w io.Writer) error
+	ExportCSV(user *data.User, options data.TransactionFilterOptions, w io.Writer) error
+
+	GetRecurringTransactions(*data.User) ([]*data.RecurringTransaction, error)
+	GetRecurringTransaction(user *data.User, recurringUUID string) (*data.RecurringTransaction, error)
+	CreateRecurringTransaction(*data.User, *data.RecurringTransaction) error
+	UpdateRecurringTransaction(*data.User, *data.RecurringTransaction) error
+	DeleteRecurringTransaction(user *data.User, recurringUUID string) error
+	SkipRecurringTransaction(user *data.User, recurringUUID string) (*data.RecurringTransaction, error)
+	PauseRecurringTransaction(user *data.User, recurringUUID string, paused bool) (*data.RecurringTransaction, error)
+	MaterializeRecurringTransactions(today time.Time) (int, error)
+
+	Backup(user *data.User, w io.Writer, options data.BackupOptions) error
+	Restore(user *data.User, r io.Reader, options data.RestoreOptions) (*data.RestoreResult, error)
 }
 
 // AuthHandler handles authentication and authentication cookies.
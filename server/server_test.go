@@ -2,7 +2,9 @@ package server
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/akrylysov/pogreb"
 	"github.com/akrylysov/pogreb/fs"
@@ -66,6 +68,26 @@ func (m *DBMock) GetTransactions(user *data.User, options data.GetTransactionOpt
 	return returnTransactions, args.Error(1)
 }
 
+func (m *DBMock) GetTransactionsPage(user *data.User, options data.GetTransactionOptions) (*data.TransactionsPage, error) {
+	args := m.Called(user, options)
+	page := args.Get(0)
+	var returnPage *data.TransactionsPage
+	if page != nil {
+		returnPage = page.(*data.TransactionsPage)
+	}
+	return returnPage, args.Error(1)
+}
+
+func (m *DBMock) SearchTransactions(user *data.User, options data.SearchTransactionOptions) (*data.SearchResult, error) {
+	args := m.Called(user, options)
+	result := args.Get(0)
+	var returnResult *data.SearchResult
+	if result != nil {
+		returnResult = result.(*data.SearchResult)
+	}
+	return returnResult, args.Error(1)
+}
+
 func (m *DBMock) CreateAccount(user *data.User, account *data.Account) error {
 	args := m.Called(user, account)
 	return args.Error(0)
@@ -96,11 +118,21 @@ func (m *DBMock) CreateTransaction(user *data.User, transaction *data.Transactio
 	return args.Error(0)
 }
 
-func (m *DBMock) UpdateTransaction(user *data.User, transaction *data.Transaction) error {
-	args := m.Called(user, transaction)
+func (m *DBMock) UpdateTransaction(user *data.User, transaction *data.Transaction, overrideReconciled bool) error {
+	args := m.Called(user, transaction, overrideReconciled)
 	return args.Error(0)
 }
 
+func (m *DBMock) FindDuplicates(user *data.User, transaction *data.Transaction, dateWindow int) ([]*data.Transaction, error) {
+	args := m.Called(user, transaction, dateWindow)
+	duplicates := args.Get(0)
+	var returnDuplicates []*data.Transaction
+	if duplicates != nil {
+		returnDuplicates = duplicates.([]*data.Transaction)
+	}
+	return returnDuplicates, args.Error(1)
+}
+
 func (m *DBMock) GetTransaction(user *data.User, transactionUUID string) (*data.Transaction, error) {
 	args := m.Called(user, transactionUUID)
 	transaction := args.Get(0)
@@ -111,26 +143,336 @@ func (m *DBMock) GetTransaction(user *data.User, transactionUUID string) (*data.
 	return returnTransaction, args.Error(1)
 }
 
-func (m *DBMock) DeleteTransaction(user *data.User, transactionUUID string) error {
-	args := m.Called(user, transactionUUID)
+func (m *DBMock) DeleteTransaction(user *data.User, transactionUUID string, overrideReconciled bool) error {
+	args := m.Called(user, transactionUUID, overrideReconciled)
 	return args.Error(0)
 }
 
+func (m *DBMock) Reconcile(user *data.User, accountUUID string, statementDate string, closingBalance int64) (*data.Reconciliation, error) {
+	args := m.Called(user, accountUUID, statementDate, closingBalance)
+	reconciliation := args.Get(0)
+	var returnReconciliation *data.Reconciliation
+	if reconciliation != nil {
+		returnReconciliation = reconciliation.(*data.Reconciliation)
+	}
+	return returnReconciliation, args.Error(1)
+}
+
+func (m *DBMock) FinishReconciliation(user *data.User, accountUUID string, statementDate string, closingBalance int64) (*data.Reconciliation, error) {
+	args := m.Called(user, accountUUID, statementDate, closingBalance)
+	reconciliation := args.Get(0)
+	var returnReconciliation *data.Reconciliation
+	if reconciliation != nil {
+		returnReconciliation = reconciliation.(*data.Reconciliation)
+	}
+	return returnReconciliation, args.Error(1)
+}
+
 func (m *DBMock) GetTags(user *data.User) ([]string, error) {
 	args := m.Called(user)
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *DBMock) Backup(user *data.User) (string, error) {
+func (m *DBMock) ExportJournal(user *data.User, format string, options data.TransactionFilterOptions, w io.Writer) error {
+	args := m.Called(user, format, options)
+	if _, err := io.WriteString(w, args.String(0)); err != nil {
+		return err
+	}
+	return args.Error(1)
+}
+
+func (m *DBMock) ExportCSV(user *data.User, options data.TransactionFilterOptions, w io.Writer) error {
+	args := m.Called(user, options)
+	if _, err := io.WriteString(w, args.String(0)); err != nil {
+		return err
+	}
+	return args.Error(1)
+}
+
+func (m *DBMock) Backup(user *data.User, w io.Writer, options data.BackupOptions) error {
+	args := m.Called(user, options)
+	if _, err := io.WriteString(w, args.String(0)); err != nil {
+		return err
+	}
+	return args.Error(1)
+}
+
+func (m *DBMock) Restore(user *data.User, r io.Reader, options data.RestoreOptions) (*data.RestoreResult, error) {
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, string(value), options)
+	result := args.Get(0)
+	var returnResult *data.RestoreResult
+	if result != nil {
+		returnResult = result.(*data.RestoreResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) GetBudgets(user *data.User) ([]*data.Budget, error) {
 	args := m.Called(user)
-	return args.Get(0).(string), args.Error(1)
+	budgets := args.Get(0)
+	var returnBudgets []*data.Budget
+	if budgets != nil {
+		returnBudgets = budgets.([]*data.Budget)
+	}
+	return returnBudgets, args.Error(1)
+}
+
+func (m *DBMock) GetBudget(user *data.User, budgetUUID string) (*data.Budget, error) {
+	args := m.Called(user, budgetUUID)
+	budget := args.Get(0)
+	var returnBudget *data.Budget
+	if budget != nil {
+		returnBudget = budget.(*data.Budget)
+	}
+	return returnBudget, args.Error(1)
+}
+
+func (m *DBMock) CreateBudget(user *data.User, budget *data.Budget) error {
+	args := m.Called(user, budget)
+	return args.Error(0)
+}
+
+func (m *DBMock) UpdateBudget(user *data.User, budget *data.Budget) error {
+	args := m.Called(user, budget)
+	return args.Error(0)
+}
+
+func (m *DBMock) DeleteBudget(user *data.User, budgetUUID string) error {
+	args := m.Called(user, budgetUUID)
+	return args.Error(0)
+}
+
+func (m *DBMock) GetExchangeRates(user *data.User) ([]*data.ExchangeRate, error) {
+	args := m.Called(user)
+	rates := args.Get(0)
+	var returnRates []*data.ExchangeRate
+	if rates != nil {
+		returnRates = rates.([]*data.ExchangeRate)
+	}
+	return returnRates, args.Error(1)
+}
+
+func (m *DBMock) SaveExchangeRates(user *data.User, rates []*data.ExchangeRate) error {
+	args := m.Called(user, rates)
+	return args.Error(0)
+}
+
+func (m *DBMock) DeleteExchangeRate(user *data.User, from, to, date string) error {
+	args := m.Called(user, from, to, date)
+	return args.Error(0)
+}
+
+func (m *DBMock) ImportExchangeRatesCSV(user *data.User, base string, reader io.Reader) (*data.ImportResult, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, base, string(value))
+	result := args.Get(0)
+	var returnResult *data.ImportResult
+	if result != nil {
+		returnResult = result.(*data.ImportResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) ImportExchangeRatesECB(user *data.User, reader io.Reader) (*data.ImportResult, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, string(value))
+	result := args.Get(0)
+	var returnResult *data.ImportResult
+	if result != nil {
+		returnResult = result.(*data.ImportResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) GetImportProfiles(user *data.User) ([]*data.ImportProfile, error) {
+	args := m.Called(user)
+	profiles := args.Get(0)
+	var returnProfiles []*data.ImportProfile
+	if profiles != nil {
+		returnProfiles = profiles.([]*data.ImportProfile)
+	}
+	return returnProfiles, args.Error(1)
+}
+
+func (m *DBMock) GetImportProfile(user *data.User, profileUUID string) (*data.ImportProfile, error) {
+	args := m.Called(user, profileUUID)
+	profile := args.Get(0)
+	var returnProfile *data.ImportProfile
+	if profile != nil {
+		returnProfile = profile.(*data.ImportProfile)
+	}
+	return returnProfile, args.Error(1)
+}
+
+func (m *DBMock) CreateImportProfile(user *data.User, profile *data.ImportProfile) error {
+	args := m.Called(user, profile)
+	return args.Error(0)
+}
+
+func (m *DBMock) UpdateImportProfile(user *data.User, profile *data.ImportProfile) error {
+	args := m.Called(user, profile)
+	return args.Error(0)
+}
+
+func (m *DBMock) DeleteImportProfile(user *data.User, profileUUID string) error {
+	args := m.Called(user, profileUUID)
+	return args.Error(0)
+}
+
+func (m *DBMock) ImportCSV(user *data.User, profileUUID string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, profileUUID, duplicates, string(value))
+	result := args.Get(0)
+	var returnResult *data.ImportResult
+	if result != nil {
+		returnResult = result.(*data.ImportResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) ImportOFX(user *data.User, accountUUID string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, accountUUID, duplicates, string(value))
+	result := args.Get(0)
+	var returnResult *data.ImportResult
+	if result != nil {
+		returnResult = result.(*data.ImportResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) ImportCamt(user *data.User, accountUUID string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, accountUUID, duplicates, string(value))
+	result := args.Get(0)
+	var returnResult *data.ImportResult
+	if result != nil {
+		returnResult = result.(*data.ImportResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) ImportMT940(user *data.User, accountUUID string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, accountUUID, duplicates, string(value))
+	result := args.Get(0)
+	var returnResult *data.ImportResult
+	if result != nil {
+		returnResult = result.(*data.ImportResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) ImportQIF(user *data.User, accountUUID string, currency string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, accountUUID, currency, duplicates, string(value))
+	result := args.Get(0)
+	var returnResult *data.ImportResult
+	if result != nil {
+		returnResult = result.(*data.ImportResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) ImportJournal(user *data.User, format string, duplicates data.DuplicateOptions, reader io.Reader) (*data.ImportResult, error) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(user, format, duplicates, string(value))
+	result := args.Get(0)
+	var returnResult *data.ImportResult
+	if result != nil {
+		returnResult = result.(*data.ImportResult)
+	}
+	return returnResult, args.Error(1)
+}
+
+func (m *DBMock) GetRecurringTransactions(user *data.User) ([]*data.RecurringTransaction, error) {
+	args := m.Called(user)
+	recurringTransactions := args.Get(0)
+	var returnRecurringTransactions []*data.RecurringTransaction
+	if recurringTransactions != nil {
+		returnRecurringTransactions = recurringTransactions.([]*data.RecurringTransaction)
+	}
+	return returnRecurringTransactions, args.Error(1)
 }
 
-func (m *DBMock) Restore(user *data.User, value string) error {
-	args := m.Called(user, value)
+func (m *DBMock) GetRecurringTransaction(user *data.User, recurringUUID string) (*data.RecurringTransaction, error) {
+	args := m.Called(user, recurringUUID)
+	recurring := args.Get(0)
+	var returnRecurring *data.RecurringTransaction
+	if recurring != nil {
+		returnRecurring = recurring.(*data.RecurringTransaction)
+	}
+	return returnRecurring, args.Error(1)
+}
+
+func (m *DBMock) CreateRecurringTransaction(user *data.User, recurring *data.RecurringTransaction) error {
+	args := m.Called(user, recurring)
+	return args.Error(0)
+}
+
+func (m *DBMock) UpdateRecurringTransaction(user *data.User, recurring *data.RecurringTransaction) error {
+	args := m.Called(user, recurring)
+	return args.Error(0)
+}
+
+func (m *DBMock) DeleteRecurringTransaction(user *data.User, recurringUUID string) error {
+	args := m.Called(user, recurringUUID)
 	return args.Error(0)
 }
 
+func (m *DBMock) SkipRecurringTransaction(user *data.User, recurringUUID string) (*data.RecurringTransaction, error) {
+	args := m.Called(user, recurringUUID)
+	recurring := args.Get(0)
+	var returnRecurring *data.RecurringTransaction
+	if recurring != nil {
+		returnRecurring = recurring.(*data.RecurringTransaction)
+	}
+	return returnRecurring, args.Error(1)
+}
+
+func (m *DBMock) PauseRecurringTransaction(user *data.User, recurringUUID string, paused bool) (*data.RecurringTransaction, error) {
+	args := m.Called(user, recurringUUID, paused)
+	recurring := args.Get(0)
+	var returnRecurring *data.RecurringTransaction
+	if recurring != nil {
+		returnRecurring = recurring.(*data.RecurringTransaction)
+	}
+	return returnRecurring, args.Error(1)
+}
+
+func (m *DBMock) MaterializeRecurringTransactions(today time.Time) (int, error) {
+	args := m.Called(today)
+	return args.Int(0), args.Error(1)
+}
+
 var testAuthCookie = "testusername"
 
 type AuthHandlerMock struct {
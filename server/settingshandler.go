@@ -1,20 +1,73 @@
 package server
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/zlogic/vogon-go/data"
 	"github.com/zlogic/vogon-go/server/auth"
 )
 
+// updateUserSettings updates the username and password of user from form values.
+// If the username was changed, the user is logged out.
+func updateUserSettings(s *Services, w http.ResponseWriter, user *data.User, values url.Values) error {
+	newPassword := values.Get("Password")
+	if newPassword != "" {
+		user.SetPassword(newPassword)
+	}
+
+	newUsername := values.Get("Username")
+	if err := user.SetUsername(newUsername); err != nil {
+		return err
+	}
+
+	if err := s.db.SaveUser(user); err != nil {
+		return err
+	}
+
+	if user.GetUsername() != newUsername {
+		// Force logout.
+		err := s.cookieHandler.SetCookieUsername(w, "", false)
+		if err != nil {
+			log.WithError(err).Error("Error while clearing the cookie during logout")
+		}
+	}
+	return nil
+}
+
+// parseRestoreOptions returns the restore options from form values.
+func parseRestoreOptions(values url.Values) (data.RestoreOptions, error) {
+	mode, err := data.ParseRestoreMode(values.Get("RestoreMode"))
+	if err != nil {
+		return data.RestoreOptions{}, err
+	}
+	conflicts, err := data.ParseRestoreConflict(values.Get("RestoreConflicts"))
+	if err != nil {
+		return data.RestoreOptions{}, err
+	}
+	dryRun := false
+	if value := values.Get("RestoreDryRun"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			return data.RestoreOptions{}, err
+		}
+	}
+	return data.RestoreOptions{Mode: mode, Conflicts: conflicts, DryRun: dryRun, Passphrase: values.Get("RestorePassphrase")}, nil
+}
+
 // SettingsHandler gets or updates settings for an authenticated user.
+// If a backup is restored, the response includes the number of restored records.
+// If RestoreDryRun is true, settings are not saved, and the response includes the changes the restore would make.
+// The multipart form is processed as it's received: the restorefile part is restored while it's being uploaded,
+// and only the form part (which has to be sent first) is limited by maxUploadSize.
 func SettingsHandler(s *Services, maxUploadSize int64) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := auth.GetUser(r.Context())
@@ -23,70 +76,79 @@ func SettingsHandler(s *Services, maxUploadSize int64) func(w http.ResponseWrite
 			return
 		}
 
+		var restoreResult *data.RestoreResult
 		if r.Method == http.MethodPost {
-			if err := r.ParseMultipartForm(maxUploadSize); err != nil {
-				handleError(w, r, err)
-				return
-			}
-
-			formPart, ok := r.MultipartForm.Value["form"]
-			defer r.MultipartForm.RemoveAll()
-			if !ok {
-				err := fmt.Errorf("cannot extract form part")
-				handleError(w, r, err)
-				return
-			}
-			values, err := url.ParseQuery(formPart[0])
-			if err != nil {
-				handleError(w, r, err)
-				return
-			}
-
-			newPassword := values.Get("Password")
-			if newPassword != "" {
-				user.SetPassword(newPassword)
-			}
-
-			newUsername := values.Get("Username")
-			err = user.SetUsername(newUsername)
+			reader, err := r.MultipartReader()
 			if err != nil {
 				handleError(w, r, err)
 				return
 			}
 
-			if err := s.db.SaveUser(user); err != nil {
-				handleError(w, r, err)
-				return
-			}
-
-			if user.GetUsername() != newUsername {
-				// Force logout.
-				err := s.cookieHandler.SetCookieUsername(w, "", false)
-				if err != nil {
-					log.WithError(err).Error("Error while clearing the cookie during logout")
-				}
-			}
-
-			restoreFile, ok := r.MultipartForm.File["restorefile"]
-			if ok {
-				file, err := restoreFile[0].Open()
-				if err != nil {
-					handleError(w, r, err)
-					return
-				}
-				defer file.Close()
-
-				data, err := io.ReadAll(file)
-				if err != nil {
+			formSaved := false
+			var restoreOptions data.RestoreOptions
+			for {
+				part, err := reader.NextPart()
+				if err == io.EOF {
+					break
+				} else if err != nil {
 					handleError(w, r, err)
 					return
 				}
 
-				if err := s.db.Restore(user, string(data)); err != nil {
-					handleError(w, r, err)
-					return
+				switch part.FormName() {
+				case "form":
+					form, err := io.ReadAll(io.LimitReader(part, maxUploadSize+1))
+					if err != nil {
+						handleError(w, r, err)
+						return
+					}
+					if int64(len(form)) > maxUploadSize {
+						handleError(w, r, fmt.Errorf("form part is larger than %v bytes", maxUploadSize))
+						return
+					}
+					values, err := url.ParseQuery(string(form))
+					if err != nil {
+						handleError(w, r, err)
+						return
+					}
+					restoreOptions, err = parseRestoreOptions(values)
+					if err != nil {
+						handleError(w, r, err)
+						return
+					}
+					if !restoreOptions.DryRun {
+						if err := updateUserSettings(s, w, user, values); err != nil {
+							handleError(w, r, err)
+							return
+						}
+					}
+					formSaved = true
+				case "restorefile":
+					if !formSaved {
+						handleError(w, r, fmt.Errorf("form part should be sent before the restore file"))
+						return
+					}
+					restoreResult, err = s.db.Restore(user, part, restoreOptions)
+					if errors.Is(err, data.ErrBackupPassphraseRequired) {
+						http.Error(w, "Backup is encrypted, a passphrase is required", http.StatusBadRequest)
+						return
+					} else if errors.Is(err, data.ErrBackupWrongPassphrase) {
+						http.Error(w, "Wrong backup passphrase", http.StatusBadRequest)
+						return
+					} else if errors.Is(err, data.ErrBackupCorrupted) {
+						http.Error(w, "Backup is corrupted or was modified", http.StatusBadRequest)
+						return
+					} else if err != nil {
+						handleError(w, r, err)
+						return
+					}
 				}
 			}
+			if !formSaved {
+				err := fmt.Errorf("cannot extract form part")
+				handleError(w, r, err)
+				return
+			}
 
 			// Reload user to return updated values.
 			user, err = s.db.GetUser(user.GetUsername())
@@ -98,9 +160,10 @@ func SettingsHandler(s *Services, maxUploadSize int64) func(w http.ResponseWrite
 
 		type clientUser struct {
 			Username string
+			Restore  *data.RestoreResult `json:",omitempty"`
 		}
 
-		returnUser := &clientUser{Username: user.GetUsername()}
+		returnUser := &clientUser{Username: user.GetUsername(), Restore: restoreResult}
 
 		w.Header().Add("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(returnUser); err != nil {
@@ -110,6 +173,8 @@ func SettingsHandler(s *Services, maxUploadSize int64) func(w http.ResponseWrite
 }
 
 // BackupHandler returns a serialized backup of all data for an authenticated user.
+// The backup is streamed as it's being created; if the compress form value is true, the backup is compressed with gzip.
+// If the passphrase form value is not empty, the backup is encrypted with the passphrase.
 func BackupHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := auth.GetUser(r.Context())
@@ -118,17 +183,39 @@ func BackupHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		data, err := s.db.Backup(user)
-		if err != nil {
+		if err := r.ParseForm(); err != nil {
 			handleError(w, r, err)
 			return
 		}
 
-		currentTime := time.Now()
-		filename := "vogon-" + currentTime.Format(time.RFC3339) + ".json"
+		options := data.BackupOptions{}
+		if value := r.Form.Get("compress"); value != "" {
+			var err error
+			options.Compress, err = strconv.ParseBool(value)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+		}
 
-		w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+		options.Passphrase = r.Form.Get("passphrase")
 
-		http.ServeContent(w, r, filename, currentTime, bytes.NewReader([]byte(data)))
+		filename := "vogon-" + time.Now().Format(time.RFC3339) + ".json"
+		contentType := "application/json"
+		if options.Compress {
+			filename += ".gz"
+			contentType = "application/gzip"
+		}
+		if options.Passphrase != "" {
+			filename += ".enc"
+			contentType = "application/octet-stream"
+		}
+
+		w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+		w.Header().Set("Content-Type", contentType)
+		sw := &streamWriter{ResponseWriter: w}
+		if err := s.db.Backup(user, sw, options); err != nil {
+			handleStreamError(sw, r, err)
+		}
 	}
 }
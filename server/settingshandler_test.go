@@ -6,6 +6,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -229,11 +230,178 @@ func TestSaveSettingsRestoreBackupAuthorized(t *testing.T) {
 
 	saveUser := user
 	dbMock.On("SaveUser", saveUser).Return(nil).Once()
-	dbMock.On("Restore", user, "json backup").Return(nil).Once()
+	dbMock.On("Restore", user, "json backup", data.RestoreOptions{}).Return(&data.RestoreResult{Inserted: 3}, nil).Once()
 
 	router.ServeHTTP(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
-	assert.Equal(t, `{"Username":"user01"}`+"\n", res.Body.String())
+	assert.Equal(t, `{"Username":"user01","Restore":{"Inserted":3,"Updated":0,"Skipped":0}}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestSaveSettingsMergeBackupAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	user := prepareExistingUser("user01")
+	assert.NotNil(t, user)
+	user.SetPassword("pass")
+	dbMock.On("GetUser", "user01").Return(user, nil).Once()
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	writer.WriteField("form", "Username=user01&RestoreMode=merge&RestoreConflicts=backup")
+	fileWriter, err := writer.CreateFormFile("restorefile", "backup.json")
+	assert.NoError(t, err)
+	_, err = fileWriter.Write([]byte("json backup"))
+	writer.Close()
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/settings", body)
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+	res := httptest.NewRecorder()
+
+	authHandler.AllowUser(user)
+
+	saveUser := user
+	dbMock.On("SaveUser", saveUser).Return(nil).Once()
+	dbMock.On("Restore", user, "json backup", data.RestoreOptions{Mode: data.RestoreMerge, Conflicts: data.RestoreUseBackup}).Return(&data.RestoreResult{Inserted: 1, Updated: 2, Skipped: 3}, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Username":"user01","Restore":{"Inserted":1,"Updated":2,"Skipped":3}}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestSaveSettingsRestoreBackupDryRunAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	user := prepareExistingUser("user01")
+	assert.NotNil(t, user)
+	dbMock.On("GetUser", "user01").Return(user, nil).Once()
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	writer.WriteField("form", "Username=user02&Password=pass&RestoreDryRun=true")
+	fileWriter, err := writer.CreateFormFile("restorefile", "backup.json")
+	assert.NoError(t, err)
+	_, err = fileWriter.Write([]byte("json backup"))
+	writer.Close()
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/settings", body)
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+	res := httptest.NewRecorder()
+
+	authHandler.AllowUser(user)
+
+	diff := &data.RestoreDiff{
+		AddedAccounts: []*data.Account{{UUID: "uuid5", Name: "Blue Bank", Currency: "PLN"}},
+		Accounts:      []*data.Account{{UUID: "uuid5", Name: "Blue Bank", Currency: "PLN", Balance: 100}},
+		Problems:      []string{"transaction uuid6 references missing account uuid9"},
+	}
+	dbMock.On("Restore", user, "json backup", data.RestoreOptions{DryRun: true}).Return(&data.RestoreResult{Inserted: 1, Diff: diff}, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Username":"user01","Restore":{"Inserted":1,"Updated":0,"Skipped":0,"Diff":{`+
+		`"AddedAccounts":[{"UUID":"uuid5","Name":"Blue Bank","Balance":0,"Currency":"PLN","IncludeInTotal":false,"ShowInList":false}],`+
+		`"RemovedAccounts":null,"ChangedAccounts":null,"AddedTransactions":null,"RemovedTransactions":null,"ChangedTransactions":null,`+
+		`"Accounts":[{"UUID":"uuid5","Name":"Blue Bank","Balance":100,"Currency":"PLN","IncludeInTotal":false,"ShowInList":false}],`+
+		`"Problems":["transaction uuid6 references missing account uuid9"]}}}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestSaveSettingsRestoreBackupErrorsAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	user := prepareExistingUser("user01")
+	assert.NotNil(t, user)
+
+	for _, test := range []struct {
+		err      error
+		status   int
+		response string
+	}{
+		{data.ErrBackupPassphraseRequired, http.StatusBadRequest, "Backup is encrypted, a passphrase is required\n"},
+		{data.ErrBackupWrongPassphrase, http.StatusBadRequest, "Wrong backup passphrase\n"},
+		{data.ErrBackupCorrupted, http.StatusBadRequest, "Backup is corrupted or was modified\n"},
+	} {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		writer.WriteField("form", "Username=user01&RestoreDryRun=true&RestorePassphrase=secret")
+		fileWriter, err := writer.CreateFormFile("restorefile", "backup.json.enc")
+		assert.NoError(t, err)
+		_, err = fileWriter.Write([]byte("encrypted backup"))
+		writer.Close()
+		assert.NoError(t, err)
+
+		req, _ := http.NewRequest("POST", "/api/settings", body)
+		req.Header.Add("Content-Type", writer.FormDataContentType())
+		res := httptest.NewRecorder()
+
+		authHandler.AllowUser(user)
+
+		restoreErr := fmt.Errorf("failed to restore backup: %w", test.err)
+		dbMock.On("Restore", user, "encrypted backup", data.RestoreOptions{DryRun: true, Passphrase: "secret"}).Return(nil, restoreErr).Once()
+
+		router.ServeHTTP(res, req)
+		assert.Equal(t, test.status, res.Code)
+		assert.Equal(t, test.response, res.Body.String())
+	}
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestSaveSettingsRestoreBackupBeforeFormAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	user := prepareExistingUser("user01")
+	assert.NotNil(t, user)
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	fileWriter, err := writer.CreateFormFile("restorefile", "backup.json")
+	assert.NoError(t, err)
+	_, err = fileWriter.Write([]byte("json backup"))
+	assert.NoError(t, err)
+	writer.WriteField("form", "Username=user01")
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/api/settings", body)
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+	res := httptest.NewRecorder()
+
+	authHandler.AllowUser(user)
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Equal(t, "Internal server error\n", res.Body.String())
 
 	dbMock.AssertExpectations(t)
 	authHandler.AssertExpectations(t)
@@ -272,22 +440,79 @@ func TestBackupAuthorized(t *testing.T) {
 	router, err := CreateRouter(services)
 	assert.NoError(t, err)
 
-	req, _ := http.NewRequest("POST", "/api/backup", nil)
+	req, _ := http.NewRequest("POST", "/api/backup", strings.NewReader(""))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	res := httptest.NewRecorder()
 
 	user := testUser
 	authHandler.AllowUser(&user)
 
-	dbMock.On("Backup", &user).Return("json backup", nil).Once()
+	dbMock.On("Backup", &user, data.BackupOptions{}).Return("json backup", nil).Once()
 
 	router.ServeHTTP(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename=vogon-.+\.json$`, res.Header().Get("Content-Disposition"))
 	assert.Equal(t, "json backup", res.Body.String())
 
 	dbMock.AssertExpectations(t)
 	authHandler.AssertExpectations(t)
 }
 
+func TestBackupCompressedAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/backup", strings.NewReader("compress=true"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("Backup", &user, data.BackupOptions{Compress: true}).Return("gzip backup", nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/gzip", res.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename=vogon-.+\.json\.gz$`, res.Header().Get("Content-Disposition"))
+	assert.Equal(t, "gzip backup", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestBackupEncryptedAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/backup", strings.NewReader("compress=true&passphrase=secret"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("Backup", &user, data.BackupOptions{Compress: true, Passphrase: "secret"}).Return("encrypted backup", nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/octet-stream", res.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename=vogon-.+\.json\.gz\.enc$`, res.Header().Get("Content-Disposition"))
+	assert.Equal(t, "encrypted backup", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
 func TestBackupUnauthorized(t *testing.T) {
 	dbMock := new(DBMock)
 	authHandler := AuthHandlerMock{}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -54,6 +55,24 @@ func parseFilterForm(r *http.Request) (data.TransactionFilterOptions, error) {
 	}, nil
 }
 
+// parseFormValueUint parses an unsigned integer form value, which cannot be empty.
+func parseFormValueUint(r *http.Request, name string) (uint64, error) {
+	value := r.Form.Get(name)
+	if value == "" {
+		return 0, fmt.Errorf("form parameter %v is empty", name)
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// parseOverrideReconciled parses the optional overrideReconciled query parameter.
+func parseOverrideReconciled(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("overrideReconciled")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
 // TransactionsCountHandler returns the number of transactions for an authenticated user.
 func TransactionsCountHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -88,6 +107,8 @@ func TransactionsCountHandler(s *Services) func(w http.ResponseWriter, r *http.R
 }
 
 // TransactionsHandler returns a filtered, pages list of transactions for an authenticated user.
+// The response contains the Transactions and the NextCursor to request the next page,
+// which is also returned in the X-Next-Cursor header; NextCursor is empty if there are no more transactions.
 func TransactionsHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := auth.GetUser(r.Context())
@@ -101,21 +122,75 @@ func TransactionsHandler(s *Services) func(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		parseFormValueInt := func(name string) (uint64, error) {
-			value := r.Form.Get(name)
-			if value == "" {
-				return 0, fmt.Errorf("form parameter %v is empty", name)
+		cursor := r.Form.Get("cursor")
+		var offset uint64
+		var err error
+		if cursor == "" || r.Form.Get("offset") != "" {
+			// When a cursor is specified, offset is optional.
+			offset, err = parseFormValueUint(r, "offset")
+			if err != nil {
+				handleError(w, r, err)
+				return
 			}
-			return strconv.ParseUint(value, 10, 64)
 		}
 
-		offset, err := parseFormValueInt("offset")
+		limit, err := parseFormValueUint(r, "limit")
 		if err != nil {
 			handleError(w, r, err)
 			return
 		}
 
-		limit, err := parseFormValueInt("limit")
+		filterOptions, err := parseFilterForm(r)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		options := data.GetTransactionOptions{
+			Cursor:                   cursor,
+			Offset:                   offset,
+			Limit:                    limit,
+			TransactionFilterOptions: filterOptions,
+		}
+		page, err := s.db.GetTransactionsPage(user, options)
+		if errors.Is(err, data.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		if page.NextCursor != "" {
+			w.Header().Set("X-Next-Cursor", page.NextCursor)
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
+
+// SearchTransactionsHandler returns a ranked, paged list of transactions matching a search query for an authenticated user.
+func SearchTransactionsHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		offset, err := parseFormValueUint(r, "offset")
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		limit, err := parseFormValueUint(r, "limit")
 		if err != nil {
 			handleError(w, r, err)
 			return
@@ -126,25 +201,27 @@ func TransactionsHandler(s *Services) func(w http.ResponseWriter, r *http.Reques
 			handleError(w, r, err)
 			return
 		}
-		options := data.GetTransactionOptions{
+		options := data.SearchTransactionOptions{
+			Query:                    r.Form.Get("query"),
 			Offset:                   offset,
 			Limit:                    limit,
 			TransactionFilterOptions: filterOptions,
 		}
-		transactions, err := s.db.GetTransactions(user, options)
+		result, err := s.db.SearchTransactions(user, options)
 		if err != nil {
 			handleError(w, r, err)
 			return
 		}
 
 		w.Header().Add("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(transactions); err != nil {
+		if err := json.NewEncoder(w).Encode(result); err != nil {
 			handleError(w, r, err)
 		}
 	}
 }
 
 // TransactionHandler gets, updates or deletes a Transaction.
+// Updates changing reconciled components are rejected, unless the overrideReconciled query parameter is true.
 func TransactionHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := auth.GetUser(r.Context())
@@ -165,11 +242,33 @@ func TransactionHandler(s *Services) func(w http.ResponseWriter, r *http.Request
 			}
 
 			if requestUUID == "new" {
+				var duplicates []*data.Transaction
+				duplicates, err = s.db.FindDuplicates(user, transaction, data.DefaultDuplicateDateWindow)
+				if err != nil {
+					handleError(w, r, err)
+					return
+				}
+				if len(duplicates) > 0 {
+					duplicateUUIDs := make([]string, len(duplicates))
+					for i, duplicate := range duplicates {
+						duplicateUUIDs[i] = duplicate.UUID
+					}
+					w.Header().Set("X-Possible-Duplicates", strings.Join(duplicateUUIDs, ","))
+				}
 				err = s.db.CreateTransaction(user, transaction)
 			} else {
-				err = s.db.UpdateTransaction(user, transaction)
+				var overrideReconciled bool
+				overrideReconciled, err = parseOverrideReconciled(r)
+				if err != nil {
+					handleError(w, r, err)
+					return
+				}
+				err = s.db.UpdateTransaction(user, transaction, overrideReconciled)
 			}
-			if err != nil {
+			if errors.Is(err, data.ErrReconciledComponent) {
+				http.Error(w, "Transaction has reconciled components", http.StatusConflict)
+				return
+			} else if err != nil {
 				handleError(w, r, err)
 				return
 			}
@@ -182,7 +281,16 @@ func TransactionHandler(s *Services) func(w http.ResponseWriter, r *http.Request
 		}
 
 		if r.Method == http.MethodDelete {
-			if err := s.db.DeleteTransaction(user, requestUUID); err != nil {
+			overrideReconciled, err := parseOverrideReconciled(r)
+			if err != nil {
+				handleError(w, r, err)
+				return
+			}
+			err = s.db.DeleteTransaction(user, requestUUID, overrideReconciled)
+			if errors.Is(err, data.ErrReconciledComponent) {
+				http.Error(w, "Transaction has reconciled components", http.StatusConflict)
+				return
+			} else if err != nil {
 				handleError(w, r, err)
 				return
 			}
@@ -210,3 +318,37 @@ func TransactionHandler(s *Services) func(w http.ResponseWriter, r *http.Request
 		}
 	}
 }
+
+// DuplicatesHandler returns existing transactions which are likely duplicates of a transaction for an authenticated user.
+func DuplicatesHandler(s *Services) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUser(r.Context())
+		if user == nil {
+			// This should never happen.
+			return
+		}
+
+		transaction := &data.Transaction{}
+		if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		dateWindow, err := parseDuplicateWindow(r)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		duplicates, err := s.db.FindDuplicates(user, transaction, dateWindow)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(duplicates); err != nil {
+			handleError(w, r, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -78,16 +79,16 @@ func TestGetTransactionsAuthorized(t *testing.T) {
 
 	transactions := createTestTransactions()
 	options := data.GetTransactionOptions{Offset: 0, Limit: 10}
-	dbMock.On("GetTransactions", &user, options).Return(transactions, nil).Once()
+	dbMock.On("GetTransactionsPage", &user, options).Return(&data.TransactionsPage{Transactions: transactions}, nil).Once()
 
 	router.ServeHTTP(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
-	assert.Equal(t, "["+
+	assert.Equal(t, `{"Transactions":[`+
 		`{"UUID":"uuid4","Description":"Gadgets 2","Type":1,"Tags":["Gadgets"],"Date":"2015-11-03","Components":[]}`+","+
 		`{"UUID":"uuid3","Description":"Gadgets","Type":0,"Tags":["Gadgets","Widgets"],"Date":"2015-11-03","Components":[]}`+","+
 		`{"UUID":"uuid1","Description":"Widgets","Type":0,"Tags":["Widgets"],"Date":"2015-11-02","Components":[{"Amount":-10000,"AccountUUID":"uuid2"}]}`+","+
 		`{"UUID":"uuid2","Description":"Salary","Type":0,"Tags":["Salary"],"Date":"2015-11-01","Components":[{"Amount":100000,"AccountUUID":"uuid1"},{"Amount":100000,"AccountUUID":"uuid2"}]}`+
-		"]\n", res.Body.String())
+		`],"NextCursor":""}`+"\n", res.Body.String())
 
 	dbMock.AssertExpectations(t)
 	authHandler.AssertExpectations(t)
@@ -122,16 +123,73 @@ func TestGetTransactionsFilterAuthorized(t *testing.T) {
 			ExcludeTransfer:      true,
 		},
 	}
-	dbMock.On("GetTransactions", &user, options).Return(transactions, nil).Once()
+	dbMock.On("GetTransactionsPage", &user, options).Return(&data.TransactionsPage{Transactions: transactions}, nil).Once()
 
 	router.ServeHTTP(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
-	assert.Equal(t, "["+
+	assert.Equal(t, `{"Transactions":[`+
 		`{"UUID":"uuid4","Description":"Gadgets 2","Type":1,"Tags":["Gadgets"],"Date":"2015-11-03","Components":[]}`+","+
 		`{"UUID":"uuid3","Description":"Gadgets","Type":0,"Tags":["Gadgets","Widgets"],"Date":"2015-11-03","Components":[]}`+","+
 		`{"UUID":"uuid1","Description":"Widgets","Type":0,"Tags":["Widgets"],"Date":"2015-11-02","Components":[{"Amount":-10000,"AccountUUID":"uuid2"}]}`+","+
 		`{"UUID":"uuid2","Description":"Salary","Type":0,"Tags":["Salary"],"Date":"2015-11-01","Components":[{"Amount":100000,"AccountUUID":"uuid1"},{"Amount":100000,"AccountUUID":"uuid2"}]}`+
-		"]\n", res.Body.String())
+		`],"NextCursor":""}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetTransactionsCursorAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transactions/getpage", strings.NewReader("cursor=c1&limit=2"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	transactions := createTestTransactions()
+	options := data.GetTransactionOptions{Cursor: "c1", Limit: 2}
+	dbMock.On("GetTransactionsPage", &user, options).Return(&data.TransactionsPage{Transactions: transactions[:2], NextCursor: "c2"}, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "c2", res.Header().Get("X-Next-Cursor"))
+	assert.Equal(t, `{"Transactions":[`+
+		`{"UUID":"uuid4","Description":"Gadgets 2","Type":1,"Tags":["Gadgets"],"Date":"2015-11-03","Components":[]}`+","+
+		`{"UUID":"uuid3","Description":"Gadgets","Type":0,"Tags":["Gadgets","Widgets"],"Date":"2015-11-03","Components":[]}`+
+		`],"NextCursor":"c2"}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetTransactionsInvalidCursorAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transactions/getpage", strings.NewReader("cursor=c1&limit=2"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	options := data.GetTransactionOptions{Cursor: "c1", Limit: 2}
+	dbMock.On("GetTransactionsPage", &user, options).Return(nil, fmt.Errorf("failed to get transactions page: %w", data.ErrInvalidCursor)).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "Invalid cursor\n", res.Body.String())
 
 	dbMock.AssertExpectations(t)
 	authHandler.AssertExpectations(t)
@@ -157,6 +215,63 @@ func TestGetTransactionsUnauthorized(t *testing.T) {
 	authHandler.AssertExpectations(t)
 }
 
+func TestSearchTransactionsAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transactions/search", strings.NewReader("query=gadg&offset=1&limit=10&filterTags=Gadgets&filterIncludeTransfer=false"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	transactions := createTestTransactions()
+	options := data.SearchTransactionOptions{
+		Query:  "gadg",
+		Offset: 1,
+		Limit:  10,
+		TransactionFilterOptions: data.TransactionFilterOptions{
+			FilterTags:      []string{"Gadgets"},
+			ExcludeTransfer: true,
+		},
+	}
+	dbMock.On("SearchTransactions", &user, options).Return(&data.SearchResult{Transactions: transactions[1:2], Total: 2}, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `{"Transactions":[`+
+		`{"UUID":"uuid3","Description":"Gadgets","Type":0,"Tags":["Gadgets","Widgets"],"Date":"2015-11-03","Components":[]}`+
+		`],"Total":2}`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestSearchTransactionsUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transactions/search", strings.NewReader("query=gadg&offset=0&limit=10"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
 func TestGetTransactionsCountAuthorized(t *testing.T) {
 	dbMock := new(DBMock)
 	authHandler := AuthHandlerMock{}
@@ -295,7 +410,40 @@ func TestDeleteTransactionAuthorized(t *testing.T) {
 	user := testUser
 	authHandler.AllowUser(&user)
 
-	dbMock.On("DeleteTransaction", &user, "uuid42").Return(nil).Once()
+	dbMock.On("DeleteTransaction", &user, "uuid42", false).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestDeleteReconciledTransaction(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("DELETE", "/api/transaction/uuid42", nil)
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	dbMock.On("DeleteTransaction", &user, "uuid42", false).Return(fmt.Errorf("cannot delete: %w", data.ErrReconciledComponent)).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Equal(t, "Transaction has reconciled components\n", res.Body.String())
+
+	req, _ = http.NewRequest("DELETE", "/api/transaction/uuid42?overrideReconciled=true", nil)
+	res = httptest.NewRecorder()
+
+	dbMock.On("DeleteTransaction", &user, "uuid42", true).Return(nil).Once()
 
 	router.ServeHTTP(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
@@ -339,11 +487,41 @@ func TestPostCreateTransactionAuthorized(t *testing.T) {
 	authHandler.AllowUser(&user)
 
 	transaction := createTestTransaction()
+	dbMock.On("FindDuplicates", &user, transaction, data.DefaultDuplicateDateWindow).Return([]*data.Transaction{}, nil).Once()
 	dbMock.On("CreateTransaction", &user, transaction).Return(nil).Once()
 
 	router.ServeHTTP(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
 	assert.Equal(t, "OK", res.Body.String())
+	assert.Empty(t, res.Header().Get("X-Possible-Duplicates"))
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestPostCreateTransactionDuplicate(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transaction/new", strings.NewReader(`{"UUID":"uuid42","Description":"Widgets","Type":0,"Tags":["Widgets"],"Date":"2015-11-02","Components":[{"Amount":-10000,"AccountUUID":"uuid2"}]}`))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	transaction := createTestTransaction()
+	duplicates := createTestTransactions()[2:]
+	dbMock.On("FindDuplicates", &user, transaction, data.DefaultDuplicateDateWindow).Return(duplicates, nil).Once()
+	dbMock.On("CreateTransaction", &user, transaction).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+	assert.Equal(t, "uuid1,uuid2", res.Header().Get("X-Possible-Duplicates"))
 
 	dbMock.AssertExpectations(t)
 	authHandler.AssertExpectations(t)
@@ -364,7 +542,42 @@ func TestPostUpdateTransactionAuthorized(t *testing.T) {
 	authHandler.AllowUser(&user)
 
 	transaction := createTestTransaction()
-	dbMock.On("UpdateTransaction", &user, transaction).Return(nil).Once()
+	dbMock.On("UpdateTransaction", &user, transaction, false).Return(nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "OK", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestPostUpdateReconciledTransaction(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	body := `{"UUID":"uuid42","Description":"Widgets","Type":0,"Tags":["Widgets"],"Date":"2015-11-02","Components":[{"Amount":-10000,"AccountUUID":"uuid2"}]}`
+	req, _ := http.NewRequest("POST", "/api/transaction/uuid42", strings.NewReader(body))
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	transaction := createTestTransaction()
+	dbMock.On("UpdateTransaction", &user, transaction, false).Return(fmt.Errorf("cannot update: %w", data.ErrReconciledComponent)).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Equal(t, "Transaction has reconciled components\n", res.Body.String())
+
+	req, _ = http.NewRequest("POST", "/api/transaction/uuid42?overrideReconciled=true", strings.NewReader(body))
+	res = httptest.NewRecorder()
+
+	dbMock.On("UpdateTransaction", &user, transaction, true).Return(nil).Once()
 
 	router.ServeHTTP(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
@@ -392,3 +605,53 @@ func TestPostTransactionUnauthorized(t *testing.T) {
 	dbMock.AssertExpectations(t)
 	authHandler.AssertExpectations(t)
 }
+
+func TestGetDuplicatesAuthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transactions/duplicates?duplicateDateWindow=7", strings.NewReader(`{"Description":"Widgets","Type":0,"Date":"2015-11-02","Components":[{"Amount":-10000,"AccountUUID":"uuid2"}]}`))
+	req.Header.Add("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+
+	user := testUser
+	authHandler.AllowUser(&user)
+
+	transaction := &data.Transaction{
+		Description: "Widgets",
+		Type:        data.TransactionTypeExpenseIncome,
+		Date:        "2015-11-02",
+		Components:  []data.TransactionComponent{{AccountUUID: "uuid2", Amount: -10000}},
+	}
+	dbMock.On("FindDuplicates", &user, transaction, 7).Return([]*data.Transaction{createTestTransaction()}, nil).Once()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `[{"UUID":"uuid42","Description":"Widgets","Type":0,"Tags":["Widgets"],"Date":"2015-11-02","Components":[{"Amount":-10000,"AccountUUID":"uuid2"}]}]`+"\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}
+
+func TestGetDuplicatesUnauthorized(t *testing.T) {
+	dbMock := new(DBMock)
+	authHandler := AuthHandlerMock{}
+
+	services := &Services{db: dbMock, cookieHandler: &authHandler}
+	router, err := CreateRouter(services)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/transactions/duplicates", nil)
+	res := httptest.NewRecorder()
+
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, "Bad credentials\n", res.Body.String())
+
+	dbMock.AssertExpectations(t)
+	authHandler.AssertExpectations(t)
+}